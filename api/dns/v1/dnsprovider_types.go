@@ -17,20 +17,115 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// +kubebuilder:validation:Enum=ALIYUN;CLOUDFLARE
+// +kubebuilder:validation:Enum=ALIYUN;CLOUDFLARE;RFC2136;ROUTE53;DNSPOD;POWERDNS;ETCD;ZONEFILE;PIHOLE;ADGUARD;GOOGLE;AZURE;WEBHOOK;HETZNER;DIGITALOCEAN;GANDI
 type DNSProviderType string
 
 const (
-	DNSProviderTypeAliyun     DNSProviderType = "ALIYUN"
-	DNSProviderTypeCloudflare DNSProviderType = "CLOUDFLARE"
+	DNSProviderTypeAliyun       DNSProviderType = "ALIYUN"
+	DNSProviderTypeCloudflare   DNSProviderType = "CLOUDFLARE"
+	DNSProviderTypeRFC2136      DNSProviderType = "RFC2136"
+	DNSProviderTypeRoute53      DNSProviderType = "ROUTE53"
+	DNSProviderTypeDNSPod       DNSProviderType = "DNSPOD"
+	DNSProviderTypePowerDNS     DNSProviderType = "POWERDNS"
+	DNSProviderTypeEtcd         DNSProviderType = "ETCD"
+	DNSProviderTypeZoneFile     DNSProviderType = "ZONEFILE"
+	DNSProviderTypePiHole       DNSProviderType = "PIHOLE"
+	DNSProviderTypeAdGuard      DNSProviderType = "ADGUARD"
+	DNSProviderTypeGoogle       DNSProviderType = "GOOGLE"
+	DNSProviderTypeAzure        DNSProviderType = "AZURE"
+	DNSProviderTypeWebhook      DNSProviderType = "WEBHOOK"
+	DNSProviderTypeHetzner      DNSProviderType = "HETZNER"
+	DNSProviderTypeDigitalOcean DNSProviderType = "DIGITALOCEAN"
+	DNSProviderTypeGandi        DNSProviderType = "GANDI"
+)
+
+// SecretKeySelector selects a key of a Secret
+type SecretKeySelector struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+}
+
+// ObjectReference references a namespaced object, e.g. a Secret or a ConfigMap
+type ObjectReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// +kubebuilder:validation:Enum=Delete;Report
+type DNSProviderGCPolicy string
+
+const (
+	// Orphaned records owned by this cluster will be deleted from the provider
+	DNSProviderGCPolicyDelete DNSProviderGCPolicy = "Delete"
+	// Orphaned records owned by this cluster will only be reported in status and events
+	DNSProviderGCPolicyReport DNSProviderGCPolicy = "Report"
+)
+
+// +kubebuilder:validation:Enum=AccessKey;STS;RAMRoleARN;ECSRAMRole;OIDCRoleARN
+type AliyunCredentialType string
+
+const (
+	// The static AccessKey pair
+	AliyunCredentialTypeAccessKey AliyunCredentialType = "AccessKey"
+	// The temporary AccessKey pair with securityToken
+	AliyunCredentialTypeSTS AliyunCredentialType = "STS"
+	// The RAM role of roleArn assumed with the AccessKey pair
+	AliyunCredentialTypeRAMRoleARN AliyunCredentialType = "RAMRoleARN"
+	// The RAM role attached to the ECS instance
+	AliyunCredentialTypeECSRAMRole AliyunCredentialType = "ECSRAMRole"
+	// The RAM role of roleArn assumed with the OIDC token, e.g. RRSA of ACK
+	AliyunCredentialTypeOIDCRoleARN AliyunCredentialType = "OIDCRoleARN"
 )
 
 type AliyunProviderConfig struct {
-	AccessKeyID     string `json:"accessKeyId"`
-	AccessKeySecret string `json:"accessKeySecret"`
+	// +optional
+	// +kubebuilder:default=AccessKey
+	CredentialType AliyunCredentialType `json:"credentialType,omitempty"`
+	// +optional
+	// Required by the AccessKey, STS and RAMRoleARN credentials
+	AccessKeyID string `json:"accessKeyId,omitempty"`
+	// +optional
+	// Required by the AccessKey, STS and RAMRoleARN credentials
+	AccessKeySecret string `json:"accessKeySecret,omitempty"`
+	// +optional
+	// The STS token of the temporary AccessKey pair, required by the STS credential
+	SecurityToken string `json:"securityToken,omitempty"`
+	// +optional
+	// The RAM role assumed by the RAMRoleARN and OIDCRoleARN credentials, env ALIBABA_CLOUD_ROLE_ARN will be used if empty
+	RoleARN string `json:"roleArn,omitempty"`
+	// +optional
+	// The session name of the assumed RAM role, k8s-dns-manager will be used if empty
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	// +optional
+	// The RAM role of the ECS instance, discovered from the metadata service if empty
+	RoleName string `json:"roleName,omitempty"`
+	// +optional
+	// The OIDC provider of the OIDCRoleARN credential, env ALIBABA_CLOUD_OIDC_PROVIDER_ARN will be used if empty
+	OIDCProviderARN string `json:"oidcProviderArn,omitempty"`
+	// +optional
+	// The file of the OIDC token of the OIDCRoleARN credential, env ALIBABA_CLOUD_OIDC_TOKEN_FILE will be used if empty
+	OIDCTokenFile string `json:"oidcTokenFile,omitempty"`
+	// +optional
+	// The region of the endpoints, e.g. cn-hangzhou
+	RegionID string `json:"regionId,omitempty"`
+	// +optional
+	// The endpoint of the Alibaba Cloud DNS API, alidns.<regionId>.aliyuncs.com or dns.aliyuncs.com will be used if empty
+	Endpoint string `json:"endpoint,omitempty"`
+	// +optional
+	// The endpoint of STS used by the OIDCRoleARN credential, sts.<regionId>.aliyuncs.com or sts.aliyuncs.com will be used if empty
+	STSEndpoint string `json:"stsEndpoint,omitempty"`
+	// +optional
+	// +kubebuilder:default=default
+	// The default record line, e.g. default, telecom, unicom, can be overrided by Annotation `dns.xzzpig.com/record-aliyun-line`
+	Line string `json:"line,omitempty"`
 }
 
 type CloudflareProviderConfig struct {
@@ -47,6 +142,260 @@ type CloudflareProviderConfig struct {
 	// +kubebuilder:default=false
 	// If true, the DNS record will be proxied by Cloudflare, can be overrided by Annotation `dns.xzzpig.com/record-proxied`
 	Proxied bool `json:"proxied"`
+	// +optional
+	// The tags in the form of `name:value` added to all records, along with Annotation `dns.xzzpig.com/record-cloudflare-tags`
+	Tags []string `json:"tags,omitempty"`
+	// +optional
+	// If true, the records are routed to the zone with the longest matching suffix among the zones of the token,
+	// instead of the single zone of zoneName, and the DNSRecords are matched by the zones instead of spec.domainName
+	MultiZone bool `json:"multiZone,omitempty"`
+	// +optional
+	// The names of the zones managed in multi-zone mode, all active zones of the token are managed if empty
+	Zones []string `json:"zones,omitempty"`
+	// +optional
+	// Only the zones of the account are managed in multi-zone mode
+	AccountID string `json:"accountId,omitempty"`
+}
+
+type RFC2136ProviderConfig struct {
+	// The address of the nameserver accepting dynamic updates, in the form of host[:port]
+	Nameserver string `json:"nameserver"`
+	// +optional
+	// If empty, spec.domainName will be used as zone name
+	Zone string `json:"zone,omitempty"`
+	// +optional
+	// +kubebuilder:validation:Enum=udp;tcp
+	// +kubebuilder:default=udp
+	Protocol string `json:"protocol,omitempty"`
+	// +optional
+	// The name of the TSIG key, updates are not signed if empty
+	TSIGKeyName string `json:"tsigKeyName,omitempty"`
+	// +optional
+	// +kubebuilder:validation:Enum=hmac-md5;hmac-sha1;hmac-sha224;hmac-sha256;hmac-sha384;hmac-sha512
+	// +kubebuilder:default=hmac-sha256
+	TSIGAlgorithm string `json:"tsigAlgorithm,omitempty"`
+	// +optional
+	// The Secret key holding the base64 encoded TSIG secret
+	TSIGSecretRef *SecretKeySelector `json:"tsigSecretRef,omitempty"`
+	// +optional
+	// +kubebuilder:default=false
+	// If true, records are searched by zone transfer (AXFR) instead of queries, which also allows listing the zone
+	AXFR bool `json:"axfr,omitempty"`
+}
+
+type DNSPodProviderConfig struct {
+	SecretID string `json:"secretId"`
+	// +optional
+	SecretKey string `json:"secretKey,omitempty"`
+	// +optional
+	// The Secret key holding the secret key, used if secretKey is empty
+	SecretKeySecretRef *SecretKeySelector `json:"secretKeySecretRef,omitempty"`
+	// +optional
+	// +kubebuilder:default=默认
+	// The default record line, can be overrided by Annotation `dns.xzzpig.com/record-dnspod-line`
+	RecordLine string `json:"recordLine,omitempty"`
+	// +optional
+	// The endpoint of the Tencent Cloud API, https://dnspod.tencentcloudapi.com will be used if empty
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+type PowerDNSProviderConfig struct {
+	// The URL of the PowerDNS Authoritative server API, e.g. http://pdns:8081
+	ServerURL string `json:"serverURL"`
+	// +optional
+	// +kubebuilder:default=localhost
+	ServerID string `json:"serverID,omitempty"`
+	// The Secret key holding the API key
+	APIKeySecretRef *SecretKeySelector `json:"apiKeySecretRef"`
+	// +optional
+	// If empty, spec.domainName will be used as zone name
+	Zone string `json:"zone,omitempty"`
+}
+
+type EtcdProviderConfig struct {
+	// The endpoints of the etcd cluster, e.g. https://etcd.kube-system.svc:2379
+	Endpoints []string `json:"endpoints"`
+	// +optional
+	// +kubebuilder:default=/skydns
+	// The path prefix of the CoreDNS etcd plugin
+	Prefix string `json:"prefix,omitempty"`
+	// +optional
+	Username string `json:"username,omitempty"`
+	// +optional
+	// The Secret key holding the password of username
+	PasswordSecretRef *SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// +optional
+	// The Secret holding the TLS client config, with keys `ca.crt`, `tls.crt` and `tls.key`
+	TLSSecretRef *ObjectReference `json:"tlsSecretRef,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Zone;Hosts
+type ZoneFileFormat string
+
+const (
+	// RFC 1035 zone file, served by BIND or the CoreDNS file plugin
+	ZoneFileFormatZone ZoneFileFormat = "Zone"
+	// hosts file, served by dnsmasq or the CoreDNS hosts plugin, only A and AAAA records are supported
+	ZoneFileFormatHosts ZoneFileFormat = "Hosts"
+)
+
+type ZoneFileProviderConfig struct {
+	// The ConfigMap storing the file, it will be created if not exists
+	ConfigMapRef ObjectReference `json:"configMapRef"`
+	// +optional
+	// The key of the file in the ConfigMap, `<domainName>.zone` for Zone format and `hosts` for Hosts format if empty
+	Key string `json:"key,omitempty"`
+	// +optional
+	// +kubebuilder:default=Zone
+	Format ZoneFileFormat `json:"format,omitempty"`
+	// +optional
+	// The primary nameserver in the SOA record, `ns.<domainName>.` if empty
+	Nameserver string `json:"nameserver,omitempty"`
+	// +optional
+	// The mailbox of the administrator in the SOA record, `hostmaster.<domainName>.` if empty
+	Hostmaster string `json:"hostmaster,omitempty"`
+}
+
+type PiHoleProviderConfig struct {
+	// The URL of the Pi-hole (v6 or later) web interface, e.g. http://pi.hole
+	ServerURL string `json:"serverURL"`
+	// +optional
+	// The Secret key holding the web interface password or an app password, can be omitted if no password is set
+	PasswordSecretRef *SecretKeySelector `json:"passwordSecretRef,omitempty"`
+}
+
+type AdGuardProviderConfig struct {
+	// The URL of the AdGuard Home web interface, e.g. http://adguard.lan:3000
+	ServerURL string `json:"serverURL"`
+	// +optional
+	Username string `json:"username,omitempty"`
+	// +optional
+	// The Secret key holding the password of username
+	PasswordSecretRef *SecretKeySelector `json:"passwordSecretRef,omitempty"`
+}
+
+type GoogleProviderConfig struct {
+	// The project of the managed zone
+	Project string `json:"project"`
+	// +optional
+	// The name of the managed zone, if empty the managed zone will be discovered by spec.domainName
+	ManagedZone string `json:"managedZone,omitempty"`
+	// +optional
+	// The Secret key holding the service account JSON key,
+	// the application default credentials (e.g. GKE workload identity) will be used if empty
+	ServiceAccountSecretRef *SecretKeySelector `json:"serviceAccountSecretRef,omitempty"`
+	// +optional
+	// The endpoint of the Cloud DNS API, https://dns.googleapis.com will be used if empty
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+type AzureProviderConfig struct {
+	SubscriptionID string `json:"subscriptionId"`
+	// The resource group of the DNS zone
+	ResourceGroup string `json:"resourceGroup"`
+	// +optional
+	// If empty, spec.domainName will be used as zone name
+	ZoneName string `json:"zoneName,omitempty"`
+	// +optional
+	// The tenant of the service principal, env AZURE_TENANT_ID will be used if empty
+	TenantID string `json:"tenantId,omitempty"`
+	// +optional
+	// The client id of the service principal or the user-assigned managed identity, env AZURE_CLIENT_ID will be used if empty
+	ClientID string `json:"clientId,omitempty"`
+	// +optional
+	// The Secret key holding the client secret of the service principal.
+	// If empty, the workload identity (env AZURE_FEDERATED_TOKEN_FILE) or the managed identity will be used
+	ClientSecretSecretRef *SecretKeySelector `json:"clientSecretSecretRef,omitempty"`
+	// +optional
+	// The endpoint of Azure Resource Manager, https://management.azure.com will be used if empty
+	Endpoint string `json:"endpoint,omitempty"`
+	// +optional
+	// The endpoint of Microsoft Entra ID, https://login.microsoftonline.com will be used if empty
+	AuthorityHost string `json:"authorityHost,omitempty"`
+}
+
+type WebhookProviderConfig struct {
+	// The base URL of the webhook implementing the webhook provider protocol, e.g. https://dns-webhook.dns-system.svc:8443
+	URL string `json:"url"`
+	// +optional
+	// The Secret key holding the bearer token sent in the Authorization header
+	TokenSecretRef *SecretKeySelector `json:"tokenSecretRef,omitempty"`
+	// +optional
+	// The Secret holding the TLS client config, with keys `ca.crt`, `tls.crt` and `tls.key`
+	TLSSecretRef *ObjectReference `json:"tlsSecretRef,omitempty"`
+	// +optional
+	// The settings passed to the webhook in every request, e.g. the zone id of the backend
+	Settings map[string]string `json:"settings,omitempty"`
+	// +optional
+	// +kubebuilder:default=30
+	// The timeout of a request (seconds)
+	Timeout int64 `json:"timeout,omitempty"`
+}
+
+type HetznerProviderConfig struct {
+	// The Secret key holding the API token of Hetzner DNS Console
+	APITokenSecretRef *SecretKeySelector `json:"apiTokenSecretRef"`
+	// +optional
+	// If empty, spec.domainName will be used as zone name
+	ZoneName string `json:"zoneName,omitempty"`
+	// +optional
+	// The endpoint of the Hetzner DNS API, https://dns.hetzner.com/api/v1 will be used if empty
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+type DigitalOceanProviderConfig struct {
+	// The Secret key holding the personal access token
+	TokenSecretRef *SecretKeySelector `json:"tokenSecretRef"`
+	// +optional
+	// If empty, spec.domainName will be used as zone name
+	ZoneName string `json:"zoneName,omitempty"`
+	// +optional
+	// The endpoint of the DigitalOcean API, https://api.digitalocean.com/v2 will be used if empty
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+type GandiProviderConfig struct {
+	// The Secret key holding the personal access token, which needs the permission to manage the technical configurations of the domain
+	TokenSecretRef *SecretKeySelector `json:"tokenSecretRef"`
+	// +optional
+	// If empty, spec.domainName will be used as zone name
+	ZoneName string `json:"zoneName,omitempty"`
+	// +optional
+	// The endpoint of the Gandi LiveDNS API, https://api.gandi.net/v5/livedns will be used if empty
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Public;Private
+type Route53ZoneType string
+
+const (
+	Route53ZoneTypePublic  Route53ZoneType = "Public"
+	Route53ZoneTypePrivate Route53ZoneType = "Private"
+)
+
+type Route53ProviderConfig struct {
+	// +optional
+	// +kubebuilder:default=us-east-1
+	Region string `json:"region,omitempty"`
+	// +optional
+	// If empty, the default credential chain (environment, shared config, IRSA, instance role) will be used
+	AccessKeyID string `json:"accessKeyId,omitempty"`
+	// +optional
+	// The Secret key holding the secret access key of accessKeyId
+	SecretAccessKeySecretRef *SecretKeySelector `json:"secretAccessKeySecretRef,omitempty"`
+	// +optional
+	// If empty, the hosted zone will be discovered by zoneName and zoneType
+	HostedZoneID string `json:"hostedZoneId,omitempty"`
+	// +optional
+	// If empty, spec.domainName will be used as zone name
+	ZoneName string `json:"zoneName,omitempty"`
+	// +optional
+	// +kubebuilder:default=Public
+	// The type of the hosted zone to discover, as a public and a private zone may have the same name
+	ZoneType Route53ZoneType `json:"zoneType,omitempty"`
+	// +optional
+	// The endpoint of the Route 53 API, the default endpoint of the region will be used if empty
+	Endpoint string `json:"endpoint,omitempty"`
 }
 
 // DNSProviderSpec defines the desired state of DNSProvider
@@ -59,12 +408,283 @@ type DNSProviderSpec struct {
 	Aliyun AliyunProviderConfig `json:"aliyun,omitempty"`
 	// +optional
 	Cloudflare CloudflareProviderConfig `json:"cloudflare,omitempty"`
+	// +optional
+	RFC2136 RFC2136ProviderConfig `json:"rfc2136,omitempty"`
+	// +optional
+	Route53 Route53ProviderConfig `json:"route53,omitempty"`
+	// +optional
+	DNSPod DNSPodProviderConfig `json:"dnspod,omitempty"`
+	// +optional
+	PowerDNS PowerDNSProviderConfig `json:"powerdns,omitempty"`
+	// +optional
+	Etcd EtcdProviderConfig `json:"etcd,omitempty"`
+	// +optional
+	ZoneFile ZoneFileProviderConfig `json:"zonefile,omitempty"`
+	// +optional
+	PiHole PiHoleProviderConfig `json:"pihole,omitempty"`
+	// +optional
+	AdGuard AdGuardProviderConfig `json:"adguard,omitempty"`
+	// +optional
+	Google GoogleProviderConfig `json:"google,omitempty"`
+	// +optional
+	Azure AzureProviderConfig `json:"azure,omitempty"`
+	// +optional
+	Webhook WebhookProviderConfig `json:"webhook,omitempty"`
+	// +optional
+	Hetzner HetznerProviderConfig `json:"hetzner,omitempty"`
+	// +optional
+	DigitalOcean DigitalOceanProviderConfig `json:"digitalocean,omitempty"`
+	// +optional
+	Gandi GandiProviderConfig `json:"gandi,omitempty"`
+	// +optional
+	// +kubebuilder:default=60
+	// The interval to refresh the zone snapshot shared by all records of this provider (seconds)
+	ZoneSyncInterval int64 `json:"zoneSyncInterval,omitempty"`
+	// +optional
+	// +kubebuilder:default=Delete
+	// What to do with records owned by this cluster which have no corresponding DNSRecord
+	GCPolicy DNSProviderGCPolicy `json:"gcPolicy,omitempty"`
+	// +optional
+	// +kubebuilder:default=600
+	// The interval to run garbage collection of orphaned records (seconds)
+	GCInterval int64 `json:"gcInterval,omitempty"`
+	// +optional
+	// If true, the changes of the matched DNSRecords are only planned and reported, never applied to the provider
+	DryRun bool `json:"dryRun,omitempty"`
+	// +optional
+	// +kubebuilder:default=Delete
+	// The default deletion policy of the matched DNSRecords
+	DeletionPolicy DNSRecordDeletionPolicy `json:"deletionPolicy,omitempty"`
+	// +optional
+	// +kubebuilder:default=false
+	// If true, the ownership marker is kept on retained records and marked as retained,
+	// so they are skipped by DNSZoneImport with the same owner id but never garbage collected
+	KeepOwnerOnRetain bool `json:"keepOwnerOnRetain,omitempty"`
+	// +optional
+	// Limits the calls to the provider API, unlimited if not set
+	RateLimit *DNSProviderRateLimit `json:"rateLimit,omitempty"`
+	// +optional
+	// The batching of the changes of the matched DNSRecords, only used by the providers supporting batch writes
+	Batch *DNSProviderBatchConfig `json:"batch,omitempty"`
+	// +optional
+	// +kubebuilder:default=30
+	// +kubebuilder:validation:Minimum=1
+	// The timeout of each call to the provider API (seconds), including the retries of the call
+	Timeout int64 `json:"timeout,omitempty"`
+}
+
+func (s *DNSProviderSpec) ZoneSyncDuration() time.Duration {
+	if s.ZoneSyncInterval <= 0 {
+		return time.Minute
+	}
+	return time.Duration(s.ZoneSyncInterval) * time.Second
+}
+
+func (s *DNSProviderSpec) GCDuration() time.Duration {
+	if s.GCInterval <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(s.GCInterval) * time.Second
+}
+
+// TimeoutDuration returns the timeout of each call to the provider API
+func (s *DNSProviderSpec) TimeoutDuration() time.Duration {
+	if s.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// DNSProviderBatchConfig configures how the changes of the DNSRecords are coalesced into batches
+type DNSProviderBatchConfig struct {
+	// +optional
+	// The time to wait for more changes before applying a batch (milliseconds),
+	// changes made while a batch is being applied are always batched together
+	Window int64 `json:"window,omitempty"`
+	// +optional
+	// +kubebuilder:validation:Maximum=1000
+	// The max count of changes applied in one batch, default is 100
+	Size int `json:"size,omitempty"`
+}
+
+// BatchWindow returns the time to wait for more changes before applying a batch
+func (s *DNSProviderSpec) BatchWindow() time.Duration {
+	if s.Batch == nil {
+		return 0
+	}
+	return time.Duration(s.Batch.Window) * time.Millisecond
+}
+
+// BatchSize returns the max count of changes applied in one batch
+func (s *DNSProviderSpec) BatchSize() int {
+	if s.Batch != nil && s.Batch.Size > 0 {
+		return s.Batch.Size
+	}
+	return 100
+}
+
+// DNSProviderRateLimit is a token bucket shared by the controllers calling a DNSProvider,
+// each call to the provider API takes a token, including the listing of garbage collection and zone import
+type DNSProviderRateLimit struct {
+	// +kubebuilder:validation:Minimum=1
+	// The count of requests allowed in a period
+	Requests int `json:"requests"`
+	// +optional
+	// +kubebuilder:default=1
+	// The period of the requests (seconds), e.g. 1200 requests per 300 seconds for Cloudflare
+	Period int64 `json:"period,omitempty"`
+	// +optional
+	// The count of requests allowed at once, default is requests
+	Burst int `json:"burst,omitempty"`
+}
+
+func (r *DNSProviderRateLimit) PeriodDuration() time.Duration {
+	if r.Period <= 0 {
+		return time.Second
+	}
+	return time.Duration(r.Period) * time.Second
+}
+
+func (r *DNSProviderRateLimit) BurstSize() int {
+	if r.Burst <= 0 {
+		return r.Requests
+	}
+	return r.Burst
+}
+
+// DNSProviderCapabilities describes the records supported by a provider
+type DNSProviderCapabilities struct {
+	// +optional
+	// The supported record types, all types are supported if empty
+	RecordTypes []DNSRecordType `json:"recordTypes,omitempty"`
+	// +optional
+	// The min ttl accepted by the provider (seconds), unlimited if 0
+	MinTTL int `json:"minTTL,omitempty"`
+	// +optional
+	// The max ttl accepted by the provider (seconds), unlimited if 0
+	MaxTTL int `json:"maxTTL,omitempty"`
+	// +optional
+	// If true, the records of the same name and type (except CNAME) with different values are kept side by side,
+	// otherwise the DNSRecords of the same name and type manage the same record
+	MultiValue bool `json:"multiValue,omitempty"`
+	// +optional
+	// If true, the ownership marker is stored with the records, which enables garbage collection
+	Ownership bool `json:"ownership,omitempty"`
+	// +optional
+	// The `dns.xzzpig.com/record-` annotations understood by the provider, a trailing `*` matches any suffix.
+	// Other annotations are ignored, e.g. those copied from an Ingress for another provider
+	Annotations []string `json:"annotations,omitempty"`
+	// +optional
+	// The routing policies supported by the provider, records with spec.routing are rejected if empty
+	RoutingPolicies []DNSRoutingPolicy `json:"routingPolicies,omitempty"`
+}
+
+// Check returns an error describing why the record is not supported, it's used before calling the provider,
+// so the record is rejected with a precise message instead of an error of the provider API
+func (c *DNSProviderCapabilities) Check(record *DNSRecord) error {
+	if len(c.RecordTypes) != 0 {
+		supported := false
+		names := make([]string, 0, len(c.RecordTypes))
+		for _, t := range c.RecordTypes {
+			supported = supported || t == record.Spec.RecordType
+			names = append(names, string(t))
+		}
+		if !supported {
+			return fmt.Errorf("record type %s is not supported by the provider, supported types are %s", record.Spec.RecordType, strings.Join(names, ", "))
+		}
+	}
+	if ttl := record.Spec.TTL; ttl != nil {
+		if c.MinTTL != 0 && *ttl < c.MinTTL {
+			return fmt.Errorf("ttl %d is less than the min ttl %d of the provider", *ttl, c.MinTTL)
+		}
+		if c.MaxTTL != 0 && *ttl > c.MaxTTL {
+			return fmt.Errorf("ttl %d is greater than the max ttl %d of the provider", *ttl, c.MaxTTL)
+		}
+	}
+	if record.Spec.Routing != nil && len(c.RoutingPolicies) == 0 {
+		return fmt.Errorf("routing of %s is not supported by the provider", record.Spec.Name)
+	}
+	for _, policy := range record.Spec.Routing.Policies() {
+		if !c.supportsRoutingPolicy(policy) {
+			names := make([]string, 0, len(c.RoutingPolicies))
+			for _, p := range c.RoutingPolicies {
+				names = append(names, string(p))
+			}
+			return fmt.Errorf("routing policy %s is not supported by the provider, supported policies are %s", policy, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+func (c *DNSProviderCapabilities) supportsRoutingPolicy(policy DNSRoutingPolicy) bool {
+	for _, p := range c.RoutingPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
 }
 
 // DNSProviderStatus defines the observed state of DNSProvider
 type DNSProviderStatus struct {
 	Valid   bool   `json:"valid"`
 	Message string `json:"message,omitempty"`
+	// +optional
+	// The capabilities declared by the provider, unknown if empty
+	Capabilities *DNSProviderCapabilities `json:"capabilities,omitempty"`
+	// +optional
+	// The zones managed by a multi-zone provider, the DNSRecords are matched by them instead of spec.domainName
+	Zones []string `json:"zones,omitempty"`
+	// +optional
+	GC *DNSProviderGCStatus `json:"gc,omitempty"`
+}
+
+// ZoneNames returns the zones managed by the provider, status.zones if declared by the provider, otherwise spec.domainName
+func (p *DNSProvider) ZoneNames() []string {
+	if len(p.Status.Zones) != 0 {
+		return p.Status.Zones
+	}
+	return []string{p.Spec.DomainName}
+}
+
+// SharesZone reports whether the providers manage a common zone of the same provider type,
+// e.g. the DNSProviders splitting the records of a domain by spec.selector
+func (p *DNSProvider) SharesZone(other *DNSProvider) bool {
+	if p.Name == other.Name {
+		return true
+	}
+	if p.Spec.ProviderType != other.Spec.ProviderType {
+		return false
+	}
+	for _, zone := range p.ZoneNames() {
+		for _, otherZone := range other.ZoneNames() {
+			if zone == otherZone {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchName reports whether the name is a subdomain of spec.domainName, or of any zone in status.zones if declared by the provider
+func (p *DNSProvider) MatchName(name string) bool {
+	for _, domainName := range p.ZoneNames() {
+		if strings.HasSuffix(name, "."+domainName) {
+			return true
+		}
+	}
+	return false
+}
+
+// DNSProviderGCStatus is the result of the last garbage collection of orphaned records
+type DNSProviderGCStatus struct {
+	LastRunTime metav1.Time `json:"lastRunTime"`
+	// The count of records owned by this cluster without corresponding DNSRecord
+	Orphaned int `json:"orphaned"`
+	// The count of orphaned records deleted from the provider
+	Deleted int `json:"deleted"`
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -73,6 +693,7 @@ type DNSProviderStatus struct {
 //+kubebuilder:printcolumn:name="Domain",type=string,JSONPath=`.spec.domainName`
 //+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.providerType`
 //+kubebuilder:printcolumn:name="Valid",type=boolean,JSONPath=`.status.valid`
+//+kubebuilder:printcolumn:name="Orphaned",type=integer,JSONPath=`.status.gc.orphaned`,priority=1
 //+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
 
 // DNSProvider is the Schema for the dnsproviders API
@@ -0,0 +1,42 @@
+package v1
+
+import (
+	"testing"
+)
+
+func TestDNSProviderCapabilitiesCheck(t *testing.T) {
+	ttl := func(v int) *int { return &v }
+	weight := int64(10)
+	capabilities := DNSProviderCapabilities{
+		RecordTypes:     []DNSRecordType{DNSRecordTypeA, DNSRecordTypeTXT},
+		MinTTL:          60,
+		MaxTTL:          3600,
+		RoutingPolicies: []DNSRoutingPolicy{DNSRoutingPolicyWeighted},
+	}
+
+	cases := []struct {
+		name         string
+		capabilities DNSProviderCapabilities
+		spec         DNSRecordSpec
+		wantErr      bool
+	}{
+		{"supported", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA, TTL: ttl(600)}, false},
+		{"unsupported type", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeCNAME, TTL: ttl(600)}, true},
+		{"unknown capabilities", DNSProviderCapabilities{}, DNSRecordSpec{RecordType: DNSRecordTypeCNAME, TTL: ttl(1)}, false},
+		{"no ttl", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA}, false},
+		{"min ttl", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA, TTL: ttl(60)}, false},
+		{"below min ttl", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA, TTL: ttl(59)}, true},
+		{"max ttl", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA, TTL: ttl(3600)}, false},
+		{"above max ttl", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA, TTL: ttl(3601)}, true},
+		{"supported policy", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA, Routing: &DNSRecordRouting{SetIdentifier: "a", Weight: &weight}}, false},
+		{"unsupported policy", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA, Routing: &DNSRecordRouting{SetIdentifier: "a", Geo: "CN"}}, true},
+		{"partly supported policies", capabilities, DNSRecordSpec{RecordType: DNSRecordTypeA, Routing: &DNSRecordRouting{SetIdentifier: "a", Weight: &weight, Failover: DNSRecordFailoverRolePrimary}}, true},
+		{"routing without policies", DNSProviderCapabilities{}, DNSRecordSpec{RecordType: DNSRecordTypeA, Routing: &DNSRecordRouting{SetIdentifier: "a"}}, true},
+	}
+	for _, tc := range cases {
+		err := tc.capabilities.Check(&DNSRecord{Spec: tc.spec})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+	}
+}
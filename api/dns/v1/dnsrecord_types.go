@@ -18,6 +18,7 @@ package v1
 
 import (
 	"strings"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -40,6 +41,187 @@ const (
 	DNSRecordTypeCAA   DNSRecordType = "CAA"
 )
 
+// +kubebuilder:validation:Enum=Delete;Retain
+type DNSRecordDeletionPolicy string
+
+const (
+	// The record will be deleted from the provider when the DNSRecord is deleted
+	DNSRecordDeletionPolicyDelete DNSRecordDeletionPolicy = "Delete"
+	// The record will be left in the provider when the DNSRecord is deleted
+	DNSRecordDeletionPolicyRetain DNSRecordDeletionPolicy = "Retain"
+)
+
+// +kubebuilder:validation:Enum=Weighted;Geo;Failover;HealthCheck
+type DNSRoutingPolicy string
+
+const (
+	// The answers are chosen by the relative weight of the records
+	DNSRoutingPolicyWeighted DNSRoutingPolicy = "Weighted"
+	// The record answers the queries from a location or a resolution line
+	DNSRoutingPolicyGeo DNSRoutingPolicy = "Geo"
+	// The secondary record answers when the primary record is unhealthy
+	DNSRoutingPolicyFailover DNSRoutingPolicy = "Failover"
+	// The record is withdrawn when the health check of the provider fails
+	DNSRoutingPolicyHealthCheck DNSRoutingPolicy = "HealthCheck"
+)
+
+// +kubebuilder:validation:Enum=Primary;Secondary
+type DNSRecordFailoverRole string
+
+const (
+	DNSRecordFailoverRolePrimary   DNSRecordFailoverRole = "Primary"
+	DNSRecordFailoverRoleSecondary DNSRecordFailoverRole = "Secondary"
+)
+
+// DNSRecordRouting is the routing policy of a record, the records of the same name and type with different
+// set identifiers are kept side by side and answered by the policy
+type DNSRecordRouting struct {
+	// +optional
+	// Distinguishes the records of the same name and type, required by Route53
+	SetIdentifier string `json:"setIdentifier,omitempty"`
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=255
+	// The relative weight of the record among the records of the same name and type
+	Weight *int64 `json:"weight,omitempty"`
+	// +optional
+	// The location of the clients answered by the record, a country code (e.g. `US`), a continent code prefixed
+	// by `continent:` (e.g. `continent:EU`) or `*` for the default location of Route53, the line (e.g. `电信`) of DNSPod
+	Geo string `json:"geo,omitempty"`
+	// +optional
+	// The role of the record in a failover pair
+	Failover DNSRecordFailoverRole `json:"failover,omitempty"`
+	// +optional
+	// The id of the health check of the provider, e.g. a Route53 health check id
+	HealthCheckID string `json:"healthCheckId,omitempty"`
+}
+
+// Policies returns the routing policies used by the routing
+func (r *DNSRecordRouting) Policies() []DNSRoutingPolicy {
+	var policies []DNSRoutingPolicy
+	if r == nil {
+		return policies
+	}
+	if r.Weight != nil {
+		policies = append(policies, DNSRoutingPolicyWeighted)
+	}
+	if r.Geo != "" {
+		policies = append(policies, DNSRoutingPolicyGeo)
+	}
+	if r.Failover != "" {
+		policies = append(policies, DNSRoutingPolicyFailover)
+	}
+	if r.HealthCheckID != "" {
+		policies = append(policies, DNSRoutingPolicyHealthCheck)
+	}
+	return policies
+}
+
+// +kubebuilder:validation:Enum=HTTP;HTTPS
+type DNSRecordHTTPScheme string
+
+const (
+	DNSRecordHTTPSchemeHTTP  DNSRecordHTTPScheme = "HTTP"
+	DNSRecordHTTPSchemeHTTPS DNSRecordHTTPScheme = "HTTPS"
+)
+
+// DNSRecordHealthCheck is the health check of the target of a record, probed by the controller.
+// The record is withdrawn from the provider, or its value swapped by fallbackValue, while the target is unhealthy.
+// Exactly one of http, tcp and endpoints should be set.
+type DNSRecordHealthCheck struct {
+	// +optional
+	// Probe the target by an HTTP GET, 2xx and 3xx responses are healthy
+	HTTP *DNSRecordHTTPHealthCheck `json:"http,omitempty"`
+	// +optional
+	// Probe the target by opening a TCP connection
+	TCP *DNSRecordTCPHealthCheck `json:"tcp,omitempty"`
+	// +optional
+	// The target is healthy if any of the Services has a ready endpoint, e.g. the backends of an Ingress
+	Endpoints *DNSRecordEndpointsHealthCheck `json:"endpoints,omitempty"`
+	// +optional
+	// The host or ip to probe, spec.value will be used if empty
+	Address string `json:"address,omitempty"`
+	// +optional
+	// +kubebuilder:default=30
+	// +kubebuilder:validation:Minimum=1
+	// The interval between probes (seconds)
+	Interval int64 `json:"interval,omitempty"`
+	// +optional
+	// +kubebuilder:default=5
+	// +kubebuilder:validation:Minimum=1
+	// The timeout of a probe (seconds)
+	Timeout int64 `json:"timeout,omitempty"`
+	// +optional
+	// +kubebuilder:default=2
+	// +kubebuilder:validation:Minimum=1
+	// The count of successful probes in a row to mark an unhealthy target healthy
+	HealthyThreshold int `json:"healthyThreshold,omitempty"`
+	// +optional
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// The count of failed probes in a row to mark a healthy target unhealthy
+	UnhealthyThreshold int `json:"unhealthyThreshold,omitempty"`
+	// +optional
+	// The value published while the target is unhealthy, the record is withdrawn if empty
+	FallbackValue string `json:"fallbackValue,omitempty"`
+}
+
+type DNSRecordHTTPHealthCheck struct {
+	// +optional
+	// +kubebuilder:default=HTTP
+	Scheme DNSRecordHTTPScheme `json:"scheme,omitempty"`
+	// +optional
+	// The port to probe, 80 for HTTP and 443 for HTTPS if empty
+	Port int32 `json:"port,omitempty"`
+	// +optional
+	// +kubebuilder:default=/
+	Path string `json:"path,omitempty"`
+	// +optional
+	// The Host header and the TLS server name, spec.name will be used if empty
+	Host string `json:"host,omitempty"`
+}
+
+type DNSRecordTCPHealthCheck struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+}
+
+type DNSRecordEndpointsHealthCheck struct {
+	// +optional
+	// The namespace of the Services, the namespace of the DNSRecord will be used if empty
+	Namespace string `json:"namespace,omitempty"`
+	// +kubebuilder:validation:MinItems=1
+	// The names of the Services
+	Services []string `json:"services"`
+}
+
+func (c *DNSRecordHealthCheck) IntervalDuration() time.Duration {
+	if c.Interval <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Interval) * time.Second
+}
+
+func (c *DNSRecordHealthCheck) TimeoutDuration() time.Duration {
+	if c.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+// Thresholds returns the count of probes in a row to change the health of the target
+func (c *DNSRecordHealthCheck) Thresholds() (healthy int, unhealthy int) {
+	healthy, unhealthy = c.HealthyThreshold, c.UnhealthyThreshold
+	if healthy <= 0 {
+		healthy = 2
+	}
+	if unhealthy <= 0 {
+		unhealthy = 3
+	}
+	return healthy, unhealthy
+}
+
 type NamespacedName struct {
 	// +optional
 	Namespace string `json:"namespace,omitempty"`
@@ -53,6 +235,15 @@ type DNSRecordSpec struct {
 	Value      string        `json:"value"`
 	// +optional
 	TTL *int `json:"ttl"`
+	// +optional
+	// What to do with the provider record when the DNSRecord is deleted, spec.deletionPolicy of the provider will be used if empty
+	DeletionPolicy DNSRecordDeletionPolicy `json:"deletionPolicy,omitempty"`
+	// +optional
+	// The routing policy of the record, rejected by the providers not supporting it
+	Routing *DNSRecordRouting `json:"routing,omitempty"`
+	// +optional
+	// The health check of the target, the record is withdrawn or swapped while the target is unhealthy
+	HealthCheck *DNSRecordHealthCheck `json:"healthCheck,omitempty"`
 }
 
 type DNSRecordStatusPhase string
@@ -63,6 +254,9 @@ const (
 	DNSRecordStatusPhaseSyncing  DNSRecordStatusPhase = "Syncing"
 	DNSRecordStatusPhaseSuccess  DNSRecordStatusPhase = "Success"
 	DNSRecordStatusPhaseFailed   DNSRecordStatusPhase = "Failed"
+	DNSRecordStatusPhasePlanned  DNSRecordStatusPhase = "Planned"
+	// The record is removed from the provider while the target is unhealthy
+	DNSRecordStatusPhaseWithdrawn DNSRecordStatusPhase = "Withdrawn"
 )
 
 // DNSRecordStatus defines the observed state of DNSRecord
@@ -71,6 +265,42 @@ type DNSRecordStatus struct {
 	RecordID    string               `json:"recordID,omitempty"`
 	Status      DNSRecordStatusPhase `json:"status"`
 	Message     string               `json:"message"`
+	// +optional
+	// The change planned in dry-run mode
+	Plan string `json:"plan,omitempty"`
+	// +optional
+	// The backoff of the failed syncs, cleared once synced
+	Retry *DNSRecordRetryStatus `json:"retry,omitempty"`
+	// +optional
+	// The health of the target, set if spec.healthCheck is set
+	Health *DNSRecordHealthStatus `json:"health,omitempty"`
+}
+
+// DNSRecordHealthStatus is the health of the target of a DNSRecord
+type DNSRecordHealthStatus struct {
+	Healthy bool `json:"healthy"`
+	// The count of successful probes in a row
+	Successes int `json:"successes"`
+	// The count of failed probes in a row
+	Failures int `json:"failures"`
+	// +optional
+	// The error of the last failed probe
+	Message string `json:"message,omitempty"`
+	// +optional
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+	// +optional
+	// The time the health was changed
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// DNSRecordRetryStatus is the backoff of a DNSRecord failed to sync
+type DNSRecordRetryStatus struct {
+	// The count of failures in a row
+	Attempts int `json:"attempts"`
+	// The class of the last error: Retryable, RateLimited or Permanent
+	Reason string `json:"reason"`
+	// The time of the next retry
+	NextRetryTime metav1.Time `json:"nextRetryTime"`
 }
 
 //+kubebuilder:object:root=true
@@ -79,6 +309,8 @@ type DNSRecordStatus struct {
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 //+kubebuilder:printcolumn:name="Provider",type="string",JSONPath=".status.providerRef.name",priority=1
 //+kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.message",priority=1
+//+kubebuilder:printcolumn:name="Retries",type="integer",JSONPath=".status.retry.attempts",priority=1
+//+kubebuilder:printcolumn:name="Healthy",type="boolean",JSONPath=".status.health.healthy",priority=1
 
 // DNSRecord is the Schema for the dnsrecords API
 type DNSRecord struct {
@@ -102,13 +334,13 @@ func init() {
 	SchemeBuilder.Register(&DNSRecord{}, &DNSRecordList{})
 }
 
-func (record *DNSRecord) Match(provider *DNSProviderSpec) bool {
-	domainName := "." + provider.DomainName
-	if !strings.HasSuffix(record.Spec.Name, domainName) {
+// Match reports whether the record is managed by the provider, by the domain names of the provider and its selector
+func (record *DNSRecord) Match(provider *DNSProvider) bool {
+	if !provider.MatchName(record.Spec.Name) {
 		return false
 	}
-	if provider.Selector != nil {
-		selector, err := metav1.LabelSelectorAsSelector(provider.Selector)
+	if provider.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(provider.Spec.Selector)
 		if err != nil {
 			return false
 		}
@@ -123,6 +355,16 @@ func (record *DNSRecordSpec) RR(provider *DNSProviderSpec) string {
 	return rr
 }
 
+func (record *DNSRecordSpec) GetDeletionPolicy(provider *DNSProviderSpec) DNSRecordDeletionPolicy {
+	if record.DeletionPolicy != "" {
+		return record.DeletionPolicy
+	}
+	if provider.DeletionPolicy != "" {
+		return provider.DeletionPolicy
+	}
+	return DNSRecordDeletionPolicyDelete
+}
+
 func (record *DNSRecordSpec) SpinalName() string {
 	return strings.TrimSpace(strings.ToLower(strings.ReplaceAll(record.Name, ".", "-")))
 }
@@ -0,0 +1,81 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DNSZoneImportSpec defines the desired state of DNSZoneImport
+type DNSZoneImportSpec struct {
+	// The name of the DNSProvider to import records from
+	ProviderRef string `json:"providerRef"`
+	// +optional
+	// The record types to import, all supported types will be imported if empty
+	RecordTypes []DNSRecordType `json:"recordTypes,omitempty"`
+	// +optional
+	// Only the records whose name matches the regular expression will be imported
+	NameRegex string `json:"nameRegex,omitempty"`
+	// +optional
+	// Extra labels added to the imported DNSRecords
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// DNSZoneImportStatus defines the observed state of DNSZoneImport
+type DNSZoneImportStatus struct {
+	Valid   bool   `json:"valid"`
+	Message string `json:"message"`
+	// The count of DNSRecords created by the last import
+	Imported int `json:"imported"`
+	// The count of provider records skipped by the last import, as they are filtered or already managed
+	Skipped int `json:"skipped"`
+	// +optional
+	// The provider records skipped as their DNSRecords would not be matched to the provider, e.g. the records of the zone apex,
+	// in the form of `<type> <name>`
+	Unmatched []string `json:"unmatched,omitempty"`
+	// +optional
+	LastImportTime *metav1.Time `json:"lastImportTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.providerRef`
+//+kubebuilder:printcolumn:name="Valid",type=boolean,JSONPath=`.status.valid`
+//+kubebuilder:printcolumn:name="Imported",type=integer,JSONPath=`.status.imported`
+//+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+
+// DNSZoneImport is the Schema for the dnszoneimports API
+type DNSZoneImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DNSZoneImportSpec   `json:"spec,omitempty"`
+	Status DNSZoneImportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DNSZoneImportList contains a list of DNSZoneImport
+type DNSZoneImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DNSZoneImport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DNSZoneImport{}, &DNSZoneImportList{})
+}
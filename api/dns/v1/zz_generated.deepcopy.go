@@ -26,6 +26,26 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdGuardProviderConfig) DeepCopyInto(out *AdGuardProviderConfig) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdGuardProviderConfig.
+func (in *AdGuardProviderConfig) DeepCopy() *AdGuardProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AdGuardProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AliyunProviderConfig) DeepCopyInto(out *AliyunProviderConfig) {
 	*out = *in
@@ -41,6 +61,26 @@ func (in *AliyunProviderConfig) DeepCopy() *AliyunProviderConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureProviderConfig) DeepCopyInto(out *AzureProviderConfig) {
+	*out = *in
+	if in.ClientSecretSecretRef != nil {
+		in, out := &in.ClientSecretSecretRef, &out.ClientSecretSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureProviderConfig.
+func (in *AzureProviderConfig) DeepCopy() *AzureProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CNAMEGeneratorConfig) DeepCopyInto(out *CNAMEGeneratorConfig) {
 	*out = *in
@@ -59,6 +99,16 @@ func (in *CNAMEGeneratorConfig) DeepCopy() *CNAMEGeneratorConfig {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudflareProviderConfig) DeepCopyInto(out *CloudflareProviderConfig) {
 	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareProviderConfig.
@@ -182,13 +232,33 @@ func (in *DNSGeneratorStatus) DeepCopy() *DNSGeneratorStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSPodProviderConfig) DeepCopyInto(out *DNSPodProviderConfig) {
+	*out = *in
+	if in.SecretKeySecretRef != nil {
+		in, out := &in.SecretKeySecretRef, &out.SecretKeySecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSPodProviderConfig.
+func (in *DNSPodProviderConfig) DeepCopy() *DNSPodProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSPodProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DNSProvider) DeepCopyInto(out *DNSProvider) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSProvider.
@@ -209,6 +279,67 @@ func (in *DNSProvider) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSProviderBatchConfig) DeepCopyInto(out *DNSProviderBatchConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSProviderBatchConfig.
+func (in *DNSProviderBatchConfig) DeepCopy() *DNSProviderBatchConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSProviderBatchConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSProviderCapabilities) DeepCopyInto(out *DNSProviderCapabilities) {
+	*out = *in
+	if in.RecordTypes != nil {
+		in, out := &in.RecordTypes, &out.RecordTypes
+		*out = make([]DNSRecordType, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RoutingPolicies != nil {
+		in, out := &in.RoutingPolicies, &out.RoutingPolicies
+		*out = make([]DNSRoutingPolicy, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSProviderCapabilities.
+func (in *DNSProviderCapabilities) DeepCopy() *DNSProviderCapabilities {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSProviderCapabilities)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSProviderGCStatus) DeepCopyInto(out *DNSProviderGCStatus) {
+	*out = *in
+	in.LastRunTime.DeepCopyInto(&out.LastRunTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSProviderGCStatus.
+func (in *DNSProviderGCStatus) DeepCopy() *DNSProviderGCStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSProviderGCStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DNSProviderList) DeepCopyInto(out *DNSProviderList) {
 	*out = *in
@@ -241,6 +372,21 @@ func (in *DNSProviderList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSProviderRateLimit) DeepCopyInto(out *DNSProviderRateLimit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSProviderRateLimit.
+func (in *DNSProviderRateLimit) DeepCopy() *DNSProviderRateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSProviderRateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DNSProviderSpec) DeepCopyInto(out *DNSProviderSpec) {
 	*out = *in
@@ -250,7 +396,31 @@ func (in *DNSProviderSpec) DeepCopyInto(out *DNSProviderSpec) {
 		(*in).DeepCopyInto(*out)
 	}
 	out.Aliyun = in.Aliyun
-	out.Cloudflare = in.Cloudflare
+	in.Cloudflare.DeepCopyInto(&out.Cloudflare)
+	in.RFC2136.DeepCopyInto(&out.RFC2136)
+	in.Route53.DeepCopyInto(&out.Route53)
+	in.DNSPod.DeepCopyInto(&out.DNSPod)
+	in.PowerDNS.DeepCopyInto(&out.PowerDNS)
+	in.Etcd.DeepCopyInto(&out.Etcd)
+	out.ZoneFile = in.ZoneFile
+	in.PiHole.DeepCopyInto(&out.PiHole)
+	in.AdGuard.DeepCopyInto(&out.AdGuard)
+	in.Google.DeepCopyInto(&out.Google)
+	in.Azure.DeepCopyInto(&out.Azure)
+	in.Webhook.DeepCopyInto(&out.Webhook)
+	in.Hetzner.DeepCopyInto(&out.Hetzner)
+	in.DigitalOcean.DeepCopyInto(&out.DigitalOcean)
+	in.Gandi.DeepCopyInto(&out.Gandi)
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(DNSProviderRateLimit)
+		**out = **in
+	}
+	if in.Batch != nil {
+		in, out := &in.Batch, &out.Batch
+		*out = new(DNSProviderBatchConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSProviderSpec.
@@ -266,6 +436,21 @@ func (in *DNSProviderSpec) DeepCopy() *DNSProviderSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DNSProviderStatus) DeepCopyInto(out *DNSProviderStatus) {
 	*out = *in
+	if in.Capabilities != nil {
+		in, out := &in.Capabilities, &out.Capabilities
+		*out = new(DNSProviderCapabilities)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GC != nil {
+		in, out := &in.GC, &out.GC
+		*out = new(DNSProviderGCStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSProviderStatus.
@@ -284,7 +469,7 @@ func (in *DNSRecord) DeepCopyInto(out *DNSRecord) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecord.
@@ -305,6 +490,94 @@ func (in *DNSRecord) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSRecordEndpointsHealthCheck) DeepCopyInto(out *DNSRecordEndpointsHealthCheck) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordEndpointsHealthCheck.
+func (in *DNSRecordEndpointsHealthCheck) DeepCopy() *DNSRecordEndpointsHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSRecordEndpointsHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSRecordHTTPHealthCheck) DeepCopyInto(out *DNSRecordHTTPHealthCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordHTTPHealthCheck.
+func (in *DNSRecordHTTPHealthCheck) DeepCopy() *DNSRecordHTTPHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSRecordHTTPHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSRecordHealthCheck) DeepCopyInto(out *DNSRecordHealthCheck) {
+	*out = *in
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(DNSRecordHTTPHealthCheck)
+		**out = **in
+	}
+	if in.TCP != nil {
+		in, out := &in.TCP, &out.TCP
+		*out = new(DNSRecordTCPHealthCheck)
+		**out = **in
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(DNSRecordEndpointsHealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordHealthCheck.
+func (in *DNSRecordHealthCheck) DeepCopy() *DNSRecordHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSRecordHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSRecordHealthStatus) DeepCopyInto(out *DNSRecordHealthStatus) {
+	*out = *in
+	if in.LastProbeTime != nil {
+		in, out := &in.LastProbeTime, &out.LastProbeTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordHealthStatus.
+func (in *DNSRecordHealthStatus) DeepCopy() *DNSRecordHealthStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSRecordHealthStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DNSRecordList) DeepCopyInto(out *DNSRecordList) {
 	*out = *in
@@ -337,6 +610,42 @@ func (in *DNSRecordList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSRecordRetryStatus) DeepCopyInto(out *DNSRecordRetryStatus) {
+	*out = *in
+	in.NextRetryTime.DeepCopyInto(&out.NextRetryTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordRetryStatus.
+func (in *DNSRecordRetryStatus) DeepCopy() *DNSRecordRetryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSRecordRetryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSRecordRouting) DeepCopyInto(out *DNSRecordRouting) {
+	*out = *in
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordRouting.
+func (in *DNSRecordRouting) DeepCopy() *DNSRecordRouting {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSRecordRouting)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DNSRecordSpec) DeepCopyInto(out *DNSRecordSpec) {
 	*out = *in
@@ -345,6 +654,16 @@ func (in *DNSRecordSpec) DeepCopyInto(out *DNSRecordSpec) {
 		*out = new(int)
 		**out = **in
 	}
+	if in.Routing != nil {
+		in, out := &in.Routing, &out.Routing
+		*out = new(DNSRecordRouting)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(DNSRecordHealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordSpec.
@@ -361,6 +680,16 @@ func (in *DNSRecordSpec) DeepCopy() *DNSRecordSpec {
 func (in *DNSRecordStatus) DeepCopyInto(out *DNSRecordStatus) {
 	*out = *in
 	out.ProviderRef = in.ProviderRef
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(DNSRecordRetryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Health != nil {
+		in, out := &in.Health, &out.Health
+		*out = new(DNSRecordHealthStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordStatus.
@@ -373,6 +702,241 @@ func (in *DNSRecordStatus) DeepCopy() *DNSRecordStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSRecordTCPHealthCheck) DeepCopyInto(out *DNSRecordTCPHealthCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordTCPHealthCheck.
+func (in *DNSRecordTCPHealthCheck) DeepCopy() *DNSRecordTCPHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSRecordTCPHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSZoneImport) DeepCopyInto(out *DNSZoneImport) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSZoneImport.
+func (in *DNSZoneImport) DeepCopy() *DNSZoneImport {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSZoneImport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DNSZoneImport) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSZoneImportList) DeepCopyInto(out *DNSZoneImportList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DNSZoneImport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSZoneImportList.
+func (in *DNSZoneImportList) DeepCopy() *DNSZoneImportList {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSZoneImportList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DNSZoneImportList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSZoneImportSpec) DeepCopyInto(out *DNSZoneImportSpec) {
+	*out = *in
+	if in.RecordTypes != nil {
+		in, out := &in.RecordTypes, &out.RecordTypes
+		*out = make([]DNSRecordType, len(*in))
+		copy(*out, *in)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSZoneImportSpec.
+func (in *DNSZoneImportSpec) DeepCopy() *DNSZoneImportSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSZoneImportSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSZoneImportStatus) DeepCopyInto(out *DNSZoneImportStatus) {
+	*out = *in
+	if in.Unmatched != nil {
+		in, out := &in.Unmatched, &out.Unmatched
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastImportTime != nil {
+		in, out := &in.LastImportTime, &out.LastImportTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSZoneImportStatus.
+func (in *DNSZoneImportStatus) DeepCopy() *DNSZoneImportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSZoneImportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DigitalOceanProviderConfig) DeepCopyInto(out *DigitalOceanProviderConfig) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DigitalOceanProviderConfig.
+func (in *DigitalOceanProviderConfig) DeepCopy() *DigitalOceanProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DigitalOceanProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdProviderConfig) DeepCopyInto(out *EtcdProviderConfig) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.TLSSecretRef != nil {
+		in, out := &in.TLSSecretRef, &out.TLSSecretRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdProviderConfig.
+func (in *EtcdProviderConfig) DeepCopy() *EtcdProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GandiProviderConfig) DeepCopyInto(out *GandiProviderConfig) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GandiProviderConfig.
+func (in *GandiProviderConfig) DeepCopy() *GandiProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GandiProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GoogleProviderConfig) DeepCopyInto(out *GoogleProviderConfig) {
+	*out = *in
+	if in.ServiceAccountSecretRef != nil {
+		in, out := &in.ServiceAccountSecretRef, &out.ServiceAccountSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoogleProviderConfig.
+func (in *GoogleProviderConfig) DeepCopy() *GoogleProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GoogleProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HetznerProviderConfig) DeepCopyInto(out *HetznerProviderConfig) {
+	*out = *in
+	if in.APITokenSecretRef != nil {
+		in, out := &in.APITokenSecretRef, &out.APITokenSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HetznerProviderConfig.
+func (in *HetznerProviderConfig) DeepCopy() *HetznerProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HetznerProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamespacedName) DeepCopyInto(out *NamespacedName) {
 	*out = *in
@@ -387,3 +951,161 @@ func (in *NamespacedName) DeepCopy() *NamespacedName {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectReference) DeepCopyInto(out *ObjectReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectReference.
+func (in *ObjectReference) DeepCopy() *ObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PiHoleProviderConfig) DeepCopyInto(out *PiHoleProviderConfig) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PiHoleProviderConfig.
+func (in *PiHoleProviderConfig) DeepCopy() *PiHoleProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PiHoleProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerDNSProviderConfig) DeepCopyInto(out *PowerDNSProviderConfig) {
+	*out = *in
+	if in.APIKeySecretRef != nil {
+		in, out := &in.APIKeySecretRef, &out.APIKeySecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerDNSProviderConfig.
+func (in *PowerDNSProviderConfig) DeepCopy() *PowerDNSProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerDNSProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RFC2136ProviderConfig) DeepCopyInto(out *RFC2136ProviderConfig) {
+	*out = *in
+	if in.TSIGSecretRef != nil {
+		in, out := &in.TSIGSecretRef, &out.TSIGSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RFC2136ProviderConfig.
+func (in *RFC2136ProviderConfig) DeepCopy() *RFC2136ProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RFC2136ProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Route53ProviderConfig) DeepCopyInto(out *Route53ProviderConfig) {
+	*out = *in
+	if in.SecretAccessKeySecretRef != nil {
+		in, out := &in.SecretAccessKeySecretRef, &out.SecretAccessKeySecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route53ProviderConfig.
+func (in *Route53ProviderConfig) DeepCopy() *Route53ProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(Route53ProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeySelector) DeepCopyInto(out *SecretKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeySelector.
+func (in *SecretKeySelector) DeepCopy() *SecretKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookProviderConfig) DeepCopyInto(out *WebhookProviderConfig) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.TLSSecretRef != nil {
+		in, out := &in.TLSSecretRef, &out.TLSSecretRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+	if in.Settings != nil {
+		in, out := &in.Settings, &out.Settings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookProviderConfig.
+func (in *WebhookProviderConfig) DeepCopy() *WebhookProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneFileProviderConfig) DeepCopyInto(out *ZoneFileProviderConfig) {
+	*out = *in
+	out.ConfigMapRef = in.ConfigMapRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneFileProviderConfig.
+func (in *ZoneFileProviderConfig) DeepCopy() *ZoneFileProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneFileProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
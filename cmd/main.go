@@ -19,6 +19,7 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	"github.com/xzzpig/k8s-dns-manager/pkg/config"
 	"github.com/xzzpig/k8s-dns-manager/pkg/generator"
@@ -27,11 +28,26 @@ import (
 	_ "github.com/xzzpig/k8s-dns-manager/pkg/generator/cname"
 	_ "github.com/xzzpig/k8s-dns-manager/pkg/generator/ddns"
 
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/adguard"
 	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/alidns"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/azure"
 	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/cloudflare"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/digitalocean"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/dnspod"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/etcd"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/gandi"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/google"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/hetzner"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/pihole"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/powerdns"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/rfc2136"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/route53"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/webhook"
+	_ "github.com/xzzpig/k8s-dns-manager/pkg/provider/zonefile"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
@@ -40,6 +56,7 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -64,9 +81,20 @@ func init() {
 
 func main() {
 	var enableLeaderElection bool
+	var dryRun bool
+	var maxConcurrentReconciles int
+	var reconcileTimeout time.Duration
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Only plan and report the changes of DNSRecords, never apply them to the DNS providers.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The count of DNSRecords reconciled at the same time, if greater than 1 the changes of them are batched by the providers supporting batch writes.")
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", 5*time.Minute,
+		"The timeout of a reconciliation, so a stuck DNS provider API does not pin the workers, 0 means unlimited. "+
+			"It should be longer than the timeout of the provider calls (spec.timeout of DNSProvider). "+
+			"The cached clients of the DNS providers unused for longer are closed.")
 	opts := zap.Options{
 		Development: config.GetConfig().Environment == "development",
 	}
@@ -82,6 +110,9 @@ func main() {
 		HealthProbeBindAddress: config.GetConfig().Bind.HealthProbe,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "2e3e7a81.xzzpig.com",
+		// the Secrets of the providers, e.g. the TSIG keys, and the ConfigMaps of the zone files are read from the API server,
+		// so the objects of the whole cluster are neither watched nor cached, and the zone files are never updated from stale reads
+		ClientDisableCacheFor: []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -99,23 +130,31 @@ func main() {
 		os.Exit(1)
 	}
 
+	provider.SetReconcileTimeout(reconcileTimeout)
+
 	if err = (&dnscontroller.DNSProviderReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		DryRun:           dryRun,
+		ReconcileTimeout: reconcileTimeout,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DNSProvider")
 		os.Exit(1)
 	}
 	if err = (&dnscontroller.DNSRecordReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		DryRun:                  dryRun,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		ReconcileTimeout:        reconcileTimeout,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DNSRecord")
 		os.Exit(1)
 	}
 	if err = (&networkingk8siocontroller.IngressReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		ReconcileTimeout: reconcileTimeout,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Ingress")
 		os.Exit(1)
@@ -127,6 +166,14 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "DNSGenerator")
 		os.Exit(1)
 	}
+	if err = (&dnscontroller.DNSZoneImportReconciler{
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		ReconcileTimeout: reconcileTimeout,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DNSZoneImport")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -141,6 +188,9 @@ func main() {
 	setupLog.Info("generator registered", "generators", generator.RegistedFactories())
 	setupLog.Info("provider registered", "providers", provider.RegistedProviders())
 
+	if dryRun {
+		setupLog.Info("dry-run enabled, no changes will be applied to the DNS providers")
+	}
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
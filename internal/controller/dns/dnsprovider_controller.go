@@ -18,10 +18,14 @@ package dns
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -36,11 +40,18 @@ import (
 type DNSProviderReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// If true, orphaned records are only reported for all providers
+	DryRun bool
+	// The timeout of a reconciliation, unlimited if 0
+	ReconcileTimeout time.Duration
+	recorder         record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnsproviders,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnsproviders/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnsproviders/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -52,6 +63,13 @@ type DNSProviderReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
 func (r *DNSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// only the work of the provider is bound to the reconcile timeout, so the status is still written after the timeout
+	providerCtx := ctx
+	if r.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		providerCtx, cancel = context.WithTimeout(ctx, r.ReconcileTimeout)
+		defer cancel()
+	}
 	logger := log.FromContext(ctx)
 
 	var dnsProvider dnsv1.DNSProvider
@@ -73,7 +91,7 @@ func (r *DNSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}
 
-	_, err := provider.New(ctx, &dnsProvider.Spec)
+	iprovider, err := provider.New(providerCtx, r.Client, &dnsProvider.Spec)
 	if err != nil {
 		logger.Error(err, "unable to create provider")
 		dnsProvider.Status.Valid = false
@@ -86,16 +104,159 @@ func (r *DNSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	dnsProvider.Status.Valid = true
 	dnsProvider.Status.Message = "ok"
+	dnsProvider.Status.Capabilities = provider.GetCapabilities(iprovider)
+	dnsProvider.Status.Zones = provider.GetZones(iprovider)
+	gcInterval := dnsProvider.Spec.GCDuration()
+	if lister, ok := iprovider.(provider.IDNSRecordLister); ok {
+		gc := dnsProvider.Status.GC
+		if gc == nil || time.Since(gc.LastRunTime.Time) >= gcInterval {
+			dnsProvider.Status.GC = r.collectGarbage(providerCtx, &dnsProvider, iprovider, lister)
+		}
+	} else {
+		gcInterval = 0
+	}
 	if err := r.Status().Update(ctx, &dnsProvider); err != nil {
 		logger.Error(err, "unable to update DNSProvider status")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: gcInterval}, nil
+}
+
+// collectGarbage finds the provider records owned by this cluster which have no corresponding DNSRecord,
+// and deletes them unless the gcPolicy is Report or the owner id of this cluster is not configured
+func (r *DNSProviderReconciler) collectGarbage(ctx context.Context, dnsProvider *dnsv1.DNSProvider, iprovider provider.IDNSProvider, lister provider.IDNSRecordLister) *dnsv1.DNSProviderGCStatus {
+	logger := log.FromContext(ctx)
+	gc := &dnsv1.DNSProviderGCStatus{LastRunTime: metav1.Now()}
+	report := r.DryRun || dnsProvider.Spec.DryRun || dnsProvider.Spec.GCPolicy == dnsv1.DNSProviderGCPolicyReport
+	if !provider.IsOwnerIDConfigured() {
+		// another cluster sharing the zone may own the same records
+		report = true
+	}
+
+	callCtx, cancel, err := provider.CallContext(ctx, dnsProvider.Name, &dnsProvider.Spec)
+	if err != nil {
+		logger.Error(err, "unable to list provider records")
+		gc.Message = "unable to list provider records: " + err.Error()
+		return gc
+	}
+	records, err := lister.ListRecords(callCtx)
+	cancel()
+	if err != nil {
+		logger.Error(err, "unable to list provider records")
+		gc.Message = "unable to list provider records: " + err.Error()
+		return gc
+	}
+	zone, err := r.zoneRecords(ctx, dnsProvider)
+	if err != nil {
+		logger.Error(err, "unable to list the DNSRecords of the zone")
+		gc.Message = "unable to list the DNSRecords of the zone: " + err.Error()
+		return gc
+	}
+	for i := range records {
+		rec := &records[i]
+		if !rec.Owner.IsLocal() || rec.Owner.Retained {
+			continue
+		}
+		orphaned, err := r.isOrphaned(ctx, zone, rec)
+		if err != nil {
+			logger.Error(err, "unable to check provider record", "record", rec.Name)
+			gc.Message = "unable to check provider record " + rec.Name + ": " + err.Error()
+			continue
+		}
+		if !orphaned {
+			continue
+		}
+		gc.Orphaned++
+		message := fmt.Sprintf("orphaned record %s %s (owner %s/%s)", rec.RecordType, rec.Name, rec.Owner.Namespace, rec.Owner.Name)
+		if report {
+			logger.Info(message)
+			r.recorder.Event(dnsProvider, "Warning", "Orphaned", message)
+			continue
+		}
+		callCtx, cancel, err := provider.CallContext(ctx, dnsProvider.Name, &dnsProvider.Spec)
+		if err == nil {
+			err = iprovider.DeleteRecord(callCtx, rec.DNSRecord(), &rec.ID)
+		}
+		cancel()
+		if err != nil {
+			logger.Error(err, "unable to delete "+message)
+			r.recorder.Event(dnsProvider, "Warning", "Error", "unable to delete "+message+": "+err.Error())
+			gc.Message = "unable to delete " + message + ": " + err.Error()
+			continue
+		}
+		gc.Deleted++
+		logger.Info("deleted " + message)
+		r.recorder.Event(dnsProvider, "Normal", "Deleted", "deleted "+message)
+	}
+	if gc.Orphaned > 0 && gc.Message == "" && !provider.IsOwnerIDConfigured() {
+		gc.Message = "orphaned records are only reported until NATM_OWNER_ID is set to a unique id of this cluster"
+	}
+	return gc
+}
+
+// zoneRecords is the DNSRecords matched to the providers sharing the zone of a provider
+type zoneRecords struct {
+	// the names of the providers sharing the zone, including the provider itself
+	providers map[string]bool
+	// the record ids in the status of the DNSRecords matched to the providers
+	recordIDs map[string]bool
+}
+
+func (r *DNSProviderReconciler) zoneRecords(ctx context.Context, dnsProvider *dnsv1.DNSProvider) (*zoneRecords, error) {
+	zone := &zoneRecords{providers: map[string]bool{dnsProvider.Name: true}, recordIDs: map[string]bool{}}
+	var providerList dnsv1.DNSProviderList
+	if err := r.List(ctx, &providerList); err != nil {
+		return nil, err
+	}
+	for i := range providerList.Items {
+		if dnsProvider.SharesZone(&providerList.Items[i]) {
+			zone.providers[providerList.Items[i].Name] = true
+		}
+	}
+	var recordList dnsv1.DNSRecordList
+	if err := r.List(ctx, &recordList); err != nil {
+		return nil, err
+	}
+	for _, dnsRecord := range recordList.Items {
+		if zone.providers[dnsRecord.Status.ProviderRef.Name] && dnsRecord.Status.RecordID != "" {
+			zone.recordIDs[dnsRecord.Status.RecordID] = true
+		}
+	}
+	return zone, nil
+}
+
+// isOrphaned reports whether no DNSRecord carries the owner marker or the id of the record,
+// across all providers sharing the zone
+func (r *DNSProviderReconciler) isOrphaned(ctx context.Context, zone *zoneRecords, rec *provider.ProviderRecord) (bool, error) {
+	if zone.recordIDs[rec.ID] {
+		return false, nil
+	}
+	var dnsRecord dnsv1.DNSRecord
+	if err := r.Get(ctx, types.NamespacedName{Namespace: rec.Owner.Namespace, Name: rec.Owner.Name}, &dnsRecord); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if !dnsRecord.DeletionTimestamp.IsZero() || dnsRecord.Status.ProviderRef.Name == "" {
+		// the DNSRecord controller is still working on it
+		return false, nil
+	}
+	if !zone.providers[dnsRecord.Status.ProviderRef.Name] {
+		// the DNSRecord is moved to a provider of another zone
+		return true, nil
+	}
+	if dnsRecord.Status.Status == dnsv1.DNSRecordStatusPhaseSuccess && dnsRecord.Status.RecordID != "" {
+		// a duplicate created for the same DNSRecord
+		return true, nil
+	}
+	return false, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *DNSProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("DNSProvider")
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&dnsv1.DNSProvider{}).
 		WithEventFilter(predicate.Funcs{
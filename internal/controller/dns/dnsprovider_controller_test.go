@@ -0,0 +1,270 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+// fakeProvider is an in-memory provider listing its records
+type fakeProvider struct {
+	records      []provider.ProviderRecord
+	capabilities dnsv1.DNSProviderCapabilities
+	// the ids of the records written by the methods
+	created []string
+	updated []string
+	deleted []string
+	// if true, SearchRecord blocks until the call is cancelled like a stuck API
+	stuck bool
+}
+
+func (p *fakeProvider) find(id string) *provider.ProviderRecord {
+	for i := range p.records {
+		if p.records[i].ID == id {
+			return &p.records[i]
+		}
+	}
+	return nil
+}
+
+func (p *fakeProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (string, bool, error) {
+	if p.stuck {
+		<-ctx.Done()
+		return "", false, ctx.Err()
+	}
+	for _, record := range p.records {
+		if record.Name == rec.Spec.Name && record.RecordType == rec.Spec.RecordType {
+			return record.ID, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func (p *fakeProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (string, error) {
+	id := fmt.Sprintf("created-%d", len(p.created))
+	p.created = append(p.created, id)
+	p.records = append(p.records, provider.ProviderRecord{ID: id, Name: rec.Spec.Name, RecordType: rec.Spec.RecordType, Value: rec.Spec.Value})
+	return id, nil
+}
+
+func (p *fakeProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) error {
+	p.updated = append(p.updated, *id)
+	if record := p.find(*id); record != nil {
+		record.Value = rec.Spec.Value
+	}
+	return nil
+}
+
+func (p *fakeProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) error {
+	p.deleted = append(p.deleted, *id)
+	// a new slice, the listed records may be iterated while deleting
+	records := make([]provider.ProviderRecord, 0, len(p.records))
+	for _, record := range p.records {
+		if record.ID != *id {
+			records = append(records, record)
+		}
+	}
+	p.records = records
+	return nil
+}
+
+func (p *fakeProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	return p.find(id), nil
+}
+
+func (p *fakeProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	return p.records, nil
+}
+
+func (p *fakeProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return p.capabilities
+}
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := dnsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+// setOwnerID sets the owner id of this cluster during the test
+func setOwnerID(t *testing.T, id string) {
+	previous := config.GetConfig().Owner.ID
+	config.GetConfig().Owner.ID = id
+	t.Cleanup(func() { config.GetConfig().Owner.ID = previous })
+}
+
+func newGCTest(t *testing.T, objects ...client.Object) (*DNSProviderReconciler, *dnsv1.DNSProvider, *fakeProvider) {
+	dnsProvider := &dnsv1.DNSProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: "provider"},
+		Spec:       dnsv1.DNSProviderSpec{DomainName: "example.com", GCPolicy: dnsv1.DNSProviderGCPolicyDelete},
+	}
+	owned := func(id string, namespace string, name string) provider.ProviderRecord {
+		return provider.ProviderRecord{
+			ID:         id,
+			Name:       name + ".example.com",
+			RecordType: dnsv1.DNSRecordTypeA,
+			Value:      "1.2.3.4",
+			Owner:      provider.ParseOwnerMarker("k8s-dns-manager:" + config.GetConfig().Owner.ID + ":" + namespace + "/" + name),
+		}
+	}
+	foreign := owned("foreign", "default", "missing")
+	foreign.Owner.OwnerID = "other-cluster"
+	// kept with keepOwnerOnRetain after the DNSRecord is deleted
+	retained := owned("retained", "default", "retained")
+	retained.Owner.Retained = true
+	iprovider := &fakeProvider{records: []provider.ProviderRecord{
+		foreign,
+		retained,
+		owned("missing", "default", "missing"),
+		owned("present", "default", "present"),
+		{ID: "unmarked", Name: "unmarked.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4"},
+	}}
+	present := &dnsv1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "present"},
+		Spec:       dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "present.example.com", Value: "1.2.3.4"},
+		Status: dnsv1.DNSRecordStatus{
+			ProviderRef: dnsv1.NamespacedName{Name: dnsProvider.Name},
+			RecordID:    "present",
+			Status:      dnsv1.DNSRecordStatusPhaseSuccess,
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(append(objects, present)...).Build()
+	r := &DNSProviderReconciler{Client: c, recorder: record.NewFakeRecorder(100)}
+	return r, dnsProvider, iprovider
+}
+
+func TestCollectGarbage(t *testing.T) {
+	setOwnerID(t, "cluster-a")
+	r, dnsProvider, iprovider := newGCTest(t)
+
+	gc := r.collectGarbage(context.Background(), dnsProvider, iprovider, iprovider)
+	// only the record owned by this cluster without DNSRecord is deleted
+	if gc.Orphaned != 1 || gc.Deleted != 1 || gc.Message != "" {
+		t.Fatalf("unexpected gc status %+v", gc)
+	}
+	if len(iprovider.deleted) != 1 || iprovider.deleted[0] != "missing" {
+		t.Fatalf("unexpected deleted records %v", iprovider.deleted)
+	}
+
+	r, dnsProvider, iprovider = newGCTest(t)
+	dnsProvider.Spec.GCPolicy = dnsv1.DNSProviderGCPolicyReport
+	gc = r.collectGarbage(context.Background(), dnsProvider, iprovider, iprovider)
+	if gc.Orphaned != 1 || gc.Deleted != 0 || len(iprovider.deleted) != 0 {
+		t.Fatalf("records are deleted with gcPolicy Report: %+v %v", gc, iprovider.deleted)
+	}
+}
+
+func TestCollectGarbageDefaultOwnerID(t *testing.T) {
+	setOwnerID(t, config.DefaultOwnerID)
+	r, dnsProvider, iprovider := newGCTest(t)
+
+	// the default owner id may be shared by another cluster, so nothing is deleted
+	gc := r.collectGarbage(context.Background(), dnsProvider, iprovider, iprovider)
+	if gc.Orphaned != 1 || gc.Deleted != 0 || gc.Message == "" || len(iprovider.deleted) != 0 {
+		t.Fatalf("records are deleted with the default owner id: %+v %v", gc, iprovider.deleted)
+	}
+}
+
+func TestIsOrphaned(t *testing.T) {
+	setOwnerID(t, "cluster-a")
+	other := &dnsv1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "moved"},
+		Status:     dnsv1.DNSRecordStatus{ProviderRef: dnsv1.NamespacedName{Name: "other-provider"}},
+	}
+	pending := &dnsv1.DNSRecord{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pending"}}
+	r, dnsProvider, _ := newGCTest(t, other, pending)
+
+	cases := []struct {
+		id       string
+		name     string
+		orphaned bool
+	}{
+		{"missing", "missing", true},
+		{"present", "present", false},
+		// a duplicate created for the same DNSRecord
+		{"duplicate", "present", true},
+		{"moved", "moved", true},
+		// not yet matched to a provider
+		{"pending", "pending", false},
+	}
+	zone, err := r.zoneRecords(context.Background(), dnsProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		rec := &provider.ProviderRecord{ID: c.id, Owner: &provider.RecordOwner{OwnerID: "cluster-a", Namespace: "default", Name: c.name}}
+		orphaned, err := r.isOrphaned(context.Background(), zone, rec)
+		if err != nil || orphaned != c.orphaned {
+			t.Errorf("isOrphaned(%s) = %v %v, want %v", c.id, orphaned, err, c.orphaned)
+		}
+	}
+}
+
+func TestCollectGarbageSharedZone(t *testing.T) {
+	setOwnerID(t, "cluster-a")
+	// the providers split the records of the zone by selector
+	sibling := &dnsv1.DNSProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: "sibling"},
+		Spec: dnsv1.DNSProviderSpec{
+			DomainName: "example.com",
+			Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}},
+		},
+	}
+	siblingRecord := &dnsv1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sibling", Labels: map[string]string{"team": "b"}},
+		Spec:       dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "sibling.example.com", Value: "1.2.3.4"},
+		Status: dnsv1.DNSRecordStatus{
+			ProviderRef: dnsv1.NamespacedName{Name: sibling.Name},
+			RecordID:    "sibling",
+			Status:      dnsv1.DNSRecordStatusPhaseSuccess,
+		},
+	}
+	// another zone, whose records are orphaned once the DNSRecord is moved to it
+	otherZone := &dnsv1.DNSProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-zone"},
+		Spec:       dnsv1.DNSProviderSpec{DomainName: "example.org"},
+	}
+	moved := &dnsv1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "moved"},
+		Status: dnsv1.DNSRecordStatus{
+			ProviderRef: dnsv1.NamespacedName{Name: otherZone.Name},
+			RecordID:    "moved-elsewhere",
+			Status:      dnsv1.DNSRecordStatusPhaseSuccess,
+		},
+	}
+	r, dnsProvider, iprovider := newGCTest(t, sibling, siblingRecord, otherZone, moved)
+	dnsProvider.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+	owner := func(name string) *provider.RecordOwner {
+		return &provider.RecordOwner{OwnerID: "cluster-a", Namespace: "default", Name: name}
+	}
+	iprovider.records = append(iprovider.records,
+		provider.ProviderRecord{ID: "sibling", Name: "sibling.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4", Owner: owner("sibling")},
+		// the marker names another DNSRecord, but the record id is carried by the DNSRecord of the sibling
+		provider.ProviderRecord{ID: "sibling", Name: "sibling.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4", Owner: owner("renamed")},
+		provider.ProviderRecord{ID: "moved", Name: "moved.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4", Owner: owner("moved")},
+	)
+
+	gc := r.collectGarbage(context.Background(), dnsProvider, iprovider, iprovider)
+	if gc.Orphaned != 2 || gc.Deleted != 2 || gc.Message != "" {
+		t.Fatalf("unexpected gc status %+v", gc)
+	}
+	if len(iprovider.deleted) != 2 || iprovider.deleted[0] != "missing" || iprovider.deleted[1] != "moved" {
+		t.Fatalf("the records of the sibling provider are deleted: %v", iprovider.deleted)
+	}
+}
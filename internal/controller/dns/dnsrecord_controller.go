@@ -18,36 +18,53 @@ package dns
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
 	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/health"
 	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
 	"github.com/xzzpig/k8s-dns-manager/util"
 )
 
+// The longest wait for a token of the rate limit of the provider in the reconciliation, longer waits are requeued
+const maxRateLimitWait = 5 * time.Second
+
 // DNSRecordReconciler reconciles a DNSRecord object
 type DNSRecordReconciler struct {
 	client.Client
-	Scheme   *runtime.Scheme
-	recorder record.EventRecorder
+	Scheme *runtime.Scheme
+	// If true, the changes are only planned and reported for all providers
+	DryRun bool
+	// The count of DNSRecords reconciled at the same time, the changes of them can be batched by batch providers
+	MaxConcurrentReconciles int
+	// The timeout of a reconciliation, unlimited if 0
+	ReconcileTimeout time.Duration
+	recorder         record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnsrecords,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnsrecords/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnsrecords/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=endpoints,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -59,6 +76,14 @@ type DNSRecordReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
 func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// the work of the provider and the health probes is bound to the reconcile timeout, so a stuck provider API
+	// does not pin the worker, while the status and finalizers are still written with ctx after the timeout
+	providerCtx := ctx
+	if r.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		providerCtx, cancel = context.WithTimeout(ctx, r.ReconcileTimeout)
+		defer cancel()
+	}
 	logger := log.FromContext(ctx)
 
 	var dnsRecord dnsv1.DNSRecord
@@ -99,6 +124,25 @@ func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}
 
+	// failed backs off the retries of the record by the class of the error returned by the provider
+	failed := func(message string, err error) (ctrl.Result, error) {
+		showResult(message, err)
+		status.Status = dnsv1.DNSRecordStatusPhaseFailed
+		class, retryAfter := provider.ClassifyError(err)
+		retry := status.Retry
+		if retry == nil {
+			retry = &dnsv1.DNSRecordRetryStatus{}
+		}
+		delay := provider.RetryDelay(class, retryAfter, retry.Attempts)
+		status.Retry = &dnsv1.DNSRecordRetryStatus{
+			Attempts:      retry.Attempts + 1,
+			Reason:        string(class),
+			NextRetryTime: metav1.NewTime(time.Now().Add(delay).Truncate(time.Second)),
+		}
+		logger.Info("retry later", "reason", class, "attempts", status.Retry.Attempts, "delay", delay)
+		return ctrl.Result{RequeueAfter: delay}, nil
+	}
+
 	if status.Status == "" {
 		status.Status = dnsv1.DNSRecordStatusPhasePending
 		showResult("start reconciling", nil)
@@ -117,7 +161,7 @@ func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			return ctrl.Result{}, err
 		}
 		for _, provider := range providerList.Items {
-			if dnsRecord.Match(&provider.Spec) {
+			if dnsRecord.Match(&provider) {
 				status.ProviderRef.Name = provider.Name
 				status.ProviderRef.Namespace = provider.Namespace
 				showResult("provider found for "+dnsRecord.Spec.Name+" provider: "+provider.Name, nil)
@@ -142,7 +186,7 @@ func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{Requeue: true}, nil
 	}
 
-	if !dnsRecord.Match(&dnsProvider.Spec) {
+	if !dnsRecord.Match(&dnsProvider) {
 		status.ProviderRef.Namespace = ""
 		status.ProviderRef.Name = ""
 		showResult("provider not match for "+dnsRecord.Spec.Name, errors.New("provider mismatch"))
@@ -154,7 +198,7 @@ func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{RequeueAfter: time.Minute}, nil
 	}
 
-	iprovider, err := provider.New(ctx, &dnsProvider.Spec)
+	iprovider, err := provider.New(providerCtx, r.Client, &dnsProvider.Spec)
 	if err != nil {
 		status.ProviderRef.Namespace = ""
 		status.ProviderRef.Name = ""
@@ -167,60 +211,158 @@ func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	// the default ttl is checked against the ttl bounds of the provider as well
 	if dnsRecord.Spec.TTL == nil {
 		dnsRecord.Spec.TTL = &config.GetConfig().Default.Record.TTL
 	}
 
+	if err := provider.CheckRecord(iprovider, &dnsRecord); err != nil && dnsRecord.DeletionTimestamp.IsZero() {
+		// retrying does not help until the spec is changed
+		status.Status = dnsv1.DNSRecordStatusPhaseFailed
+		showResult("unsupported record: ", err)
+		return ctrl.Result{}, nil
+	}
+
 	if status.Status != dnsv1.DNSRecordStatusPhaseSyncing {
 		status.Status = dnsv1.DNSRecordStatusPhaseSyncing
 		logger.Info("start syncing " + dnsRecord.Spec.Name)
 		return ctrl.Result{Requeue: true}, nil
 	}
 
-	recordID, ok, err := iprovider.SearchRecord(ctx, &dnsRecord)
+	// each call to the provider takes a token, the worker is not held if the tokens are not available soon
+	if delay := provider.RateLimitDelay(dnsProvider.Name, dnsProvider.Spec.RateLimit); delay > maxRateLimitWait {
+		logger.Info("rate limited by provider", "delay", delay)
+		status.Message = "wait for the rate limit of provider " + dnsProvider.Name
+		return ctrl.Result{RequeueAfter: delay}, nil
+	}
+
+	// the interval to sync the record again, the health of the target is probed on every sync
+	var resync time.Duration
+	if check := dnsRecord.Spec.HealthCheck; check != nil && dnsRecord.DeletionTimestamp.IsZero() {
+		resync = check.IntervalDuration()
+		if status.Health == nil {
+			status.Health = &dnsv1.DNSRecordHealthStatus{Healthy: true}
+		}
+		probeErr := health.Probe(providerCtx, r.Client, &dnsRecord)
+		if health.Observe(status.Health, check, probeErr, time.Now()) {
+			if status.Health.Healthy {
+				logger.Info("target is healthy")
+				r.recorder.Event(&dnsRecord, "Normal", "Healthy", "target of "+dnsRecord.Spec.Name+" is healthy")
+			} else {
+				logger.Info("target is unhealthy", "error", probeErr.Error())
+				r.recorder.Event(&dnsRecord, "Warning", "Unhealthy", "target of "+dnsRecord.Spec.Name+" is unhealthy: "+probeErr.Error())
+			}
+		}
+		if !status.Health.Healthy && check.FallbackValue != "" {
+			dnsRecord.Spec.Value = check.FallbackValue
+		}
+	} else if dnsRecord.Spec.HealthCheck == nil {
+		status.Health = nil
+	}
+
+	callCtx, cancel, err := provider.CallContext(providerCtx, dnsProvider.Name, &dnsProvider.Spec)
 	if err != nil {
-		showResult("unable to search record", err)
-		status.Status = dnsv1.DNSRecordStatusPhaseFailed
-		return ctrl.Result{RequeueAfter: time.Minute}, nil
+		return failed("unable to search record", err)
 	}
-	if dnsRecord.DeletionTimestamp.IsZero() {
+	recordID, ok, err := iprovider.SearchRecord(callCtx, &dnsRecord)
+	cancel()
+	if err != nil {
+		return failed("unable to search record", err)
+	}
+
+	if r.DryRun || dnsProvider.Spec.DryRun {
+		plan, diff := r.plan(providerCtx, iprovider, &dnsProvider, &dnsRecord, recordID, ok)
+		logger.Info("dry-run plan", "plan", plan, "diff", diff)
+		status.Status = dnsv1.DNSRecordStatusPhasePlanned
+		if status.Plan != plan {
+			status.Plan = plan
+			showResult("dry-run: "+plan, nil)
+		}
+		// the finalizer is kept on deletion, so the planned deletion stays visible and is applied once dry-run is turned off.
+		// The live state or the dry-run setting of the provider may change
+		if resync == 0 || resync > time.Minute {
+			resync = time.Minute
+		}
+		return ctrl.Result{RequeueAfter: resync}, nil
+	}
+	status.Plan = ""
+
+	// apply applies the change, which is batched with the changes of other DNSRecords by batch providers
+	apply := func(change *provider.RecordChange) error {
+		if r.MaxConcurrentReconciles <= 1 {
+			// no other change can be pending, waiting for the batch window would only delay the change
+			return provider.ApplyChange(providerCtx, dnsProvider.Name, &dnsProvider.Spec, iprovider, change)
+		}
+		return provider.ApplyChanges(providerCtx, dnsProvider.Name, &dnsProvider.Spec, iprovider, change)
+	}
+
+	if isWithdrawn(&dnsRecord) {
 		if ok {
-			if err := iprovider.UpdateRecord(ctx, &dnsRecord, &recordID); err != nil {
-				showResult("unable to update record", err)
-				status.Status = dnsv1.DNSRecordStatusPhaseFailed
-				return ctrl.Result{RequeueAfter: time.Minute}, nil
+			if err := apply(&provider.RecordChange{Action: provider.ChangeActionDelete, Record: &dnsRecord, ID: recordID}); err != nil {
+				return failed("unable to withdraw record", err)
+			}
+		}
+		status.Status = dnsv1.DNSRecordStatusPhaseWithdrawn
+		status.Retry = nil
+		status.RecordID = ""
+		showResult("withdrawn while the target is unhealthy", nil)
+		// the finalizer is kept, the record is restored once the target recovers
+		return ctrl.Result{RequeueAfter: resync}, nil
+	} else if dnsRecord.DeletionTimestamp.IsZero() {
+		if ok {
+			if err := apply(&provider.RecordChange{Action: provider.ChangeActionUpdate, Record: &dnsRecord, ID: recordID}); err != nil {
+				return failed("unable to update record", err)
 			}
 			status.Status = dnsv1.DNSRecordStatusPhaseSuccess
+			status.Retry = nil
 			showResult("synced", nil)
 			if err := r.addFinalizer(ctx, dnsRecordOrigin); err != nil {
 				logger.Error(err, "unable to add finalizer")
 				return ctrl.Result{}, err
 			}
-			return ctrl.Result{}, nil
+			return ctrl.Result{RequeueAfter: resync}, nil
 		} else {
-			recordID, err := iprovider.CreateRecord(ctx, &dnsRecord)
-			if err != nil {
-				showResult("unable to create record", err)
-				status.Status = dnsv1.DNSRecordStatusPhaseFailed
-				return ctrl.Result{RequeueAfter: time.Minute}, nil
+			change := &provider.RecordChange{Action: provider.ChangeActionCreate, Record: &dnsRecord}
+			if err := apply(change); err != nil {
+				// the record may be created even if the create failed, e.g. without its owner marker, it's adopted by the id
+				if change.ID != "" {
+					status.RecordID = change.ID
+				}
+				return failed("unable to create record", err)
 			}
 			status.Status = dnsv1.DNSRecordStatusPhaseSuccess
-			status.RecordID = recordID
+			status.Retry = nil
+			status.RecordID = change.ID
 			showResult("synced", nil)
 			if err := r.addFinalizer(ctx, dnsRecordOrigin); err != nil {
 				logger.Error(err, "unable to add finalizer")
 				return ctrl.Result{}, err
 			}
-			return ctrl.Result{}, nil
+			return ctrl.Result{RequeueAfter: resync}, nil
 		}
 	} else {
-		if ok {
-			if err := iprovider.DeleteRecord(ctx, &dnsRecord, &recordID); err != nil {
-				showResult("unable to delete record", err)
-				status.Status = dnsv1.DNSRecordStatusPhaseFailed
-				return ctrl.Result{RequeueAfter: time.Minute}, nil
+		if ok && dnsRecord.Spec.GetDeletionPolicy(&dnsProvider.Spec) == dnsv1.DNSRecordDeletionPolicyRetain {
+			ownership := provider.OwnershipReleased
+			if dnsProvider.Spec.KeepOwnerOnRetain {
+				ownership = provider.OwnershipRetained
+			}
+			if err := apply(&provider.RecordChange{Action: provider.ChangeActionUpdate, Record: provider.WithOwnership(&dnsRecord, ownership), ID: recordID}); err != nil {
+				return failed("unable to release record", err)
 			}
 			status.Status = dnsv1.DNSRecordStatusPhaseSuccess
+			status.Retry = nil
+			showResult("retained", nil)
+			if err := r.removeFinalizer(ctx, dnsRecordOrigin); err != nil {
+				logger.Error(err, "unable to remove finalizer")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		} else if ok {
+			if err := apply(&provider.RecordChange{Action: provider.ChangeActionDelete, Record: &dnsRecord, ID: recordID}); err != nil {
+				return failed("unable to delete record", err)
+			}
+			status.Status = dnsv1.DNSRecordStatusPhaseSuccess
+			status.Retry = nil
 			showResult("deleted", nil)
 			if err := r.removeFinalizer(ctx, dnsRecordOrigin); err != nil {
 				logger.Error(err, "unable to remove finalizer")
@@ -229,6 +371,7 @@ func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			return ctrl.Result{}, nil
 		} else {
 			status.Status = dnsv1.DNSRecordStatusPhaseSuccess
+			status.Retry = nil
 			showResult("deleted", nil)
 			if err := r.removeFinalizer(ctx, dnsRecordOrigin); err != nil {
 				logger.Error(err, "unable to remove finalizer")
@@ -239,6 +382,106 @@ func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 }
 
+// plan describes the change which would be applied to the provider, and the diff against the live record if known
+func (r *DNSRecordReconciler) plan(ctx context.Context, iprovider provider.IDNSProvider, dnsProvider *dnsv1.DNSProvider, dnsRecord *dnsv1.DNSRecord, recordID string, exists bool) (plan string, diff string) {
+	spec := &dnsRecord.Spec
+	if !dnsRecord.DeletionTimestamp.IsZero() {
+		if !exists {
+			return "nothing to delete", ""
+		}
+		if spec.GetDeletionPolicy(&dnsProvider.Spec) == dnsv1.DNSRecordDeletionPolicyRetain {
+			return fmt.Sprintf("retain %s %s (id %s)", spec.RecordType, spec.Name, recordID), ""
+		}
+		return fmt.Sprintf("delete %s %s (id %s)", spec.RecordType, spec.Name, recordID), ""
+	}
+	if isWithdrawn(dnsRecord) {
+		if !exists {
+			return "nothing to withdraw", ""
+		}
+		return fmt.Sprintf("withdraw %s %s (id %s)", spec.RecordType, spec.Name, recordID), ""
+	}
+	if !exists {
+		return fmt.Sprintf("create %s %s %s", spec.RecordType, spec.Name, spec.Value), ""
+	}
+	getter, ok := iprovider.(provider.IDNSRecordGetter)
+	if !ok {
+		return fmt.Sprintf("update %s %s %s (id %s)", spec.RecordType, spec.Name, spec.Value, recordID), "unknown"
+	}
+	callCtx, cancel, err := provider.CallContext(ctx, dnsProvider.Name, &dnsProvider.Spec)
+	defer cancel()
+	if err != nil {
+		return fmt.Sprintf("update %s %s %s (id %s)", spec.RecordType, spec.Name, spec.Value, recordID), "unknown"
+	}
+	current, err := getter.GetRecord(callCtx, recordID)
+	if err != nil || current == nil {
+		return fmt.Sprintf("update %s %s %s (id %s)", spec.RecordType, spec.Name, spec.Value, recordID), "unknown"
+	}
+	var diffs []string
+	if current.Name != spec.Name {
+		diffs = append(diffs, fmt.Sprintf("name %s -> %s", current.Name, spec.Name))
+	}
+	if current.RecordType != spec.RecordType {
+		diffs = append(diffs, fmt.Sprintf("type %s -> %s", current.RecordType, spec.RecordType))
+	}
+	if current.Value != spec.Value {
+		diffs = append(diffs, fmt.Sprintf("value %s -> %s", current.Value, spec.Value))
+	}
+	// a ttl of 0 is not stored by the provider
+	if current.TTL != 0 && spec.TTL != nil && current.TTL != *spec.TTL {
+		diffs = append(diffs, fmt.Sprintf("ttl %d -> %d", current.TTL, *spec.TTL))
+	}
+	if !reflect.DeepEqual(current.Routing, spec.Routing) {
+		diffs = append(diffs, fmt.Sprintf("routing %s -> %s", routingString(current.Routing), routingString(spec.Routing)))
+	}
+	if capabilities := provider.GetCapabilities(iprovider); capabilities != nil && capabilities.Ownership {
+		if owner := provider.ParseOwnerMarker(provider.OwnerMarker(dnsRecord)); !reflect.DeepEqual(current.Owner, owner) {
+			diffs = append(diffs, fmt.Sprintf("owner %s -> %s", ownerString(current.Owner), ownerString(owner)))
+		}
+	}
+	// only the annotations reported by the provider are compared, the others have defaults of the provider spec
+	keys := make([]string, 0, len(current.Annotations))
+	for key := range current.Annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value, ok := dnsRecord.Annotations[key]; ok && value != current.Annotations[key] {
+			diffs = append(diffs, fmt.Sprintf("%s %s -> %s", key, current.Annotations[key], value))
+		}
+	}
+	if len(diffs) == 0 {
+		return fmt.Sprintf("no change to %s %s (id %s)", spec.RecordType, spec.Name, recordID), ""
+	}
+	diff = strings.Join(diffs, ", ")
+	return fmt.Sprintf("update %s %s (id %s): %s", spec.RecordType, spec.Name, recordID, diff), diff
+}
+
+func routingString(routing *dnsv1.DNSRecordRouting) string {
+	if routing == nil {
+		return "none"
+	}
+	data, _ := json.Marshal(routing)
+	return string(data)
+}
+
+func ownerString(owner *provider.RecordOwner) string {
+	if owner == nil {
+		return "none"
+	}
+	marker := owner.OwnerID + ":" + owner.Namespace + "/" + owner.Name
+	if owner.Retained {
+		marker += " (retained)"
+	}
+	return marker
+}
+
+// isWithdrawn returns true if the record should be removed from the provider while its target is unhealthy,
+// the records with a fallback value are swapped instead
+func isWithdrawn(dnsRecord *dnsv1.DNSRecord) bool {
+	check, health := dnsRecord.Spec.HealthCheck, dnsRecord.Status.Health
+	return dnsRecord.DeletionTimestamp.IsZero() && check != nil && check.FallbackValue == "" && health != nil && !health.Healthy
+}
+
 func (r *DNSRecordReconciler) addFinalizer(ctx context.Context, dnsRecord *dnsv1.DNSRecord) error {
 	if !util.ContainsString(dnsRecord.GetFinalizers(), util.FinalizerName) {
 		dnsRecord.SetFinalizers(append(dnsRecord.GetFinalizers(), util.FinalizerName))
@@ -260,6 +503,7 @@ func (r *DNSRecordReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.recorder = mgr.GetEventRecorderFor("DNSRecord")
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&dnsv1.DNSRecord{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		WithEventFilter(predicate.Funcs{
 			UpdateFunc: func(e event.UpdateEvent) bool {
 				oldGeneration := e.ObjectOld.GetGeneration()
@@ -0,0 +1,235 @@
+package dns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+// ctxClient rejects the writes with a done context like the client of the API server
+type ctxClient struct {
+	client.Client
+}
+
+func (c ctxClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c ctxClient) Status() client.StatusWriter {
+	return ctxStatusWriter{c.Client.Status()}
+}
+
+type ctxStatusWriter struct {
+	client.StatusWriter
+}
+
+func (w ctxStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+// newRecordTest creates a reconciler of the DNSRecords matched to the provider, which uses testProvider
+func newRecordTest(t *testing.T, dnsProvider *dnsv1.DNSProvider, objects ...client.Object) (*DNSRecordReconciler, client.Client) {
+	dnsProvider.Spec.DomainName = "example.com"
+	dnsProvider.Spec.ProviderType = testProviderType
+	dnsProvider.Status.Valid = true
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(append(objects, dnsProvider)...).Build()
+	// the writes fail with a done context like the client of the API server
+	r := &DNSRecordReconciler{Client: ctxClient{c}, recorder: record.NewFakeRecorder(100)}
+	return r, c
+}
+
+// syncingRecord returns a DNSRecord matched to the provider of newRecordTest
+func syncingRecord(name string, value string) *dnsv1.DNSRecord {
+	ttl := 600
+	return &dnsv1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: name + ".example.com", Value: value, TTL: &ttl},
+		Status: dnsv1.DNSRecordStatus{
+			ProviderRef: dnsv1.NamespacedName{Name: "provider"},
+			Status:      dnsv1.DNSRecordStatusPhaseSyncing,
+		},
+	}
+}
+
+// reconcileRecord reconciles the DNSRecord until it's synced or planned, and returns it
+func reconcileRecord(t *testing.T, r *DNSRecordReconciler, c client.Client, name string) *dnsv1.DNSRecord {
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+	var dnsRecord dnsv1.DNSRecord
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Get(ctx, req.NamespacedName, &dnsRecord); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			t.Fatal(err)
+		}
+		if dnsRecord.Status.Status != dnsv1.DNSRecordStatusPhaseSyncing {
+			break
+		}
+	}
+	return &dnsRecord
+}
+
+func TestDNSRecordReconcileTimeout(t *testing.T) {
+	testProvider = &fakeProvider{stuck: true}
+	r, c := newRecordTest(t, &dnsv1.DNSProvider{ObjectMeta: metav1.ObjectMeta{Name: "provider"}}, syncingRecord("www", "1.2.3.4"))
+	r.ReconcileTimeout = 100 * time.Millisecond
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "www"}})
+	if err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("expected the record to be retried later: %+v %v", result, err)
+	}
+	// the failure is written after the provider call times out
+	var got dnsv1.DNSRecord
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "www"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.Status != dnsv1.DNSRecordStatusPhaseFailed || got.Status.Retry == nil || got.Status.Retry.Attempts != 1 {
+		t.Fatalf("the failure is not written: %+v", got.Status)
+	}
+}
+
+func TestDNSRecordDryRun(t *testing.T) {
+	setOwnerID(t, "cluster-a")
+	owner := func(name string) *provider.RecordOwner {
+		return &provider.RecordOwner{OwnerID: "cluster-a", Namespace: "default", Name: name}
+	}
+	testProvider = &fakeProvider{
+		capabilities: dnsv1.DNSProviderCapabilities{Ownership: true},
+		records: []provider.ProviderRecord{
+			{ID: "1", Name: "www.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4", TTL: 300, Owner: owner("www")},
+			{ID: "2", Name: "unmarked.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4", TTL: 600},
+			{ID: "3", Name: "same.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4", TTL: 600, Owner: owner("same")},
+		},
+	}
+	www := syncingRecord("www", "1.2.3.4")
+	www.Finalizers = []string{util.FinalizerName}
+	dnsProvider := &dnsv1.DNSProvider{ObjectMeta: metav1.ObjectMeta{Name: "provider"}, Spec: dnsv1.DNSProviderSpec{DryRun: true}}
+	r, c := newRecordTest(t, dnsProvider, www, syncingRecord("unmarked", "1.2.3.4"), syncingRecord("same", "1.2.3.4"), syncingRecord("new", "5.6.7.8"))
+
+	cases := []struct {
+		name string
+		plan string
+	}{
+		{"www", "update A www.example.com (id 1): ttl 300 -> 600"},
+		{"unmarked", "update A unmarked.example.com (id 2): owner none -> cluster-a:default/unmarked"},
+		{"same", "no change to A same.example.com (id 3)"},
+		{"new", "create A new.example.com 5.6.7.8"},
+	}
+	for _, tc := range cases {
+		got := reconcileRecord(t, r, c, tc.name)
+		if got.Status.Status != dnsv1.DNSRecordStatusPhasePlanned || got.Status.Plan != tc.plan {
+			t.Errorf("%s: unexpected status %s %q, want plan %q", tc.name, got.Status.Status, got.Status.Plan, tc.plan)
+		}
+	}
+
+	// the planned deletion stays visible until dry-run is turned off
+	if err := c.Delete(context.Background(), www); err != nil {
+		t.Fatal(err)
+	}
+	got := reconcileRecord(t, r, c, "www")
+	if got == nil || got.Status.Plan != "delete A www.example.com (id 1)" || !util.ContainsString(got.Finalizers, util.FinalizerName) {
+		t.Fatalf("the planned deletion is not kept: %+v", got)
+	}
+
+	if len(testProvider.created) != 0 || len(testProvider.updated) != 0 || len(testProvider.deleted) != 0 {
+		t.Fatalf("dry-run writes to the provider: created=%v updated=%v deleted=%v", testProvider.created, testProvider.updated, testProvider.deleted)
+	}
+}
+
+func TestDNSRecordDefaultTTLCheck(t *testing.T) {
+	defaultTTL := config.GetConfig().Default.Record.TTL
+	testProvider = &fakeProvider{capabilities: dnsv1.DNSProviderCapabilities{MinTTL: defaultTTL + 1}}
+	www := syncingRecord("www", "1.2.3.4")
+	www.Spec.TTL = nil
+	r, c := newRecordTest(t, &dnsv1.DNSProvider{ObjectMeta: metav1.ObjectMeta{Name: "provider"}}, www)
+
+	// the default ttl is below the min ttl of the provider
+	got := reconcileRecord(t, r, c, "www")
+	if got.Status.Status != dnsv1.DNSRecordStatusPhaseFailed || len(testProvider.created) != 0 {
+		t.Fatalf("expected the default ttl to be rejected: %+v", got.Status)
+	}
+}
+
+func TestDNSRecordHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	healthCheck := func(fallback string) *dnsv1.DNSRecordHealthCheck {
+		return &dnsv1.DNSRecordHealthCheck{
+			Endpoints:          &dnsv1.DNSRecordEndpointsHealthCheck{Services: []string{"web"}},
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+			FallbackValue:      fallback,
+		}
+	}
+	www := syncingRecord("www", "1.2.3.4")
+	www.Spec.HealthCheck = healthCheck("")
+	fallback := syncingRecord("fallback", "1.2.3.4")
+	fallback.Spec.HealthCheck = healthCheck("5.6.7.8")
+	testProvider = &fakeProvider{records: []provider.ProviderRecord{
+		{ID: "1", Name: "www.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4"},
+		{ID: "2", Name: "fallback.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4"},
+	}}
+	r, c := newRecordTest(t, &dnsv1.DNSProvider{ObjectMeta: metav1.ObjectMeta{Name: "provider"}}, www, fallback)
+
+	// no ready endpoint of the target, the record is withdrawn and the fallback value is published
+	got := reconcileRecord(t, r, c, "www")
+	if got.Status.Status != dnsv1.DNSRecordStatusPhaseWithdrawn || got.Status.RecordID != "" || got.Status.Health.Healthy {
+		t.Fatalf("expected the record to be withdrawn: %+v", got.Status)
+	}
+	if testProvider.find("1") != nil || len(testProvider.deleted) != 1 || testProvider.deleted[0] != "1" {
+		t.Fatalf("expected the record to be deleted from the provider: %v", testProvider.deleted)
+	}
+	got = reconcileRecord(t, r, c, "fallback")
+	if got.Status.Status != dnsv1.DNSRecordStatusPhaseSuccess || got.Status.Health.Healthy || got.Spec.Value != "1.2.3.4" {
+		t.Fatalf("expected the fallback value to be synced: %+v %+v", got.Spec, got.Status)
+	}
+	if record := testProvider.find("2"); record == nil || record.Value != "5.6.7.8" {
+		t.Fatalf("expected the fallback value to be published: %+v", record)
+	}
+
+	// the target recovers, the record is restored and the value is swapped back
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	}
+	if err := c.Create(ctx, endpoints); err != nil {
+		t.Fatal(err)
+	}
+	got = reconcileRecord(t, r, c, "www")
+	if got.Status.Status != dnsv1.DNSRecordStatusPhaseSuccess || got.Status.RecordID == "" || !got.Status.Health.Healthy {
+		t.Fatalf("expected the record to be restored: %+v", got.Status)
+	}
+	if record := testProvider.find(got.Status.RecordID); record == nil || record.Value != "1.2.3.4" {
+		t.Fatalf("expected the record to be created again: %+v", testProvider.records)
+	}
+	got = reconcileRecord(t, r, c, "fallback")
+	if got.Status.Status != dnsv1.DNSRecordStatusPhaseSuccess || !got.Status.Health.Healthy {
+		t.Fatalf("expected the fallback record to be healthy: %+v", got.Status)
+	}
+	if record := testProvider.find("2"); record == nil || record.Value != "1.2.3.4" {
+		t.Fatalf("expected the value to be swapped back: %+v", record)
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+const AnnotationKeyImportedBy = "dns.xzzpig.com/imported-by"
+
+var supportedRecordTypes = []dnsv1.DNSRecordType{
+	dnsv1.DNSRecordTypeA,
+	dnsv1.DNSRecordTypeCNAME,
+	dnsv1.DNSRecordTypeTXT,
+	dnsv1.DNSRecordTypeMX,
+	dnsv1.DNSRecordTypeSRV,
+	dnsv1.DNSRecordTypeAAAA,
+	dnsv1.DNSRecordTypeNS,
+	dnsv1.DNSRecordTypeCAA,
+}
+
+var ErrProviderNotListable = errors.New("provider does not support listing records")
+
+// DNSZoneImportReconciler reconciles a DNSZoneImport object
+type DNSZoneImportReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// The timeout of a reconciliation, unlimited if 0
+	ReconcileTimeout time.Duration
+	recorder         record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnszoneimports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnszoneimports/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dns.xzzpig.com,resources=dnszoneimports/finalizers,verbs=update
+
+// Reconcile imports the records of the referenced DNSProvider as DNSRecords in the namespace of the DNSZoneImport.
+// The imported DNSRecords have status.recordID pre-populated, so the DNSRecord controller adopts the existing
+// records instead of creating duplicates.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
+func (r *DNSZoneImportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// only the work of the provider is bound to the reconcile timeout, so the status is still written after the timeout
+	providerCtx := ctx
+	if r.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		providerCtx, cancel = context.WithTimeout(ctx, r.ReconcileTimeout)
+		defer cancel()
+	}
+	logger := log.FromContext(ctx)
+
+	var zoneImport dnsv1.DNSZoneImport
+	if err := r.Get(ctx, req.NamespacedName, &zoneImport); err != nil {
+		logger.Error(err, "unable to fetch DNSZoneImport")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	logger = logger.WithValues("provider", zoneImport.Spec.ProviderRef)
+
+	showResult := func(message string, err error) {
+		if err != nil {
+			logger.Error(err, message)
+			zoneImport.Status.Valid = false
+			zoneImport.Status.Message = message + err.Error()
+			r.recorder.Event(&zoneImport, "Warning", "Error", message+err.Error())
+		} else {
+			logger.Info(message)
+			zoneImport.Status.Valid = true
+			zoneImport.Status.Message = message
+			r.recorder.Event(&zoneImport, "Normal", "Info", message)
+		}
+		if err := r.Status().Update(ctx, &zoneImport); err != nil {
+			logger.Error(err, "unable to update DNSZoneImport status")
+		}
+	}
+
+	var nameRegex *regexp.Regexp
+	if zoneImport.Spec.NameRegex != "" {
+		regex, err := regexp.Compile(zoneImport.Spec.NameRegex)
+		if err != nil {
+			showResult("invalid nameRegex: ", err)
+			return ctrl.Result{}, nil
+		}
+		nameRegex = regex
+	}
+
+	var dnsProvider dnsv1.DNSProvider
+	if err := r.Get(ctx, types.NamespacedName{Name: zoneImport.Spec.ProviderRef}, &dnsProvider); err != nil {
+		showResult("unable to fetch DNSProvider: ", err)
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	if !dnsProvider.Status.Valid {
+		showResult("wait for provider to be valid: ", errors.New(dnsProvider.Status.Message))
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	iprovider, err := provider.New(providerCtx, r.Client, &dnsProvider.Spec)
+	if err != nil {
+		showResult("unable to create provider: ", err)
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	lister, ok := iprovider.(provider.IDNSRecordLister)
+	if !ok {
+		showResult("unable to import: ", ErrProviderNotListable)
+		return ctrl.Result{}, nil
+	}
+
+	callCtx, cancel, err := provider.CallContext(providerCtx, dnsProvider.Name, &dnsProvider.Spec)
+	if err != nil {
+		showResult("unable to list provider records: ", err)
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	records, err := lister.ListRecords(callCtx)
+	cancel()
+	if err != nil {
+		showResult("unable to list provider records: ", err)
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	var recordList dnsv1.DNSRecordList
+	if err := r.List(ctx, &recordList, client.InNamespace(zoneImport.Namespace)); err != nil {
+		showResult("unable to list DNSRecord: ", err)
+		return ctrl.Result{}, err
+	}
+	adopted := make(map[string]bool)
+	for _, dnsRecord := range recordList.Items {
+		if dnsRecord.Status.ProviderRef.Name == dnsProvider.Name && dnsRecord.Status.RecordID != "" {
+			adopted[dnsRecord.Status.RecordID] = true
+		}
+	}
+
+	labels := make(map[string]string)
+	if dnsProvider.Spec.Selector != nil {
+		for k, v := range dnsProvider.Spec.Selector.MatchLabels {
+			labels[k] = v
+		}
+	}
+	for k, v := range zoneImport.Spec.Labels {
+		labels[k] = v
+	}
+	recordTypes := zoneImport.Spec.RecordTypes
+	if len(recordTypes) == 0 {
+		recordTypes = supportedRecordTypes
+	}
+
+	zoneImport.Status.Imported = 0
+	zoneImport.Status.Skipped = 0
+	zoneImport.Status.Unmatched = nil
+	for i := range records {
+		rec := &records[i]
+		if rec.Owner.IsLocal() || adopted[rec.ID] ||
+			!containsRecordType(recordTypes, rec.RecordType) ||
+			!containsRecordType(supportedRecordTypes, rec.RecordType) ||
+			(nameRegex != nil && !nameRegex.MatchString(rec.Name)) {
+			zoneImport.Status.Skipped++
+			continue
+		}
+		dnsRecord := rec.DNSRecord()
+		dnsRecord.ObjectMeta = metav1.ObjectMeta{
+			Namespace:   zoneImport.Namespace,
+			Name:        importedRecordName(rec),
+			Labels:      make(map[string]string, len(labels)),
+			Annotations: dnsRecord.Annotations,
+		}
+		for k, v := range labels {
+			dnsRecord.Labels[k] = v
+		}
+		if !dnsRecord.Match(&dnsProvider) {
+			// e.g. the records of the zone apex, which no DNSRecord of the provider can manage
+			zoneImport.Status.Skipped++
+			zoneImport.Status.Unmatched = append(zoneImport.Status.Unmatched, string(rec.RecordType)+" "+rec.Name)
+			continue
+		}
+
+		if dnsRecord.Annotations == nil {
+			dnsRecord.Annotations = make(map[string]string)
+		}
+		dnsRecord.Annotations[AnnotationKeyImportedBy] = zoneImport.Name
+		status := dnsv1.DNSRecordStatus{
+			ProviderRef: dnsv1.NamespacedName{Name: dnsProvider.Name},
+			RecordID:    rec.ID,
+		}
+		if err := r.Create(ctx, dnsRecord); err != nil {
+			if client.IgnoreAlreadyExists(err) != nil {
+				showResult("unable to create DNSRecord "+dnsRecord.Name+": ", err)
+				return ctrl.Result{}, err
+			}
+			// imported by a previous run, whose status was not written
+			zoneImport.Status.Skipped++
+			continue
+		}
+		dnsRecord.Status = status
+		if err := r.Status().Update(ctx, dnsRecord); err != nil {
+			// the DNSRecord controller will find the record by name instead
+			logger.Error(err, "unable to update DNSRecord status", "dnsrecord", dnsRecord.Name)
+		}
+		zoneImport.Status.Imported++
+	}
+
+	now := metav1.Now()
+	zoneImport.Status.LastImportTime = &now
+	message := fmt.Sprintf("imported %d records, skipped %d records", zoneImport.Status.Imported, zoneImport.Status.Skipped)
+	if len(zoneImport.Status.Unmatched) != 0 {
+		message += fmt.Sprintf(", %d records would not be matched to the provider", len(zoneImport.Status.Unmatched))
+	}
+	showResult(message, nil)
+	return ctrl.Result{}, nil
+}
+
+func containsRecordType(recordTypes []dnsv1.DNSRecordType, recordType dnsv1.DNSRecordType) bool {
+	for _, t := range recordTypes {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// importedRecordName returns a valid object name for the record, in the form of `<name>-<type>-<hash of id>`.
+// The name is derived from the record id, so the records of the same name and type are told apart
+// and a record is imported as the same object by every run.
+func importedRecordName(rec *provider.ProviderRecord) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(rec.ID))
+	suffix := fmt.Sprintf("-%08x", hash.Sum32())
+
+	name := strings.ToLower(rec.Name + "-" + string(rec.RecordType))
+	name = strings.ReplaceAll(name, "*", "wildcard")
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "-"), "-")
+	if max := 253 - len(suffix); len(name) > max {
+		name = strings.Trim(name[len(name)-max:], "-")
+	}
+	return name + suffix
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DNSZoneImportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("DNSZoneImport")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&dnsv1.DNSZoneImport{}).
+		WithEventFilter(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldGeneration := e.ObjectOld.GetGeneration()
+				newGeneration := e.ObjectNew.GetGeneration()
+				// Generation is only updated on spec changes (also on deletion),
+				// not metadata or status
+				// Filter out events where the generation hasn't changed to
+				// avoid being triggered by status updates
+
+				return oldGeneration != newGeneration
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				// Imported DNSRecords are not owned by the DNSZoneImport
+				return false
+			},
+		}).
+		Complete(r)
+}
@@ -0,0 +1,127 @@
+package dns
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+// The provider type of the tests, which creates testProvider
+const testProviderType = "Test"
+
+var testProvider *fakeProvider
+
+func init() {
+	provider.Register(testProviderType, func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		return testProvider, nil
+	})
+}
+
+func TestDNSZoneImport(t *testing.T) {
+	setOwnerID(t, "cluster-a")
+	ctx := context.Background()
+	testProvider = &fakeProvider{records: []provider.ProviderRecord{
+		{ID: "1", Name: "www.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4"},
+		// the records of the same name and type
+		{ID: "2", Name: "api.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4"},
+		{ID: "3", Name: "api.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "5.6.7.8"},
+		{ID: "4", Name: "example.com", RecordType: dnsv1.DNSRecordTypeMX, Value: "10 mx.example.com"},
+		{ID: "5", Name: "owned.example.com", RecordType: dnsv1.DNSRecordTypeA, Value: "1.2.3.4",
+			Owner: &provider.RecordOwner{OwnerID: "cluster-a", Namespace: "default", Name: "owned"}},
+	}}
+	dnsProvider := &dnsv1.DNSProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: "provider"},
+		Spec: dnsv1.DNSProviderSpec{
+			DomainName:   "example.com",
+			ProviderType: testProviderType,
+			Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"zone": "example"}},
+		},
+		Status: dnsv1.DNSProviderStatus{Valid: true},
+	}
+	zoneImport := &dnsv1.DNSZoneImport{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "import"},
+		Spec:       dnsv1.DNSZoneImportSpec{ProviderRef: dnsProvider.Name, Labels: map[string]string{"imported": "true"}},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(dnsProvider, zoneImport).Build()
+	r := &DNSZoneImportReconciler{Client: c, recorder: record.NewFakeRecorder(100)}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "import"}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, req.NamespacedName, zoneImport); err != nil {
+		t.Fatal(err)
+	}
+	if zoneImport.Status.Imported != 3 || zoneImport.Status.Skipped != 2 ||
+		len(zoneImport.Status.Unmatched) != 1 || zoneImport.Status.Unmatched[0] != "MX example.com" {
+		t.Fatalf("unexpected status %+v", zoneImport.Status)
+	}
+	var recordList dnsv1.DNSRecordList
+	if err := c.List(ctx, &recordList, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(recordList.Items) != 3 {
+		t.Fatalf("imported %d DNSRecords", len(recordList.Items))
+	}
+	names := map[string]bool{}
+	for _, dnsRecord := range recordList.Items {
+		names[dnsRecord.Name] = true
+		if !strings.HasPrefix(dnsRecord.Name, strings.ReplaceAll(dnsRecord.Spec.Name, ".", "-")+"-a-") ||
+			dnsRecord.Labels["zone"] != "example" || dnsRecord.Labels["imported"] != "true" || len(dnsRecord.Labels) != 2 ||
+			dnsRecord.Annotations[AnnotationKeyImportedBy] != "import" || dnsRecord.Status.RecordID == "" {
+			t.Errorf("unexpected DNSRecord %s %+v %+v", dnsRecord.Name, dnsRecord.ObjectMeta, dnsRecord.Status)
+		}
+	}
+
+	// the status of the DNSRecords is lost, e.g. failed to be written, the rerun adopts the existing objects
+	for i := range recordList.Items {
+		recordList.Items[i].Status = dnsv1.DNSRecordStatus{}
+		if err := c.Status().Update(ctx, &recordList.Items[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.List(ctx, &recordList, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(recordList.Items) != 3 {
+		t.Fatalf("the rerun creates duplicates, %d DNSRecords", len(recordList.Items))
+	}
+	for _, dnsRecord := range recordList.Items {
+		if !names[dnsRecord.Name] {
+			t.Errorf("unexpected DNSRecord %s of the rerun", dnsRecord.Name)
+		}
+	}
+}
+
+func TestImportedRecordName(t *testing.T) {
+	rec := &provider.ProviderRecord{ID: "test.example.com. A", Name: "*.Example.com", RecordType: dnsv1.DNSRecordTypeA}
+	name := importedRecordName(rec)
+	if !strings.HasPrefix(name, "wildcard-example-com-a-") || len(name) != len("wildcard-example-com-a-")+8 {
+		t.Fatalf("unexpected name %s", name)
+	}
+	if importedRecordName(rec) != name {
+		t.Fatal("the name is not stable")
+	}
+	other := *rec
+	other.ID = "test.example.com. A blue"
+	if importedRecordName(&other) == name {
+		t.Fatal("the records of different ids have the same name")
+	}
+	rec.Name = strings.Repeat("a", 300) + ".example.com"
+	if name := importedRecordName(rec); len(name) > 253 {
+		t.Fatalf("the name is too long: %d", len(name))
+	}
+}
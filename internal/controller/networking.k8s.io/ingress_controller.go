@@ -41,8 +41,10 @@ import (
 // IngressReconciler reconciles a Ingress object
 type IngressReconciler struct {
 	client.Client
-	Scheme   *runtime.Scheme
-	recorder record.EventRecorder
+	Scheme *runtime.Scheme
+	// The timeout of a reconciliation, unlimited if 0
+	ReconcileTimeout time.Duration
+	recorder         record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
@@ -104,7 +106,14 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, nil
 	}
 
-	records, err := recordGenerator.Generate(ctx, generator.DNSGeneratorSourceIngress)
+	// only the generator is bound to the reconcile timeout, e.g. the public IP detection of DDNS, so the DNSRecords are still written after the timeout
+	generatorCtx := ctx
+	if r.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		generatorCtx, cancel = context.WithTimeout(ctx, r.ReconcileTimeout)
+		defer cancel()
+	}
+	records, err := recordGenerator.Generate(generatorCtx, generator.DNSGeneratorSourceIngress)
 	if err != nil {
 		showResult("Error", "generator error", err)
 		return ctrl.Result{}, err
@@ -132,9 +141,23 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
+	deletionPolicy := dnsv1.DNSRecordDeletionPolicy(ingress.Annotations[generator.AnnotationKeyDeletionPolicy])
+	switch deletionPolicy {
+	case "", dnsv1.DNSRecordDeletionPolicyDelete, dnsv1.DNSRecordDeletionPolicyRetain:
+	default:
+		showResult("Warning", "invalid annotation "+generator.AnnotationKeyDeletionPolicy+": "+string(deletionPolicy)+", ignored", nil)
+		deletionPolicy = ""
+	}
+
 	oldLogger := logger
 	for _, record := range records {
 		logger = oldLogger.WithValues("dns", record)
+		record.DeletionPolicy = deletionPolicy
+		healthCheck, err := generator.IngressHealthCheck(&ingress, record.Name)
+		if err != nil {
+			showResult("Warning", "invalid health check, ignored", err)
+		}
+		record.HealthCheck = healthCheck
 
 		delete(ownedRecordMap, record.Name)
 
@@ -20,6 +20,9 @@ type Config struct {
 			Type string `envconfig:"NATM_DEFAULT_GENERATOR_TYPE"`
 		}
 	}
+	Owner struct {
+		ID string `envconfig:"NATM_OWNER_ID"`
+	}
 	Bind struct {
 		Metrics     string `envconfig:"NATM_BIND_METRICS"`
 		HealthProbe string `envconfig:"NATM_BIND_HEALTH_PROBE"`
@@ -30,6 +33,9 @@ var config Config
 
 type EnvKey = string
 
+// DefaultOwnerID is the owner id of the clusters without NATM_OWNER_ID, which is shared by all of them
+const DefaultOwnerID = "default"
+
 const (
 	GO_ENV EnvKey = "GO_ENV"
 )
@@ -22,7 +22,7 @@ type DDNSGenerator struct {
 func (g *DDNSGenerator) Generate(ctx context.Context, source generator.DNSGeneratorSource) ([]v1.DNSRecordSpec, error) {
 	records := []v1.DNSRecordSpec{}
 	if source == generator.DNSGeneratorSourceIngress {
-		ip, err := g.GetPublicIP()
+		ip, err := g.GetPublicIP(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -48,12 +48,12 @@ func (g *DDNSGenerator) RequeueAfter(ctx context.Context, source generator.DNSGe
 
 const cacheKeyPublicIP = "publicIP"
 
-func (g *DDNSGenerator) GetPublicIP() (string, error) {
+func (g *DDNSGenerator) GetPublicIP(ctx context.Context) (string, error) {
 	ip, ok := g.cache.Get(cacheKeyPublicIP)
 	if ok {
 		return ip.(string), nil
 	}
-	ip = g.ip.MyIPv4()
+	ip = g.ip.MyIPv4(ctx)
 	if ip == "" {
 		return "", ErrNoPublicIP
 	}
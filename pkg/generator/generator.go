@@ -12,6 +12,8 @@ import (
 const (
 	AnnotationKeyGenerator    = "dns.xzzpig.com/generator"
 	AnnotationKeyRecordPrefix = "dns.xzzpig.com/record-"
+	// The deletion policy of the generated records, Delete or Retain
+	AnnotationKeyDeletionPolicy = "dns.xzzpig.com/deletion-policy"
 )
 
 type DNSGeneratorSource string
@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	netv1 "k8s.io/api/networking/v1"
+)
+
+const (
+	// The health check of the generated records, HTTP, HTTPS, TCP or Endpoints
+	AnnotationKeyHealthCheck = "dns.xzzpig.com/health-check"
+	// The port probed by the HTTP, HTTPS and TCP health checks
+	AnnotationKeyHealthCheckPort = "dns.xzzpig.com/health-check-port"
+	// The path probed by the HTTP and HTTPS health checks
+	AnnotationKeyHealthCheckPath = "dns.xzzpig.com/health-check-path"
+	// The value published while the target is unhealthy, the records are withdrawn if empty
+	AnnotationKeyHealthCheckFallback = "dns.xzzpig.com/health-check-fallback"
+)
+
+// IngressHealthCheck returns the health check of the record generated for the host of the ingress by its annotations,
+// nil if the annotation is not set. The Endpoints health check probes the backend Services of the host.
+func IngressHealthCheck(ingress *netv1.Ingress, host string) (*dnsv1.DNSRecordHealthCheck, error) {
+	kind := ingress.Annotations[AnnotationKeyHealthCheck]
+	if kind == "" {
+		return nil, nil
+	}
+	// the defaults of the CRD are set explicitly, so the generated spec equals the stored one
+	check := &dnsv1.DNSRecordHealthCheck{
+		Interval:           30,
+		Timeout:            5,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+		FallbackValue:      ingress.Annotations[AnnotationKeyHealthCheckFallback],
+	}
+	var port int32
+	if value := ingress.Annotations[AnnotationKeyHealthCheckPort]; value != "" {
+		p, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid annotation %s: %s", AnnotationKeyHealthCheckPort, value)
+		}
+		port = int32(p)
+	}
+
+	switch strings.ToUpper(kind) {
+	case "HTTP", "HTTPS":
+		path := ingress.Annotations[AnnotationKeyHealthCheckPath]
+		if path == "" {
+			path = "/"
+		}
+		check.HTTP = &dnsv1.DNSRecordHTTPHealthCheck{
+			Scheme: dnsv1.DNSRecordHTTPScheme(strings.ToUpper(kind)),
+			Port:   port,
+			Path:   path,
+		}
+	case "TCP":
+		if port == 0 {
+			return nil, fmt.Errorf("annotation %s is required by the TCP health check", AnnotationKeyHealthCheckPort)
+		}
+		check.TCP = &dnsv1.DNSRecordTCPHealthCheck{Port: port}
+	case "ENDPOINTS":
+		services := ingressServices(ingress, host)
+		if len(services) == 0 {
+			return nil, fmt.Errorf("no backend service of host %s", host)
+		}
+		check.Endpoints = &dnsv1.DNSRecordEndpointsHealthCheck{Services: services}
+	default:
+		return nil, fmt.Errorf("invalid annotation %s: %s", AnnotationKeyHealthCheck, kind)
+	}
+	return check, nil
+}
+
+// ingressServices returns the backend Services of the host, or the default backend if the host has no backend service
+func ingressServices(ingress *netv1.Ingress, host string) []string {
+	var services []string
+	seen := map[string]bool{}
+	add := func(backend *netv1.IngressBackend) {
+		if backend != nil && backend.Service != nil && !seen[backend.Service.Name] {
+			seen[backend.Service.Name] = true
+			services = append(services, backend.Service.Name)
+		}
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != host || rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			add(&rule.HTTP.Paths[i].Backend)
+		}
+	}
+	if len(services) == 0 {
+		add(ingress.Spec.DefaultBackend)
+	}
+	return services
+}
@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIngressHealthCheck(t *testing.T) {
+	backend := func(service string) netv1.IngressBackend {
+		return netv1.IngressBackend{Service: &netv1.IngressServiceBackend{Name: service}}
+	}
+	rule := func(host string, services ...string) netv1.IngressRule {
+		var paths []netv1.HTTPIngressPath
+		for _, service := range services {
+			paths = append(paths, netv1.HTTPIngressPath{Backend: backend(service)})
+		}
+		return netv1.IngressRule{Host: host, IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: paths}}}
+	}
+	defaultBackend := backend("default")
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		spec        netv1.IngressSpec
+		want        *dnsv1.DNSRecordHealthCheck
+		wantErr     bool
+	}{
+		{name: "no health check"},
+		{
+			name:        "http",
+			annotations: map[string]string{AnnotationKeyHealthCheck: "https", AnnotationKeyHealthCheckPort: "8443", AnnotationKeyHealthCheckFallback: "1.2.3.4"},
+			want: &dnsv1.DNSRecordHealthCheck{
+				HTTP:     &dnsv1.DNSRecordHTTPHealthCheck{Scheme: dnsv1.DNSRecordHTTPSchemeHTTPS, Port: 8443, Path: "/"},
+				Interval: 30, Timeout: 5, HealthyThreshold: 2, UnhealthyThreshold: 3, FallbackValue: "1.2.3.4",
+			},
+		},
+		{
+			name:        "tcp",
+			annotations: map[string]string{AnnotationKeyHealthCheck: "TCP", AnnotationKeyHealthCheckPort: "22"},
+			want: &dnsv1.DNSRecordHealthCheck{
+				TCP:      &dnsv1.DNSRecordTCPHealthCheck{Port: 22},
+				Interval: 30, Timeout: 5, HealthyThreshold: 2, UnhealthyThreshold: 3,
+			},
+		},
+		{name: "tcp without port", annotations: map[string]string{AnnotationKeyHealthCheck: "TCP"}, wantErr: true},
+		{name: "bad port", annotations: map[string]string{AnnotationKeyHealthCheck: "HTTP", AnnotationKeyHealthCheckPort: "http"}, wantErr: true},
+		{name: "port out of range", annotations: map[string]string{AnnotationKeyHealthCheck: "TCP", AnnotationKeyHealthCheckPort: "65536"}, wantErr: true},
+		{name: "unknown kind", annotations: map[string]string{AnnotationKeyHealthCheck: "ICMP"}, wantErr: true},
+		{
+			name:        "endpoints",
+			annotations: map[string]string{AnnotationKeyHealthCheck: "Endpoints"},
+			spec:        netv1.IngressSpec{DefaultBackend: &defaultBackend, Rules: []netv1.IngressRule{rule("www.example.com", "web", "api", "web"), rule("other.example.com", "other")}},
+			want: &dnsv1.DNSRecordHealthCheck{
+				Endpoints: &dnsv1.DNSRecordEndpointsHealthCheck{Services: []string{"web", "api"}},
+				Interval:  30, Timeout: 5, HealthyThreshold: 2, UnhealthyThreshold: 3,
+			},
+		},
+		{
+			name:        "endpoints of default backend",
+			annotations: map[string]string{AnnotationKeyHealthCheck: "Endpoints"},
+			spec:        netv1.IngressSpec{DefaultBackend: &defaultBackend, Rules: []netv1.IngressRule{rule("other.example.com", "other")}},
+			want: &dnsv1.DNSRecordHealthCheck{
+				Endpoints: &dnsv1.DNSRecordEndpointsHealthCheck{Services: []string{"default"}},
+				Interval:  30, Timeout: 5, HealthyThreshold: 2, UnhealthyThreshold: 3,
+			},
+		},
+		{
+			name:        "endpoints without backend",
+			annotations: map[string]string{AnnotationKeyHealthCheck: "Endpoints"},
+			spec:        netv1.IngressSpec{Rules: []netv1.IngressRule{rule("other.example.com", "other")}},
+			wantErr:     true,
+		},
+	}
+	for _, tc := range cases {
+		ingress := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}, Spec: tc.spec}
+		got, err := IngressHealthCheck(ingress, "www.example.com")
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var ErrNoProbe = errors.New("one of http, tcp and endpoints is required by the health check")
+
+// Probe runs the health check of the record once, returns nil if the target is healthy
+func Probe(ctx context.Context, reader client.Reader, rec *dnsv1.DNSRecord) error {
+	check := rec.Spec.HealthCheck
+	ctx, cancel := context.WithTimeout(ctx, check.TimeoutDuration())
+	defer cancel()
+
+	address := check.Address
+	if address == "" {
+		address = rec.Spec.Value
+	}
+	switch {
+	case check.HTTP != nil:
+		host := check.HTTP.Host
+		if host == "" {
+			host = rec.Spec.Name
+		}
+		return probeHTTP(ctx, check.HTTP, address, host)
+	case check.TCP != nil:
+		return probeTCP(ctx, net.JoinHostPort(address, strconv.Itoa(int(check.TCP.Port))))
+	case check.Endpoints != nil:
+		namespace := check.Endpoints.Namespace
+		if namespace == "" {
+			namespace = rec.Namespace
+		}
+		return probeEndpoints(ctx, reader, namespace, check.Endpoints.Services)
+	}
+	return ErrNoProbe
+}
+
+func probeHTTP(ctx context.Context, check *dnsv1.DNSRecordHTTPHealthCheck, address string, host string) error {
+	scheme, port := "http", int32(80)
+	if check.Scheme == dnsv1.DNSRecordHTTPSchemeHTTPS {
+		scheme, port = "https", 443
+	}
+	if check.Port != 0 {
+		port = check.Port
+	}
+	path := check.Path
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+net.JoinHostPort(address, strconv.Itoa(int(port)))+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = host
+	client := &http.Client{
+		Transport: &http.Transport{
+			// like the HTTPS probes of kubelet, the certificate is not verified
+			TLSClientConfig:   &tls.Config{ServerName: host, InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		// redirects are healthy responses
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("http probe of %s returned %s", req.URL, resp.Status)
+	}
+	return nil
+}
+
+func probeTCP(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func probeEndpoints(ctx context.Context, reader client.Reader, namespace string, services []string) error {
+	for _, service := range services {
+		var endpoints corev1.Endpoints
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: service}, &endpoints); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		for _, subset := range endpoints.Subsets {
+			if len(subset.Addresses) != 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no ready endpoint of services %v in namespace %s", services, namespace)
+}
+
+// Observe records the result of a probe in the health status, the health is only changed after
+// the thresholds of the check are reached in a row, so a flapping target does not flap the record.
+// The target is healthy until proven otherwise. It returns true if the health is changed.
+func Observe(status *dnsv1.DNSRecordHealthStatus, check *dnsv1.DNSRecordHealthCheck, probeErr error, now time.Time) (changed bool) {
+	healthyThreshold, unhealthyThreshold := check.Thresholds()
+	probeTime := metav1.NewTime(now)
+	status.LastProbeTime = &probeTime
+	if probeErr == nil {
+		status.Successes++
+		status.Failures = 0
+		status.Message = ""
+		changed = !status.Healthy && status.Successes >= healthyThreshold
+	} else {
+		status.Failures++
+		status.Successes = 0
+		status.Message = probeErr.Error()
+		changed = status.Healthy && status.Failures >= unhealthyThreshold
+	}
+	if changed {
+		status.Healthy = !status.Healthy
+		status.LastTransitionTime = &probeTime
+	}
+	return changed
+}
@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProbeHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	portNumber, _ := strconv.Atoi(port)
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "www.example.com",
+		Value:      host,
+		HealthCheck: &dnsv1.DNSRecordHealthCheck{
+			HTTP: &dnsv1.DNSRecordHTTPHealthCheck{Port: int32(portNumber), Path: "/healthz"},
+		},
+	}}
+	if err := Probe(context.Background(), nil, rec); err != nil {
+		t.Fatal(err)
+	}
+	rec.Spec.HealthCheck.HTTP.Path = "/down"
+	if err := Probe(context.Background(), nil, rec); err == nil {
+		t.Fatal("expected 503 to be unhealthy")
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		Value:       "192.0.2.1",
+		HealthCheck: &dnsv1.DNSRecordHealthCheck{TCP: &dnsv1.DNSRecordTCPHealthCheck{Port: int32(port)}, Address: "127.0.0.1"},
+	}}
+	if err := Probe(context.Background(), nil, rec); err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	if err := Probe(context.Background(), nil, rec); err == nil {
+		t.Fatal("expected a closed port to be unhealthy")
+	}
+}
+
+func TestProbeEndpoints(t *testing.T) {
+	ready := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ready"},
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	}
+	notReady := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "not-ready"},
+		Subsets:    []corev1.EndpointSubset{{NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}}}},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ready, notReady).Build()
+	rec := &dnsv1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "www"},
+		Spec: dnsv1.DNSRecordSpec{HealthCheck: &dnsv1.DNSRecordHealthCheck{
+			Endpoints: &dnsv1.DNSRecordEndpointsHealthCheck{Services: []string{"missing", "not-ready"}},
+		}},
+	}
+	if err := Probe(context.Background(), reader, rec); err == nil {
+		t.Fatal("expected services without ready endpoints to be unhealthy")
+	}
+	rec.Spec.HealthCheck.Endpoints.Services = append(rec.Spec.HealthCheck.Endpoints.Services, "ready")
+	if err := Probe(context.Background(), reader, rec); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	check := &dnsv1.DNSRecordHealthCheck{HealthyThreshold: 2, UnhealthyThreshold: 3}
+	status := &dnsv1.DNSRecordHealthStatus{Healthy: true}
+	probeErr := errors.New("connection refused")
+	now := time.Now()
+
+	for i, want := range []bool{false, false, true} {
+		if changed := Observe(status, check, probeErr, now); changed != want {
+			t.Fatalf("failure %d: changed=%v", i+1, changed)
+		}
+	}
+	if status.Healthy || status.Message != probeErr.Error() {
+		t.Fatalf("expected unhealthy after 3 failures: %+v", status)
+	}
+	// a success resets the failures but does not recover the target alone
+	if Observe(status, check, nil, now) || Observe(status, check, probeErr, now) || Observe(status, check, nil, now) {
+		t.Fatalf("flapping target changed the health: %+v", status)
+	}
+	if !Observe(status, check, nil, now) || !status.Healthy {
+		t.Fatalf("expected healthy after 2 successes: %+v", status)
+	}
+}
@@ -0,0 +1,252 @@
+package adguard
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+// zoneRecord is the rewrite rules of a domain and type, AdGuard Home allows multiple answers of a domain
+type zoneRecord = []util.AdGuardRewrite
+
+var supportedRecordTypes = []dnsv1.DNSRecordType{dnsv1.DNSRecordTypeA, dnsv1.DNSRecordTypeAAAA, dnsv1.DNSRecordTypeCNAME}
+
+// AdGuardProvider manages the DNS rewrite rules of AdGuard Home.
+// The record id is the domain and type of the rule, e.g. `www.example.com A`.
+// AdGuard Home has no place to store the ownership marker, so the rules are never garbage collected.
+type AdGuardProvider struct {
+	util *util.AdGuardUtils
+	spec *dnsv1.DNSProviderSpec
+	zone *provider.ZoneSnapshot[zoneRecord]
+}
+
+func recordID(domain string, recordType dnsv1.DNSRecordType) string {
+	return strings.ToLower(strings.TrimSuffix(domain, ".")) + " " + string(recordType)
+}
+
+// recordType infers the record type from the answer, returns empty for the special answers `A` and `AAAA`
+// which keep the upstream records
+func recordType(answer string) dnsv1.DNSRecordType {
+	if answer == "A" || answer == "AAAA" {
+		return ""
+	}
+	ip := net.ParseIP(answer)
+	switch {
+	case ip == nil:
+		return dnsv1.DNSRecordTypeCNAME
+	case ip.To4() != nil:
+		return dnsv1.DNSRecordTypeA
+	default:
+		return dnsv1.DNSRecordTypeAAAA
+	}
+}
+
+func (p *AdGuardProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	rewrites, err := p.util.ListRewrites(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(rewrites))
+	for _, rewrite := range rewrites {
+		t := recordType(rewrite.Answer)
+		if t == "" {
+			continue
+		}
+		id := recordID(rewrite.Domain, t)
+		zone[id] = append(zone[id], rewrite)
+	}
+	return zone, nil
+}
+
+func (p *AdGuardProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{RecordTypes: supportedRecordTypes}
+}
+
+// newRewrite builds the rewrite rule described by the DNSRecord
+func newRewrite(rec *dnsv1.DNSRecord) (util.AdGuardRewrite, error) {
+	rewrite := util.AdGuardRewrite{
+		Domain: strings.ToLower(strings.TrimSuffix(rec.Spec.Name, ".")),
+		Answer: strings.TrimSuffix(rec.Spec.Value, "."),
+	}
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeA, dnsv1.DNSRecordTypeAAAA, dnsv1.DNSRecordTypeCNAME:
+		if recordType(rewrite.Answer) != rec.Spec.RecordType {
+			return rewrite, fmt.Errorf("invalid %s record value %q", rec.Spec.RecordType, rec.Spec.Value)
+		}
+	default:
+		return rewrite, fmt.Errorf("record type %s is not supported by adguard provider", rec.Spec.RecordType)
+	}
+	return rewrite, nil
+}
+
+func (p *AdGuardProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	id = recordID(rec.Spec.Name, rec.Spec.RecordType)
+	_, ok, err = p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *AdGuardProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	rewrite, err := newRewrite(rec)
+	if err != nil {
+		return "", err
+	}
+	if err := p.util.AddRewrite(ctx, rewrite); err != nil {
+		p.zone.Invalidate()
+		return "", err
+	}
+	id = recordID(rewrite.Domain, rec.Spec.RecordType)
+	p.zone.Put(id, zoneRecord{rewrite})
+	return id, nil
+}
+
+func (p *AdGuardProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	desired, err := newRewrite(rec)
+	if err != nil {
+		return err
+	}
+	newID := recordID(desired.Domain, rec.Spec.RecordType)
+	current, _, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if newID != *id {
+		// the domain or type has changed, the rules of the new id are replaced as well
+		newCurrent, _, err := p.zone.Get(ctx, newID)
+		if err != nil {
+			return err
+		}
+		current = append(append(zoneRecord{}, current...), newCurrent...)
+	}
+	if len(current) == 1 && current[0] == desired {
+		if newID != *id {
+			p.zone.Remove(*id)
+			p.zone.Put(newID, current)
+			rec.Status.RecordID = newID
+		}
+		return nil
+	}
+
+	if len(current) == 1 {
+		err = p.util.UpdateRewrite(ctx, current[0], desired)
+	} else {
+		err = p.replace(ctx, current, desired)
+	}
+	if err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	p.zone.Remove(*id)
+	p.zone.Put(newID, zoneRecord{desired})
+	rec.Status.RecordID = newID
+	return nil
+}
+
+// replace adds the desired rule and removes the others
+func (p *AdGuardProvider) replace(ctx context.Context, current zoneRecord, desired util.AdGuardRewrite) error {
+	exists := false
+	for _, rewrite := range current {
+		exists = exists || rewrite == desired
+	}
+	if !exists {
+		if err := p.util.AddRewrite(ctx, desired); err != nil {
+			return err
+		}
+	}
+	for _, rewrite := range current {
+		if rewrite == desired {
+			continue
+		}
+		if err := p.util.DeleteRewrite(ctx, rewrite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *AdGuardProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	current, _, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	for _, rewrite := range current {
+		if err := p.util.DeleteRewrite(ctx, rewrite); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	p.zone.Remove(*id)
+	return nil
+}
+
+func (p *AdGuardProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	current, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok || len(current) == 0 {
+		return nil, err
+	}
+	rec := providerRecord(&current[0])
+	return &rec, nil
+}
+
+func (p *AdGuardProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for _, current := range zone {
+		// AdGuard Home is not partitioned by zones, only the rules of the domain are listed
+		if len(current) == 0 || !inDomain(current[0].Domain, p.spec.DomainName) {
+			continue
+		}
+		records = append(records, providerRecord(&current[0]))
+	}
+	return records, nil
+}
+
+func inDomain(name string, domain string) bool {
+	name, domain = strings.ToLower(name), strings.ToLower(strings.TrimSuffix(domain, "."))
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+func providerRecord(rewrite *util.AdGuardRewrite) provider.ProviderRecord {
+	t := recordType(rewrite.Answer)
+	return provider.ProviderRecord{
+		ID:         recordID(rewrite.Domain, t),
+		Name:       rewrite.Domain,
+		RecordType: t,
+		Value:      rewrite.Answer,
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeAdGuard), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		password, err := args.SecretValue(spec.AdGuard.PasswordSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		dnsutil, err := util.NewAdGuardUtils(util.AdGuardAccount{
+			ServerURL: spec.AdGuard.ServerURL,
+			Username:  spec.AdGuard.Username,
+			Password:  strings.TrimSpace(password),
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := &AdGuardProvider{
+			util: dnsutil,
+			spec: spec,
+		}
+		p.zone = provider.GetZoneSnapshot(string(dnsv1.DNSProviderTypeAdGuard)+"/"+spec.AdGuard.ServerURL, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
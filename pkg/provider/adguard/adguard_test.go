@@ -0,0 +1,140 @@
+package adguard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testServer is a local stand-in of the AdGuard Home `/control/rewrite` endpoints
+type testServer struct {
+	mu       sync.Mutex
+	rewrites []util.AdGuardRewrite
+	updates  int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "test-password" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	switch r.URL.Path {
+	case "/control/rewrite/list":
+		json.NewEncoder(w).Encode(s.rewrites)
+	case "/control/rewrite/add":
+		var rewrite util.AdGuardRewrite
+		json.NewDecoder(r.Body).Decode(&rewrite)
+		s.rewrites = append(s.rewrites, rewrite)
+	case "/control/rewrite/update":
+		var body struct {
+			Target util.AdGuardRewrite `json:"target"`
+			Update util.AdGuardRewrite `json:"update"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for i := range s.rewrites {
+			if s.rewrites[i] == body.Target {
+				s.rewrites[i] = body.Update
+				s.updates++
+				return
+			}
+		}
+		http.Error(w, "rewrite rule not found", http.StatusBadRequest)
+	case "/control/rewrite/delete":
+		var rewrite util.AdGuardRewrite
+		json.NewDecoder(r.Body).Decode(&rewrite)
+		rewrites := s.rewrites[:0]
+		for _, v := range s.rewrites {
+			if v != rewrite {
+				rewrites = append(rewrites, v)
+			}
+		}
+		s.rewrites = rewrites
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestAdGuardProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{rewrites: []util.AdGuardRewrite{{Domain: "other.org", Answer: "10.0.0.9"}, {Domain: "upstream.example.com", Answer: "A"}}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "adguard"},
+		Data:       map[string][]byte{"password": []byte("test-password")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeAdGuard,
+		AdGuard: dnsv1.AdGuardProviderConfig{
+			ServerURL:         httpServer.URL,
+			Username:          "admin",
+			PasswordSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "adguard", Key: "password"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeAAAA,
+		Name:       "www.example.com",
+		Value:      "2001:db8::1",
+	}}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "www.example.com AAAA" {
+		t.Fatalf("unexpected id %q", id)
+	}
+
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeCNAME
+	rec.Spec.Value = "web.example.com."
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if server.updates != 1 || server.rewrites[2] != (util.AdGuardRewrite{Domain: "www.example.com", Answer: "web.example.com"}) {
+		t.Fatalf("unexpected rewrites %+v", server.rewrites)
+	}
+	id = rec.Status.RecordID
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "www.example.com CNAME" || records[0].Value != "web.example.com" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.rewrites) != 2 {
+		t.Fatalf("unexpected rewrites after delete %+v", server.rewrites)
+	}
+
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeA
+	if _, err := p.CreateRecord(ctx, rec); err == nil {
+		t.Fatal("expected A record with a domain value to be rejected")
+	}
+}
@@ -2,72 +2,302 @@ package alidns
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 
+	alidnsclient "github.com/alibabacloud-go/alidns-20150109/client"
+	"github.com/alibabacloud-go/tea/tea"
 	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
 	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
 	"github.com/xzzpig/k8s-dns-manager/util"
 )
 
+const (
+	AnnotationKeyLine = "dns.xzzpig.com/record-aliyun-line"
+
+	defaultLine = "default"
+)
+
+// The credential types of the util by the credential types of the provider
+var credentialTypes = map[dnsv1.AliyunCredentialType]string{
+	dnsv1.AliyunCredentialTypeAccessKey:   util.AliCredentialTypeAccessKey,
+	dnsv1.AliyunCredentialTypeSTS:         util.AliCredentialTypeSTS,
+	dnsv1.AliyunCredentialTypeRAMRoleARN:  util.AliCredentialTypeRAMRoleARN,
+	dnsv1.AliyunCredentialTypeECSRAMRole:  util.AliCredentialTypeECSRAMRole,
+	dnsv1.AliyunCredentialTypeOIDCRoleARN: util.AliCredentialTypeOIDCRoleARN,
+}
+
+type zoneRecord = *alidnsclient.DescribeDomainRecordsResponseBodyDomainRecordsRecord
+
 type AliDNSProvider struct {
 	util *util.AliDNSUtils
 	spec *dnsv1.DNSProviderSpec
+	zone *provider.ZoneSnapshot[zoneRecord]
+}
+
+func (p *AliDNSProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	records, err := p.util.ListRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(records))
+	for _, record := range records {
+		zone[tea.StringValue(record.RecordId)] = record
+	}
+	return zone, nil
+}
+
+// line returns the line of the record, e.g. default, telecom, unicom
+func (p *AliDNSProvider) line(rec *dnsv1.DNSRecord) string {
+	if rec.Spec.Routing != nil && rec.Spec.Routing.Geo != "" {
+		return rec.Spec.Routing.Geo
+	}
+	if line := rec.Annotations[AnnotationKeyLine]; line != "" {
+		return line
+	}
+	if p.spec.Aliyun.Line != "" {
+		return p.spec.Aliyun.Line
+	}
+	return defaultLine
+}
+
+// recordValue returns the value of the record in the form of DNSRecord, the preference of MX records is the priority in Aliyun
+func recordValue(record zoneRecord) string {
+	if tea.StringValue(record.Type) == "MX" && tea.Int64Value(record.Priority) != 0 {
+		return strconv.FormatInt(tea.Int64Value(record.Priority), 10) + " " + tea.StringValue(record.Value)
+	}
+	return tea.StringValue(record.Value)
+}
+
+func (p *AliDNSProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{
+		Ownership:   true,
+		Annotations: []string{AnnotationKeyLine},
+		// the records of the same name answer the lines by routing.geo
+		RoutingPolicies: []dnsv1.DNSRoutingPolicy{dnsv1.DNSRoutingPolicyGeo},
+	}
 }
 
 func (p *AliDNSProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
-	if rec.Status.RecordID != "" {
-		record, err := p.util.FindRecordById(rec.Status.RecordID)
-		if err == nil && record != nil {
-			return *record.RecordId, true, nil
+	rr := rec.Spec.RR(p.spec)
+	line := p.line(rec)
+	synced := p.zone.Synced()
+	if synced {
+		if rec.Status.RecordID != "" {
+			record, ok, err := p.zone.Get(ctx, rec.Status.RecordID)
+			if err == nil && ok {
+				return *record.RecordId, true, nil
+			}
+		}
+		id, _, ok, err = p.zone.Find(ctx, func(id string, record zoneRecord) bool {
+			return tea.StringValue(record.RR) == rr && tea.StringValue(record.Type) == string(rec.Spec.RecordType) && tea.StringValue(record.Line) == line
+		})
+		if err != nil {
+			return "", false, err
+		}
+		if ok {
+			rec.Status.RecordID = id
+			return rec.Status.RecordID, true, nil
 		}
 	}
-	rr := rec.Spec.RR(p.spec)
-	record, err := p.util.FindRecordByRR(rr)
+
+	// the zone is not listed for a single record, and the snapshot misses the records created by others since the listing
+	records, err := p.util.FindRecordsByRR(ctx, rr, string(rec.Spec.RecordType))
 	if err != nil {
 		return "", false, err
 	}
-	if record == nil {
-		return "", false, nil
+	var found zoneRecord
+	for _, record := range records {
+		recordID := tea.StringValue(record.RecordId)
+		if recordID == rec.Status.RecordID {
+			found = record
+			break
+		}
+		// the same record is found every time like the snapshot
+		if tea.StringValue(record.Line) == line && (found == nil || recordID < tea.StringValue(found.RecordId)) {
+			found = record
+		}
 	}
-	rec.Status.RecordID = *record.RecordId
+	if found == nil {
+		if rec.Status.RecordID == "" || synced {
+			return "", false, nil
+		}
+		// the record may be renamed since it's written, which is only found by its id in the listing
+		if err := provider.WaitCallRateLimit(ctx); err != nil {
+			return "", false, err
+		}
+		record, ok, err := p.zone.Get(ctx, rec.Status.RecordID)
+		if err != nil || !ok {
+			return "", false, err
+		}
+		return *record.RecordId, true, nil
+	}
+	p.zone.Put(tea.StringValue(found.RecordId), found)
+	rec.Status.RecordID = tea.StringValue(found.RecordId)
 	return rec.Status.RecordID, true, nil
 }
 
 func (p *AliDNSProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
 	rr := rec.Spec.RR(p.spec)
-	return p.util.CreateRecord(rr, rec.Spec.Value, string(rec.Spec.RecordType))
+	id, err = p.util.CreateRecord(ctx, rr, rec.Spec.Value, string(rec.Spec.RecordType), p.line(rec))
+	if err != nil {
+		p.zone.Invalidate()
+		return "", err
+	}
+	if err := p.util.UpdateRecordRemark(ctx, id, provider.OwnerMarker(rec)); err != nil {
+		// the record is created without the owner marker, it's adopted by the id and marked by the next update
+		p.zone.Invalidate()
+		return id, err
+	}
+	p.zone.Put(id, p.newZoneRecord(id, rr, rec))
+	return id, nil
 }
 
 func (p *AliDNSProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
-	record, err := p.util.FindRecordById(*id)
+	record, ok, err := p.zone.Get(ctx, *id)
 	if err != nil {
 		return err
 	}
 	rr := rec.Spec.RR(p.spec)
-	if *record.RR == rr && *record.Type == string(rec.Spec.RecordType) && *record.Value == rec.Spec.Value {
+	remark := provider.OwnerMarker(rec)
+	valueEquals := ok && tea.StringValue(record.RR) == rr && tea.StringValue(record.Type) == string(rec.Spec.RecordType) &&
+		recordValue(record) == rec.Spec.Value && tea.StringValue(record.Line) == p.line(rec)
+	remarkEquals := ok && tea.StringValue(record.Remark) == remark
+	if valueEquals && remarkEquals {
 		return nil
 	}
-	return p.util.UpdateRecord(*id, rr, rec.Spec.Value, string(rec.Spec.RecordType))
+	if !valueEquals {
+		if err := p.util.UpdateRecord(ctx, *id, rr, rec.Spec.Value, string(rec.Spec.RecordType), p.line(rec)); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	if !remarkEquals {
+		if err := p.util.UpdateRecordRemark(ctx, *id, remark); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	p.zone.Put(*id, p.newZoneRecord(*id, rr, rec))
+	return nil
 }
 
 func (p *AliDNSProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
-	return p.util.DeleteRecord(*id)
+	if err := p.util.DeleteRecord(ctx, *id); err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	p.zone.Remove(*id)
+	return nil
+}
+
+func (p *AliDNSProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	record, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	rec := p.providerRecord(id, record)
+	return &rec, nil
+}
+
+func (p *AliDNSProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for id, record := range zone {
+		records = append(records, p.providerRecord(id, record))
+	}
+	return records, nil
+}
+
+func (p *AliDNSProvider) providerRecord(id string, record zoneRecord) provider.ProviderRecord {
+	name := p.spec.DomainName
+	if rr := tea.StringValue(record.RR); rr != "@" {
+		name = rr + "." + name
+	}
+	return provider.ProviderRecord{
+		ID:         id,
+		Name:       name,
+		RecordType: dnsv1.DNSRecordType(tea.StringValue(record.Type)),
+		Value:      recordValue(record),
+		TTL:        int(tea.Int64Value(record.TTL)),
+		Owner:      provider.ParseOwnerMarker(tea.StringValue(record.Remark)),
+		Annotations: map[string]string{
+			AnnotationKeyLine: tea.StringValue(record.Line),
+		},
+	}
+}
+
+func (p *AliDNSProvider) newZoneRecord(id string, rr string, rec *dnsv1.DNSRecord) zoneRecord {
+	record := &alidnsclient.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+		DomainName: tea.String(p.spec.DomainName),
+		RecordId:   tea.String(id),
+		RR:         tea.String(rr),
+		Type:       tea.String(string(rec.Spec.RecordType)),
+		Value:      tea.String(rec.Spec.Value),
+		Line:       tea.String(p.line(rec)),
+		Remark:     tea.String(provider.OwnerMarker(rec)),
+	}
+	if rec.Spec.RecordType == dnsv1.DNSRecordTypeMX {
+		if preference, value, ok := strings.Cut(rec.Spec.Value, " "); ok {
+			if priority, err := strconv.ParseInt(preference, 10, 64); err == nil {
+				record.Priority = tea.Int64(priority)
+				record.Value = tea.String(strings.TrimSpace(value))
+			}
+		}
+	}
+	return record
+}
+
+// classifyError classifies the errors of the Aliyun SDK, the throttling errors may be returned with status 400
+func classifyError(err error) (provider.ErrorClass, time.Duration, bool) {
+	var sdkErr *tea.SDKError
+	if !errors.As(err, &sdkErr) {
+		return "", 0, false
+	}
+	if strings.HasPrefix(tea.StringValue(sdkErr.Code), "Throttling") {
+		return provider.ErrorClassRateLimited, 0, true
+	}
+	return provider.ClassifyStatusCode(tea.IntValue(sdkErr.StatusCode)), 0, true
 }
 
 func init() {
+	provider.RegisterErrorClassifier(classifyError)
 	// fmt.Println("init alidns provider")
 	provider.Register(string(dnsv1.DNSProviderTypeAliyun), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
 		spec := args.Spec
+		credentialType, ok := credentialTypes[spec.Aliyun.CredentialType]
+		if !ok && spec.Aliyun.CredentialType != "" {
+			return nil, errors.New("unknown aliyun credential type " + string(spec.Aliyun.CredentialType))
+		}
 		dnsutil, err := util.NewAliDnsUtils(util.AliDnsAccount{
 			AccessKeyID:     spec.Aliyun.AccessKeyID,
 			AccessKeySecret: spec.Aliyun.AccessKeySecret,
 			DomainName:      spec.DomainName,
+			CredentialType:  credentialType,
+			SecurityToken:   spec.Aliyun.SecurityToken,
+			RoleArn:         spec.Aliyun.RoleARN,
+			RoleSessionName: spec.Aliyun.RoleSessionName,
+			RoleName:        spec.Aliyun.RoleName,
+			OIDCProviderArn: spec.Aliyun.OIDCProviderARN,
+			OIDCTokenFile:   spec.Aliyun.OIDCTokenFile,
+			RegionID:        spec.Aliyun.RegionID,
+			Endpoint:        spec.Aliyun.Endpoint,
+			STSEndpoint:     spec.Aliyun.STSEndpoint,
 		})
 		if err != nil {
 			return nil, err
 		}
-		return &AliDNSProvider{
+		p := &AliDNSProvider{
 			util: dnsutil,
 			spec: spec,
-		}, nil
+		}
+		key := string(dnsv1.DNSProviderTypeAliyun) + "/" + spec.Aliyun.AccessKeyID + "/" + spec.Aliyun.RoleARN + "/" + spec.DomainName
+		p.zone = provider.GetZoneSnapshot(key, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
 	})
 }
@@ -0,0 +1,275 @@
+package alidns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	alidnsclient "github.com/alibabacloud-go/alidns-20150109/client"
+	"github.com/alibabacloud-go/tea/tea"
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+// testServer is a local stand-in of the record API of Alibaba Cloud DNS
+type testServer struct {
+	mu      sync.Mutex
+	records []zoneRecord
+	nextID  int
+	// the requests of DescribeDomainRecords listing the zone
+	pages int
+	// the requests of DescribeDomainRecords searching by the keywords
+	searches int
+	// if true, UpdateDomainRecordRemark fails
+	remarkFails bool
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.ParseForm()
+	w.Header().Set("Content-Type", "application/json")
+	resp := map[string]interface{}{"RequestId": "test"}
+	switch action := r.Form.Get("Action"); action {
+	case "DescribeDomainRecords":
+		matched := s.records
+		if r.Form.Get("SearchMode") == "ADVANCED" {
+			s.searches++
+			matched = nil
+			for _, record := range s.records {
+				if strings.Contains(tea.StringValue(record.RR), r.Form.Get("RRKeyWord")) && tea.StringValue(record.Type) == r.Form.Get("TypeKeyWord") {
+					matched = append(matched, record)
+				}
+			}
+		} else {
+			s.pages++
+		}
+		page, _ := strconv.Atoi(r.Form.Get("PageNumber"))
+		size, _ := strconv.Atoi(r.Form.Get("PageSize"))
+		start, end := (page-1)*size, page*size
+		if start > len(matched) {
+			start = len(matched)
+		}
+		if end > len(matched) {
+			end = len(matched)
+		}
+		resp["TotalCount"] = len(matched)
+		resp["DomainRecords"] = map[string]interface{}{"Record": matched[start:end]}
+	case "AddDomainRecord":
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		s.records = append(s.records, &alidnsclient.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+			DomainName: tea.String(r.Form.Get("DomainName")),
+			RecordId:   tea.String(id),
+			RR:         tea.String(r.Form.Get("RR")),
+			Type:       tea.String(r.Form.Get("Type")),
+			Value:      tea.String(r.Form.Get("Value")),
+			Line:       tea.String(r.Form.Get("Line")),
+		})
+		resp["RecordId"] = id
+	case "UpdateDomainRecordRemark":
+		if s.remarkFails {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"Code":"ServiceUnavailable","Message":"remark failed"}`)
+			return
+		}
+		for _, record := range s.records {
+			if tea.StringValue(record.RecordId) == r.Form.Get("RecordId") {
+				record.Remark = tea.String(r.Form.Get("Remark"))
+			}
+		}
+	case "DeleteDomainRecord":
+		for i, record := range s.records {
+			if tea.StringValue(record.RecordId) == r.Form.Get("RecordId") {
+				s.records = append(s.records[:i], s.records[i+1:]...)
+				break
+			}
+		}
+		resp["RecordId"] = r.Form.Get("RecordId")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"Code":"InvalidAction.NotFound","Message":"unknown action %s"}`, action)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestAliDNSProviderZone(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{nextID: 2000}
+	// more records than the max page size, so the zone is listed in 3 pages
+	for i := 0; i < 1100; i++ {
+		server.records = append(server.records, &alidnsclient.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+			DomainName: tea.String("example.com"),
+			RecordId:   tea.String(strconv.Itoa(1000 + i)),
+			RR:         tea.String("host" + strconv.Itoa(i)),
+			Type:       tea.String("A"),
+			Value:      tea.String("1.2.3.4"),
+			Line:       tea.String("default"),
+		})
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	p, err := provider.New(ctx, nil, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeAliyun,
+		Aliyun: dnsv1.AliyunProviderConfig{
+			AccessKeyID:     t.Name(),
+			AccessKeySecret: "test-secret",
+			Endpoint:        httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1100 || server.pages != 3 {
+		t.Fatalf("listed %d records in %d pages", len(records), server.pages)
+	}
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "host1099.example.com", Value: "1.2.3.4"}}
+	if id, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok || id != "2099" {
+		t.Fatalf("SearchRecord of the last page: %s %v %v", id, ok, err)
+	}
+
+	// the writes are applied to the snapshot without listing the zone again
+	created := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "www.example.com", Value: "5.6.7.8"}}
+	id, err := p.CreateRecord(ctx, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, ok, err := p.SearchRecord(ctx, created); err != nil || !ok || found != id {
+		t.Fatalf("SearchRecord after create: %s %v %v", found, ok, err)
+	}
+	if err := p.DeleteRecord(ctx, rec, &rec.Status.RecordID); err != nil {
+		t.Fatal(err)
+	}
+	if record, err := p.(provider.IDNSRecordGetter).GetRecord(ctx, "2099"); err != nil || record != nil {
+		t.Fatalf("GetRecord after delete: %+v %v", record, err)
+	}
+	if server.pages != 3 {
+		t.Fatalf("the zone is listed again after the writes, %d pages", server.pages)
+	}
+
+	// a failed write invalidates the snapshot
+	httpServer.Close()
+	if err := p.DeleteRecord(ctx, created, &id); err == nil {
+		t.Fatal("expected the delete to fail")
+	}
+	if _, err := p.(provider.IDNSRecordGetter).GetRecord(ctx, id); err == nil {
+		t.Fatal("expected the invalidated snapshot to be listed again")
+	}
+}
+
+func TestAliDNSProviderCreateRemarkFailure(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{remarkFails: true}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	p, err := provider.New(ctx, nil, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeAliyun,
+		Aliyun: dnsv1.AliyunProviderConfig{
+			AccessKeyID:     t.Name(),
+			AccessKeySecret: "test-secret",
+			Endpoint:        httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "www.example.com", Value: "1.2.3.4"}}
+	id, err := p.CreateRecord(ctx, rec)
+	if err == nil || id != "1" {
+		t.Fatalf("expected the id of the created record with the error: %q %v", id, err)
+	}
+
+	// the record is adopted by the id and marked by the update
+	server.mu.Lock()
+	server.remarkFails = false
+	server.mu.Unlock()
+	rec.Status.RecordID = id
+	if found, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok || found != id {
+		t.Fatalf("SearchRecord after the failed create: %q %v %v", found, ok, err)
+	}
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.records) != 1 || tea.StringValue(server.records[0].Remark) != provider.OwnerMarker(rec) {
+		t.Fatalf("expected the created record to be marked: %+v", server.records)
+	}
+}
+
+func TestAliDNSProviderSearch(t *testing.T) {
+	ctx := context.Background()
+	record := func(id string, rr string, line string) zoneRecord {
+		return &alidnsclient.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+			DomainName: tea.String("example.com"),
+			RecordId:   tea.String(id),
+			RR:         tea.String(rr),
+			Type:       tea.String("A"),
+			Value:      tea.String("1.2.3.4"),
+			Line:       tea.String(line),
+		}
+	}
+	server := &testServer{records: []zoneRecord{record("1", "www", "telecom"), record("2", "www", "default"), record("3", "www2", "default")}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	p, err := provider.New(ctx, nil, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeAliyun,
+		Aliyun: dnsv1.AliyunProviderConfig{
+			AccessKeyID:     t.Name(),
+			AccessKeySecret: "test-secret",
+			Endpoint:        httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the record is searched by the server before the zone is listed
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "www.example.com", Value: "1.2.3.4"}}
+	if id, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok || id != "2" {
+		t.Fatalf("SearchRecord before listing: %s %v %v", id, ok, err)
+	}
+	if server.pages != 0 || server.searches != 1 {
+		t.Fatalf("expected one search without listing the zone, %d pages %d searches", server.pages, server.searches)
+	}
+
+	// a record missed by the snapshot is searched by the server
+	if _, err := p.(provider.IDNSRecordLister).ListRecords(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	server.records = append(server.records, record("4", "api", "default"))
+	server.mu.Unlock()
+	missed := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "api.example.com", Value: "1.2.3.4"}}
+	if id, ok, err := p.SearchRecord(ctx, missed); err != nil || !ok || id != "4" {
+		t.Fatalf("SearchRecord of the missed record: %s %v %v", id, ok, err)
+	}
+	if _, ok, err := p.SearchRecord(ctx, missed); err != nil || !ok || server.searches != 2 {
+		t.Fatalf("expected the found record to be put to the snapshot, %d searches", server.searches)
+	}
+	absent := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "absent.example.com", Value: "1.2.3.4"}}
+	if id, ok, err := p.SearchRecord(ctx, absent); err != nil || ok {
+		t.Fatalf("SearchRecord of the absent record: %s %v %v", id, ok, err)
+	}
+	if server.pages != 1 {
+		t.Fatalf("the zone is listed %d times", server.pages)
+	}
+}
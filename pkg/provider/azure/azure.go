@@ -0,0 +1,350 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	"golang.org/x/oauth2"
+)
+
+// The metadata key of the ownership marker, metadata keys allow only letters, digits and underscores
+const ownerMetadataKey = "k8s_dns_manager"
+
+type zoneRecord = *util.AzureRecordSet
+
+// AzureProvider manages the record sets of an Azure DNS zone.
+// The record id is the name and type of the record set, e.g. `www.example.com. A`.
+type AzureProvider struct {
+	util     *util.AzureDNSUtils
+	spec     *dnsv1.DNSProviderSpec
+	zoneName string
+	zone     *provider.ZoneSnapshot[zoneRecord]
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func recordID(name string, rrtype string) string {
+	return fqdn(strings.ToLower(name)) + " " + rrtype
+}
+
+// relativeName returns the name of the record set relative to the zone, `@` for the apex
+func (p *AzureProvider) relativeName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == p.zoneName {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+p.zoneName)
+}
+
+func (p *AzureProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	recordSets, err := p.util.ListRecordSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(recordSets))
+	for _, rs := range recordSets {
+		zone[recordID(rs.Properties.FQDN, rs.RecordType())] = rs
+	}
+	return zone, nil
+}
+
+// newProperties builds the record set properties described by the DNSRecord
+func newProperties(rec *dnsv1.DNSRecord) (*util.AzureRecordSetProperties, error) {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	properties := &util.AzureRecordSetProperties{TTL: ttl, Metadata: map[string]string{}}
+	if marker := provider.OwnerMarker(rec); marker != "" {
+		properties.Metadata[ownerMetadataKey] = marker
+	}
+	value := rec.Spec.Value
+	fields := strings.Fields(value)
+	invalid := fmt.Errorf("invalid %s record value %q", rec.Spec.RecordType, value)
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeA:
+		properties.ARecords = []util.AzureARecord{{IPv4Address: value}}
+	case dnsv1.DNSRecordTypeAAAA:
+		properties.AAAARecords = []util.AzureAAAARecord{{IPv6Address: value}}
+	case dnsv1.DNSRecordTypeCNAME:
+		properties.CNAMERecord = &util.AzureCNAMERecord{CNAME: value}
+	case dnsv1.DNSRecordTypeNS:
+		properties.NSRecords = []util.AzureNSRecord{{NSDName: value}}
+	case dnsv1.DNSRecordTypeTXT:
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		// a TXT string is limited to 255 characters
+		var chunks []string
+		for len(value) > 255 {
+			chunks, value = append(chunks, value[:255]), value[255:]
+		}
+		properties.TXTRecords = []util.AzureTXTRecord{{Value: append(chunks, value)}}
+	case dnsv1.DNSRecordTypeMX:
+		if len(fields) != 2 {
+			return nil, invalid
+		}
+		preference, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, invalid
+		}
+		properties.MXRecords = []util.AzureMXRecord{{Preference: preference, Exchange: fields[1]}}
+	case dnsv1.DNSRecordTypeSRV:
+		if len(fields) != 4 {
+			return nil, invalid
+		}
+		numbers := make([]int, 3)
+		for i := range numbers {
+			n, err := strconv.Atoi(fields[i])
+			if err != nil {
+				return nil, invalid
+			}
+			numbers[i] = n
+		}
+		properties.SRVRecords = []util.AzureSRVRecord{{Priority: numbers[0], Weight: numbers[1], Port: numbers[2], Target: fields[3]}}
+	case dnsv1.DNSRecordTypeCAA:
+		if len(fields) < 3 {
+			return nil, invalid
+		}
+		flags, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, invalid
+		}
+		caaValue := strings.Join(fields[2:], " ")
+		if unquoted, err := strconv.Unquote(caaValue); err == nil {
+			caaValue = unquoted
+		}
+		properties.CAARecords = []util.AzureCAARecord{{Flags: flags, Tag: fields[1], Value: caaValue}}
+	default:
+		return nil, fmt.Errorf("record type %s is not supported by azure provider", rec.Spec.RecordType)
+	}
+	return properties, nil
+}
+
+// values returns the values of the record set in the form of DNSRecord values
+func values(rs zoneRecord) []string {
+	var values []string
+	properties := &rs.Properties
+	for _, r := range properties.ARecords {
+		values = append(values, r.IPv4Address)
+	}
+	for _, r := range properties.AAAARecords {
+		values = append(values, r.IPv6Address)
+	}
+	if r := properties.CNAMERecord; r != nil {
+		values = append(values, strings.TrimSuffix(r.CNAME, "."))
+	}
+	for _, r := range properties.NSRecords {
+		values = append(values, strings.TrimSuffix(r.NSDName, "."))
+	}
+	for _, r := range properties.TXTRecords {
+		values = append(values, strings.Join(r.Value, ""))
+	}
+	for _, r := range properties.MXRecords {
+		values = append(values, fmt.Sprintf("%d %s", r.Preference, strings.TrimSuffix(r.Exchange, ".")))
+	}
+	for _, r := range properties.SRVRecords {
+		values = append(values, fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, strings.TrimSuffix(r.Target, ".")))
+	}
+	for _, r := range properties.CAARecords {
+		values = append(values, fmt.Sprintf("%d %s %s", r.Flags, r.Tag, strconv.Quote(r.Value)))
+	}
+	return values
+}
+
+func recordSetEquals(current zoneRecord, desired zoneRecord) bool {
+	currentValues, desiredValues := values(current), values(desired)
+	return len(currentValues) == 1 && currentValues[0] == desiredValues[0] &&
+		current.Properties.TTL == desired.Properties.TTL &&
+		current.Properties.Metadata[ownerMetadataKey] == desired.Properties.Metadata[ownerMetadataKey]
+}
+
+func (p *AzureProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{MaxTTL: math.MaxInt32, Ownership: true}
+}
+
+func (p *AzureProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	id = recordID(rec.Spec.Name, string(rec.Spec.RecordType))
+	_, ok, err = p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+// put creates or replaces the record set described by the DNSRecord, returns the written record set
+func (p *AzureProvider) put(ctx context.Context, rec *dnsv1.DNSRecord, properties *util.AzureRecordSetProperties) (zoneRecord, error) {
+	rs := &util.AzureRecordSet{
+		Name:       p.relativeName(rec.Spec.Name),
+		Type:       "Microsoft.Network/dnszones/" + string(rec.Spec.RecordType),
+		Properties: *properties,
+	}
+	if err := p.util.PutRecordSet(ctx, string(rec.Spec.RecordType), rs.Name, properties); err != nil {
+		p.zone.Invalidate()
+		return nil, err
+	}
+	rs.Properties.FQDN = fqdn(strings.ToLower(rec.Spec.Name))
+	return rs, nil
+}
+
+func (p *AzureProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	properties, err := newProperties(rec)
+	if err != nil {
+		return "", err
+	}
+	rs, err := p.put(ctx, rec, properties)
+	if err != nil {
+		return "", err
+	}
+	id = recordID(rec.Spec.Name, string(rec.Spec.RecordType))
+	p.zone.Put(id, rs)
+	return id, nil
+}
+
+func (p *AzureProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	properties, err := newProperties(rec)
+	if err != nil {
+		return err
+	}
+	newID := recordID(rec.Spec.Name, string(rec.Spec.RecordType))
+	current, ok, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if ok && newID == *id {
+		if recordSetEquals(current, &util.AzureRecordSet{Properties: *properties}) {
+			return nil
+		}
+		// keep the metadata not written by k8s-dns-manager
+		for key, value := range current.Properties.Metadata {
+			if _, exists := properties.Metadata[key]; !exists && key != ownerMetadataKey {
+				properties.Metadata[key] = value
+			}
+		}
+	}
+	rs, err := p.put(ctx, rec, properties)
+	if err != nil {
+		return err
+	}
+	if ok && newID != *id {
+		// the name or type has changed, Azure has no batch api so the old record set is removed after the new one is written
+		if err := p.util.DeleteRecordSet(ctx, current.RecordType(), current.Name); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	p.zone.Remove(*id)
+	p.zone.Put(newID, rs)
+	rec.Status.RecordID = newID
+	return nil
+}
+
+func (p *AzureProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	name, rrtype, ok := strings.Cut(*id, " ")
+	if !ok {
+		return fmt.Errorf("invalid record id %q", *id)
+	}
+	if err := p.util.DeleteRecordSet(ctx, rrtype, p.relativeName(name)); err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	p.zone.Remove(*id)
+	return nil
+}
+
+func (p *AzureProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	rs, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	rec := providerRecord(rs)
+	return &rec, nil
+}
+
+func (p *AzureProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for _, rs := range zone {
+		if rs.Properties.SOARecord != nil || len(values(rs)) == 0 {
+			continue
+		}
+		records = append(records, providerRecord(rs))
+	}
+	return records, nil
+}
+
+func providerRecord(rs zoneRecord) provider.ProviderRecord {
+	rec := provider.ProviderRecord{
+		ID:         recordID(rs.Properties.FQDN, rs.RecordType()),
+		Name:       strings.TrimSuffix(rs.Properties.FQDN, "."),
+		RecordType: dnsv1.DNSRecordType(rs.RecordType()),
+		TTL:        rs.Properties.TTL,
+		Owner:      provider.ParseOwnerMarker(rs.Properties.Metadata[ownerMetadataKey]),
+	}
+	if values := values(rs); len(values) != 0 {
+		rec.Value = values[0]
+	}
+	return rec
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeAzure), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		cfg := &spec.Azure
+		clientSecret, err := args.SecretValue(cfg.ClientSecretSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		zoneName := cfg.ZoneName
+		if zoneName == "" {
+			zoneName = spec.DomainName
+		}
+		zoneName = strings.ToLower(strings.TrimSuffix(zoneName, "."))
+		account := util.AzureDNSAccount{
+			SubscriptionID: cfg.SubscriptionID,
+			ResourceGroup:  cfg.ResourceGroup,
+			ZoneName:       zoneName,
+			TenantID:       cfg.TenantID,
+			ClientID:       cfg.ClientID,
+			ClientSecret:   strings.TrimSpace(clientSecret),
+			Endpoint:       cfg.Endpoint,
+			AuthorityHost:  cfg.AuthorityHost,
+		}
+		// the token source is shared by the providers with the same credentials
+		tsKey := provider.LookupKey("Azure/tokenSource", account.Endpoint, account.AuthorityHost, account.TenantID, account.ClientID, account.ClientSecret)
+		ts, err := provider.CachedClient(tsKey, func() (oauth2.TokenSource, error) {
+			return util.NewAzureTokenSource(account), nil
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		dnsutil, err := util.NewAzureDNSUtils(account, ts)
+		if err != nil {
+			return nil, err
+		}
+		p := &AzureProvider{
+			util:     dnsutil,
+			spec:     spec,
+			zoneName: zoneName,
+		}
+		key := string(dnsv1.DNSProviderTypeAzure) + "/" + cfg.SubscriptionID + "/" + cfg.ResourceGroup + "/" + zoneName
+		p.zone = provider.GetZoneSnapshot(key, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
@@ -0,0 +1,152 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const zonePath = "/subscriptions/test-sub/resourceGroups/test-rg/providers/Microsoft.Network/dnsZones/example.com"
+
+// testServer is a local stand-in of the Entra ID token endpoint and the Azure DNS record set endpoints
+type testServer struct {
+	mu         sync.Mutex
+	recordSets map[string]*util.AzureRecordSet
+	tokens     int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/test-tenant/oauth2/v2.0/token" {
+		r.ParseForm()
+		if r.Form.Get("client_id") != "test-client" || r.Form.Get("client_secret") != "test-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		s.tokens++
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "test-token", "token_type": "Bearer", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer test-token" || r.URL.Query().Get("api-version") != "2018-05-01" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": "AuthenticationFailed", "message": "Authentication failed"}})
+		return
+	}
+	if r.URL.Path == zonePath+"/all" {
+		recordSets := []*util.AzureRecordSet{}
+		for _, rs := range s.recordSets {
+			recordSets = append(recordSets, rs)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": recordSets})
+		return
+	}
+	rrtype, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, zonePath+"/"), "/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		var rs util.AzureRecordSet
+		json.NewDecoder(r.Body).Decode(&rs)
+		rs.Name, rs.Type = name, "Microsoft.Network/dnszones/"+rrtype
+		rs.Properties.FQDN = "example.com."
+		if name != "@" {
+			rs.Properties.FQDN = name + ".example.com."
+		}
+		s.recordSets[rrtype+"/"+name] = &rs
+		json.NewEncoder(w).Encode(rs)
+	case http.MethodDelete:
+		delete(s.recordSets, rrtype+"/"+name)
+	}
+}
+
+func TestAzureProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{recordSets: map[string]*util.AzureRecordSet{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "azure"},
+		Data:       map[string][]byte{"clientSecret": []byte("test-secret")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeAzure,
+		Azure: dnsv1.AzureProviderConfig{
+			SubscriptionID:        "test-sub",
+			ResourceGroup:         "test-rg",
+			TenantID:              "test-tenant",
+			ClientID:              "test-client",
+			ClientSecretSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "azure", Key: "clientSecret"},
+			Endpoint:              httpServer.URL,
+			AuthorityHost:         httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeMX,
+		Name:       "example.com",
+		Value:      "10 mail.example.com",
+	}}
+	rec.Namespace = "default"
+	rec.Name = "mx"
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := server.recordSets["MX/@"]
+	if id != "example.com. MX" || created == nil || created.Properties.MXRecords[0].Exchange != "mail.example.com" ||
+		created.Properties.Metadata[ownerMetadataKey] != provider.OwnerMarker(rec) {
+		t.Fatalf("unexpected id %q and record sets %+v", id, server.recordSets)
+	}
+
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeA
+	rec.Spec.Name = "www.example.com"
+	rec.Spec.Value = "10.0.0.1"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.recordSets) != 1 || server.recordSets["A/www"] == nil {
+		t.Fatalf("unexpected record sets %+v", server.recordSets)
+	}
+	id = rec.Status.RecordID
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "www.example.com. A" || records[0].Value != "10.0.0.1" || !records[0].Owner.IsLocal() {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.recordSets) != 0 || server.tokens != 1 {
+		t.Fatalf("unexpected record sets %+v after %d tokens", server.recordSets, server.tokens)
+	}
+}
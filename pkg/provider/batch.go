@@ -0,0 +1,212 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+)
+
+// ChangeAction is the action of a RecordChange
+type ChangeAction string
+
+const (
+	ChangeActionCreate ChangeAction = "Create"
+	ChangeActionUpdate ChangeAction = "Update"
+	ChangeActionDelete ChangeAction = "Delete"
+)
+
+// RecordChange is a change of a DNSRecord applied to the provider
+type RecordChange struct {
+	Action ChangeAction
+	Record *dnsv1.DNSRecord
+	// The id of the record to update or delete, set to the id of the created record once applied.
+	// Like UpdateRecord, the provider sets Record.Status.RecordID if the id is changed by an update.
+	ID string
+}
+
+// IDNSBatchProvider is implemented by providers which can apply the changes of many records in one call
+type IDNSBatchProvider interface {
+	// ApplyChanges applies all changes or none of them
+	ApplyChanges(ctx context.Context, changes []*RecordChange) error
+}
+
+// ApplyChange applies the change by the per-record methods of the provider without batching,
+// the call takes a token from the rate limit of the spec and is bound to its timeout
+func ApplyChange(ctx context.Context, key string, spec *dnsv1.DNSProviderSpec, p IDNSProvider, change *RecordChange) (err error) {
+	ctx, cancel, err := CallContext(ctx, key, spec)
+	defer cancel()
+	if err != nil {
+		return err
+	}
+	switch change.Action {
+	case ChangeActionCreate:
+		change.ID, err = p.CreateRecord(ctx, change.Record)
+	case ChangeActionUpdate:
+		err = p.UpdateRecord(ctx, change.Record, &change.ID)
+	case ChangeActionDelete:
+		err = p.DeleteRecord(ctx, change.Record, &change.ID)
+	}
+	return err
+}
+
+// changeRequest is a group of changes which must be applied in the same batch
+type changeRequest struct {
+	provider IDNSBatchProvider
+	changes  []*RecordChange
+	done     chan error
+}
+
+// changeBatcher applies the changes of a DNSProvider,
+// the changes requested while waiting for the batch window or a running batch are applied together.
+type changeBatcher struct {
+	mu      sync.Mutex
+	key     string
+	spec    *dnsv1.DNSProviderSpec
+	pending []*changeRequest
+	running bool
+}
+
+var (
+	batchersMu sync.Mutex
+	batchers   = map[string]*changeBatcher{}
+)
+
+// getChangeBatcher returns the batcher shared by the DNSRecords of the key, which uses the latest spec
+func getChangeBatcher(key string, spec *dnsv1.DNSProviderSpec) *changeBatcher {
+	batchersMu.Lock()
+	defer batchersMu.Unlock()
+	b, ok := batchers[key]
+	if !ok {
+		b = &changeBatcher{key: key}
+		batchers[key] = b
+	}
+	b.mu.Lock()
+	b.spec = spec
+	b.mu.Unlock()
+	return b
+}
+
+// ApplyChanges applies the changes of a DNSRecord to the provider and waits until they are applied.
+// The changes of a batch provider are coalesced with the changes of the other DNSRecords of the key,
+// usually the name of the DNSProvider, within the batch window of the spec.
+// Other providers apply the changes one by one.
+func ApplyChanges(ctx context.Context, key string, spec *dnsv1.DNSProviderSpec, p IDNSProvider, changes ...*RecordChange) error {
+	batchProvider, ok := p.(IDNSBatchProvider)
+	if !ok {
+		for _, change := range changes {
+			if err := ApplyChange(ctx, key, spec, p, change); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	req := &changeRequest{provider: batchProvider, changes: changes, done: make(chan error, 1)}
+	b := getChangeBatcher(key, spec)
+	b.mu.Lock()
+	b.pending = append(b.pending, req)
+	if !b.running {
+		b.running = true
+		go b.run()
+	}
+	b.mu.Unlock()
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+	}
+	if b.cancel(req) {
+		return ctx.Err()
+	}
+	// the request is being applied, wait for it so the id of a created record is not lost
+	return <-req.done
+}
+
+// cancel removes the request from the queue, returns false if it's already taken by a batch
+func (b *changeBatcher) cancel(req *changeRequest) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, pending := range b.pending {
+		if pending == req {
+			b.pending = append(b.pending[:i:i], b.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (b *changeBatcher) run() {
+	for {
+		b.mu.Lock()
+		window := b.spec.BatchWindow()
+		b.mu.Unlock()
+		if window > 0 {
+			time.Sleep(window)
+		}
+
+		batch := b.next()
+		if len(batch) == 0 {
+			return
+		}
+		b.apply(batch)
+	}
+}
+
+// next takes the requests of the next batch from the queue, stops the batcher if the queue is empty
+func (b *changeBatcher) next() []*changeRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) == 0 {
+		b.running = false
+		return nil
+	}
+	count := 0
+	for i, req := range b.pending {
+		// a request larger than the batch size is applied alone
+		if i > 0 && count+len(req.changes) > b.spec.BatchSize() {
+			batch := b.pending[:i]
+			b.pending = b.pending[i:]
+			return batch
+		}
+		count += len(req.changes)
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// apply applies the batch, each call is not bound to the context of any request but to the timeout of the spec,
+// and takes a token from the rate limit of the spec
+func (b *changeBatcher) apply(batch []*changeRequest) {
+	b.mu.Lock()
+	spec := b.spec
+	b.mu.Unlock()
+	applyChanges := func(p IDNSBatchProvider, changes []*RecordChange) error {
+		ctx, cancel, err := CallContext(context.Background(), b.key, spec)
+		defer cancel()
+		if err != nil {
+			return err
+		}
+		return p.ApplyChanges(ctx, changes)
+	}
+
+	var changes []*RecordChange
+	for _, req := range batch {
+		changes = append(changes, req.changes...)
+	}
+	// the provider of the latest request has the latest spec
+	err := applyChanges(batch[len(batch)-1].provider, changes)
+	if class, _ := ClassifyError(err); err != nil && len(batch) > 1 && class != ErrorClassRateLimited {
+		// the whole batch is rejected if any change is invalid, retry the requests one by one
+		for _, req := range batch {
+			req.done <- applyChanges(req.provider, req.changes)
+		}
+		return
+	}
+	for _, req := range batch {
+		req.done <- err
+	}
+}
@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+// fakeBatchProvider records the batches, rejecting a whole batch if it contains the invalid record
+type fakeBatchProvider struct {
+	mu      sync.Mutex
+	batches [][]*RecordChange
+	invalid string
+	err     error
+}
+
+func (p *fakeBatchProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (string, bool, error) {
+	return "", false, nil
+}
+
+func (p *fakeBatchProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (string, error) {
+	change := &RecordChange{Action: ChangeActionCreate, Record: rec}
+	err := p.ApplyChanges(ctx, []*RecordChange{change})
+	return change.ID, err
+}
+
+func (p *fakeBatchProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) error {
+	return p.ApplyChanges(ctx, []*RecordChange{{Action: ChangeActionUpdate, Record: rec, ID: *id}})
+}
+
+func (p *fakeBatchProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) error {
+	return p.ApplyChanges(ctx, []*RecordChange{{Action: ChangeActionDelete, Record: rec, ID: *id}})
+}
+
+func (p *fakeBatchProvider) ApplyChanges(ctx context.Context, changes []*RecordChange) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.batches = append(p.batches, changes)
+	if p.err != nil {
+		return p.err
+	}
+	for _, change := range changes {
+		if change.Record.Spec.Name == p.invalid {
+			return errors.New("invalid record " + p.invalid)
+		}
+	}
+	for _, change := range changes {
+		if change.Action == ChangeActionCreate {
+			change.ID = change.Record.Spec.Name
+		}
+	}
+	return nil
+}
+
+func newChangeRequest(p IDNSBatchProvider, names ...string) *changeRequest {
+	req := &changeRequest{provider: p, done: make(chan error, 1)}
+	for _, name := range names {
+		req.changes = append(req.changes, &RecordChange{Action: ChangeActionCreate, Record: &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{Name: name}}})
+	}
+	return req
+}
+
+func TestChangeBatcherNext(t *testing.T) {
+	p := &fakeBatchProvider{}
+	a, b := newChangeRequest(p, "a1", "a2"), newChangeRequest(p, "b")
+	c, d, e := newChangeRequest(p, "c"), newChangeRequest(p, "d1", "d2", "d3", "d4"), newChangeRequest(p, "e")
+	batcher := &changeBatcher{
+		spec:    &dnsv1.DNSProviderSpec{Batch: &dnsv1.DNSProviderBatchConfig{Size: 3}},
+		pending: []*changeRequest{a, b, c, d, e},
+		running: true,
+	}
+
+	// the changes of a request are never split, a request larger than the batch size is applied alone
+	want := [][]*changeRequest{{a, b}, {c}, {d}, {e}}
+	for i, batch := range want {
+		got := batcher.next()
+		if len(got) != len(batch) {
+			t.Fatalf("batch %d: got %d requests, want %d", i, len(got), len(batch))
+		}
+		for j := range batch {
+			if got[j] != batch[j] {
+				t.Fatalf("batch %d: unexpected request %d", i, j)
+			}
+		}
+	}
+	if batch := batcher.next(); batch != nil || batcher.running {
+		t.Fatalf("the batcher is not stopped once the queue is empty: %d requests", len(batch))
+	}
+}
+
+func TestChangeBatcherApply(t *testing.T) {
+	p := &fakeBatchProvider{invalid: "invalid"}
+	batcher := &changeBatcher{key: t.Name(), spec: &dnsv1.DNSProviderSpec{}}
+	valid, invalid, other := newChangeRequest(p, "valid"), newChangeRequest(p, "invalid"), newChangeRequest(p, "other")
+	batcher.apply([]*changeRequest{valid, invalid, other})
+
+	// the rejected batch is retried request by request
+	if len(p.batches) != 4 || len(p.batches[0]) != 3 {
+		t.Fatalf("unexpected batches %+v", p.batches)
+	}
+	if err := <-valid.done; err != nil || valid.changes[0].ID != "valid" {
+		t.Fatalf("the valid change is not applied: %v", err)
+	}
+	if err := <-invalid.done; err == nil {
+		t.Fatal("expected the invalid change to fail")
+	}
+	if err := <-other.done; err != nil || other.changes[0].ID != "other" {
+		t.Fatalf("the other change is not applied: %v", err)
+	}
+
+	// rate limited batches are not retried, which would only be rate limited again
+	p = &fakeBatchProvider{err: &util.RESTError{StatusCode: http.StatusTooManyRequests}}
+	valid, other = newChangeRequest(p, "valid"), newChangeRequest(p, "other")
+	batcher.apply([]*changeRequest{valid, other})
+	if len(p.batches) != 1 {
+		t.Fatalf("the rate limited batch is retried: %+v", p.batches)
+	}
+	if <-valid.done == nil || <-other.done == nil {
+		t.Fatal("expected the rate limited changes to fail")
+	}
+}
+
+func TestApplyChangesCancel(t *testing.T) {
+	p := &fakeBatchProvider{}
+	spec := &dnsv1.DNSProviderSpec{}
+	// the batcher is busy, so the request stays in the queue
+	batcher := getChangeBatcher(t.Name(), spec)
+	batcher.running = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	change := &RecordChange{Action: ChangeActionCreate, Record: &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{Name: "cancelled"}}}
+	if err := ApplyChanges(ctx, t.Name(), spec, p, change); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// the cancelled request is not applied later
+	if batch := batcher.next(); batch != nil {
+		t.Fatalf("the cancelled request is still queued: %d requests", len(batch))
+	}
+	if len(p.batches) != 0 || change.ID != "" {
+		t.Fatalf("the cancelled change is applied: %+v", p.batches)
+	}
+}
+
+func TestApplyChange(t *testing.T) {
+	p := &fakeBatchProvider{}
+	change := &RecordChange{Action: ChangeActionCreate, Record: &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{Name: "single"}}}
+	if err := ApplyChange(context.Background(), t.Name(), &dnsv1.DNSProviderSpec{}, p, change); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.batches) != 1 || change.ID != "single" {
+		t.Fatalf("the change is not applied alone: %+v %q", p.batches, change.ID)
+	}
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// cachedClient is a client of a provider API shared by the providers with the same config
+type cachedClient struct {
+	client   any
+	close    func()
+	lastUsed time.Time
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]*cachedClient{}
+	// the timeout of the reconciles using the providers, default is the default of --reconcile-timeout
+	reconcileTimeout = 5 * time.Minute
+)
+
+// SetReconcileTimeout sets the timeout of the reconciles using the providers, unlimited if 0.
+// The cached clients unused for longer are closed, as no reconcile can still use them.
+func SetReconcileTimeout(timeout time.Duration) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	reconcileTimeout = timeout
+}
+
+// CachedClient returns the client cached by the key, or creates it by create.
+// As providers are created on every reconcile, the client is shared by the providers with the same key,
+// which must cover the whole config of the client, e.g. by LookupKey, so the providers with different configs
+// never replace the clients of each other. A client unused for longer than the reconcile timeout is closed by close,
+// clients are never closed if the reconcile timeout is unlimited.
+func CachedClient[T any](key string, create func() (T, error), close func(T)) (T, error) {
+	clientsMu.Lock()
+	idle := takeIdleClients(time.Now())
+	defer func() {
+		for _, cached := range idle {
+			cached.close()
+		}
+	}()
+	defer clientsMu.Unlock()
+
+	if cached, ok := clients[key]; ok {
+		cached.lastUsed = time.Now()
+		return cached.client.(T), nil
+	}
+	client, err := create()
+	if err != nil {
+		return client, err
+	}
+	cached := &cachedClient{client: client, lastUsed: time.Now(), close: func() {}}
+	if close != nil {
+		cached.close = func() { close(client) }
+	}
+	clients[key] = cached
+	return client, nil
+}
+
+// takeIdleClients removes the clients unused for longer than the reconcile timeout from the cache
+func takeIdleClients(now time.Time) []*cachedClient {
+	if reconcileTimeout <= 0 {
+		return nil
+	}
+	var idle []*cachedClient
+	for key, cached := range clients {
+		if now.Sub(cached.lastUsed) > reconcileTimeout {
+			idle = append(idle, cached)
+			delete(clients, key)
+		}
+	}
+	return idle
+}
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+type testClient struct {
+	config string
+	closed bool
+}
+
+func TestCachedClient(t *testing.T) {
+	SetReconcileTimeout(100 * time.Millisecond)
+	t.Cleanup(func() { SetReconcileTimeout(5 * time.Minute) })
+
+	created := 0
+	get := func(config string) *testClient {
+		client, err := CachedClient(LookupKey(t.Name(), config), func() (*testClient, error) {
+			created++
+			return &testClient{config: config}, nil
+		}, func(c *testClient) { c.closed = true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	// the providers with different configs keep their own clients
+	a, b := get("a"), get("b")
+	if get("a") != a || get("b") != b || created != 2 {
+		t.Fatalf("the clients are replaced, created %d clients", created)
+	}
+
+	// the client unused for longer than the reconcile timeout is closed
+	time.Sleep(60 * time.Millisecond)
+	get("a")
+	time.Sleep(60 * time.Millisecond)
+	if get("a") != a || a.closed {
+		t.Fatal("the client in use is closed")
+	}
+	if !b.closed {
+		t.Fatal("the idle client is not closed")
+	}
+	if get("b") == b || created != 3 {
+		t.Fatal("the closed client is reused")
+	}
+}
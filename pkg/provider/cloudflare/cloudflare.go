@@ -2,32 +2,106 @@ package cloudflare
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudflare/cloudflare-go"
 	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
 	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
 )
 
 const (
 	AnnotationKeyProxied = "dns.xzzpig.com/record-proxied"
+	// The comment of the record, written after the owner marker
+	AnnotationKeyComment = "dns.xzzpig.com/record-cloudflare-comment"
+	// The comma separated tags of the record in the form of `name:value`, added to spec.cloudflare.tags of the provider
+	AnnotationKeyTags = "dns.xzzpig.com/record-cloudflare-tags"
 )
 
+// CloudflareProvider manages the records of a zone, or of many zones of the token in multi-zone mode.
+// In multi-zone mode the record id is prefixed by the zone id, e.g. `<zone id>/<record id>`.
 type CloudflareProvider struct {
 	spec *dnsv1.DNSProviderSpec
 	api  *cloudflare.API
-	zone *cloudflare.Zone
+	// The managed zones sorted by the length of the name in descending order, so the first matching zone has the longest suffix
+	zones     []cloudflare.Zone
+	multiZone bool
+}
+
+// zoneOf returns the zone of the record name, the zone with the longest matching suffix in multi-zone mode
+func (p *CloudflareProvider) zoneOf(name string) (*cloudflare.Zone, error) {
+	if !p.multiZone {
+		return &p.zones[0], nil
+	}
+	for i := range p.zones {
+		if name == p.zones[i].Name || strings.HasSuffix(name, "."+p.zones[i].Name) {
+			return &p.zones[i], nil
+		}
+	}
+	return nil, &provider.ClassifiedError{Class: provider.ErrorClassPermanent, Err: fmt.Errorf("no cloudflare zone of %s", name)}
+}
+
+func (p *CloudflareProvider) recordID(zoneID string, id string) string {
+	if !p.multiZone {
+		return id
+	}
+	return zoneID + "/" + id
+}
+
+// parseRecordID returns the zone id and the cloudflare id of the record id
+func (p *CloudflareProvider) parseRecordID(id string) (zoneID string, recordID string, err error) {
+	if !p.multiZone {
+		return p.zones[0].ID, id, nil
+	}
+	zoneID, recordID, ok := strings.Cut(id, "/")
+	if !ok {
+		return "", "", fmt.Errorf("invalid record id %q, the zone id is missing", id)
+	}
+	return zoneID, recordID, nil
+}
+
+func (p *CloudflareProvider) Zones() []string {
+	if !p.multiZone {
+		return nil
+	}
+	names := make([]string, 0, len(p.zones))
+	for _, zone := range p.zones {
+		names = append(names, zone.Name)
+	}
+	return names
+}
+
+func (p *CloudflareProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{
+		// 1 is automatic
+		MinTTL:      1,
+		MaxTTL:      86400,
+		Ownership:   true,
+		Annotations: []string{AnnotationKeyProxied, AnnotationKeyComment, AnnotationKeyTags},
+	}
 }
 
 func (p *CloudflareProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	zone, err := p.zoneOf(rec.Spec.Name)
+	if err != nil {
+		return "", false, err
+	}
 	if rec.Status.RecordID != "" {
-		record, err := p.api.GetDNSRecord(ctx, cloudflare.ZoneIdentifier(p.zone.ID), rec.Status.RecordID)
-		if err == nil {
-			return record.ID, true, nil
+		// a record in another zone can not be renamed to the name
+		if zoneID, recordID, err := p.parseRecordID(rec.Status.RecordID); err == nil && zoneID == zone.ID {
+			record, err := p.api.GetDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), recordID)
+			if err == nil {
+				return p.recordID(zone.ID, record.ID), true, nil
+			}
 		}
 	}
 
-	records, _, err := p.api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(p.zone.ID), cloudflare.ListDNSRecordsParams{
+	records, _, err := p.api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zone.ID), cloudflare.ListDNSRecordsParams{
 		Name: rec.Spec.Name,
 	})
 	if err != nil {
@@ -36,7 +110,7 @@ func (p *CloudflareProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRec
 	if len(records) == 0 {
 		return "", false, nil
 	}
-	rec.Status.RecordID = records[0].ID
+	rec.Status.RecordID = p.recordID(zone.ID, records[0].ID)
 	return rec.Status.RecordID, true, nil
 }
 
@@ -52,34 +126,112 @@ func (p *CloudflareProvider) proxied(rec *dnsv1.DNSRecord) bool {
 	}
 }
 
+// ttl returns the ttl of the record, the ttl of proxied records is always 1 (automatic)
+func ttl(rec *dnsv1.DNSRecord, proxied bool) int {
+	if proxied {
+		return 1
+	}
+	if rec.Spec.TTL != nil {
+		return *rec.Spec.TTL
+	}
+	return config.GetConfig().Default.Record.TTL
+}
+
+// comment returns the comment of the record, the owner marker followed by the comment annotation,
+// which tells the records managed by the cluster and their DNSRecords in the Cloudflare dashboard
+func comment(rec *dnsv1.DNSRecord) string {
+	marker, text := provider.OwnerMarker(rec), strings.TrimSpace(rec.Annotations[AnnotationKeyComment])
+	if marker == "" || text == "" {
+		return marker + text
+	}
+	return marker + " " + text
+}
+
+// parseComment splits the comment written by comment into the owner and the comment annotation
+func parseComment(comment string) (*provider.RecordOwner, string) {
+	marker, text, _ := strings.Cut(comment, " ")
+	if owner := provider.ParseOwnerMarker(marker); owner != nil {
+		return owner, text
+	}
+	return nil, comment
+}
+
+// tags returns the sorted tags of the provider and the record
+func (p *CloudflareProvider) tags(rec *dnsv1.DNSRecord) []string {
+	seen := make(map[string]bool)
+	tags := []string{}
+	for _, tag := range append(append([]string{}, p.spec.Cloudflare.Tags...), strings.Split(rec.Annotations[AnnotationKeyTags], ",")...) {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func tagsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *CloudflareProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
-	record, err := p.api.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(p.zone.ID), cloudflare.CreateDNSRecordParams{
+	zone, err := p.zoneOf(rec.Spec.Name)
+	if err != nil {
+		return "", err
+	}
+	proxied := p.proxied(rec)
+	record, err := p.api.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zone.ID), cloudflare.CreateDNSRecordParams{
 		Type:    string(rec.Spec.RecordType),
 		Name:    rec.Spec.Name,
 		Content: rec.Spec.Value,
-		Proxied: cloudflare.BoolPtr(p.proxied(rec)),
+		TTL:     ttl(rec, proxied),
+		Proxied: cloudflare.BoolPtr(proxied),
+		Comment: comment(rec),
+		Tags:    p.tags(rec),
 	})
 	if err != nil {
 		return "", err
 	}
-	return record.ID, nil
+	return p.recordID(zone.ID, record.ID), nil
 }
 
 func (p *CloudflareProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
-	record, err := p.api.GetDNSRecord(ctx, cloudflare.ZoneIdentifier(p.zone.ID), *id)
+	zoneID, recordID, err := p.parseRecordID(*id)
+	if err != nil {
+		return err
+	}
+	record, err := p.api.GetDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), recordID)
 	if err != nil {
 		return err
 	}
 	proxied := p.proxied(rec)
-	if record.Name == rec.Spec.Name && record.Type == string(rec.Spec.RecordType) && record.Content == rec.Spec.Value && *record.Proxied == proxied {
+	ttl, comment, tags := ttl(rec, proxied), comment(rec), p.tags(rec)
+	if record.Name == rec.Spec.Name && record.Type == string(rec.Spec.RecordType) && record.Content == rec.Spec.Value &&
+		(record.Proxied != nil && *record.Proxied) == proxied && record.TTL == ttl &&
+		record.Comment == comment && tagsEqual(record.Tags, tags) {
 		return nil
 	}
-	_, err = p.api.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(p.zone.ID), cloudflare.UpdateDNSRecordParams{
-		ID:      *id,
+	_, err = p.api.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.UpdateDNSRecordParams{
+		ID:      recordID,
 		Type:    string(rec.Spec.RecordType),
 		Name:    rec.Spec.Name,
 		Content: rec.Spec.Value,
+		TTL:     ttl,
 		Proxied: cloudflare.BoolPtr(proxied),
+		Comment: comment,
+		Tags:    tags,
 	})
 	if err != nil {
 		return err
@@ -88,10 +240,138 @@ func (p *CloudflareProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRec
 }
 
 func (p *CloudflareProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
-	return p.api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(p.zone.ID), *id)
+	zoneID, recordID, err := p.parseRecordID(*id)
+	if err != nil {
+		return err
+	}
+	return p.api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), recordID)
+}
+
+func (p *CloudflareProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	zoneID, recordID, err := p.parseRecordID(id)
+	if err != nil {
+		return nil, err
+	}
+	record, err := p.api.GetDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), recordID)
+	if err != nil {
+		return nil, err
+	}
+	rec := providerRecord(p.recordID(zoneID, record.ID), &record)
+	return &rec, nil
+}
+
+func (p *CloudflareProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	var result []provider.ProviderRecord
+	for _, zone := range p.zones {
+		records, _, err := p.api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zone.ID), cloudflare.ListDNSRecordsParams{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range records {
+			result = append(result, providerRecord(p.recordID(zone.ID, records[i].ID), &records[i]))
+		}
+	}
+	return result, nil
+}
+
+func providerRecord(id string, record *cloudflare.DNSRecord) provider.ProviderRecord {
+	owner, text := parseComment(record.Comment)
+	rec := provider.ProviderRecord{
+		ID:         id,
+		Name:       record.Name,
+		RecordType: dnsv1.DNSRecordType(record.Type),
+		Value:      record.Content,
+		TTL:        record.TTL,
+		Owner:      owner,
+		Annotations: map[string]string{
+			AnnotationKeyProxied: strconv.FormatBool(record.Proxied != nil && *record.Proxied),
+		},
+	}
+	if text != "" {
+		rec.Annotations[AnnotationKeyComment] = text
+	}
+	if len(record.Tags) != 0 {
+		rec.Annotations[AnnotationKeyTags] = strings.Join(record.Tags, ",")
+	}
+	return rec
+}
+
+// loadZones returns the zones managed by the provider, the lookup is shared by the reconciles of all records
+// and cached for zoneSyncInterval, so the zones are not listed on every reconcile
+func loadZones(ctx context.Context, api *cloudflare.API, spec *dnsv1.DNSProviderSpec) ([]cloudflare.Zone, error) {
+	cfg := &spec.Cloudflare
+	if cfg.MultiZone {
+		key := provider.LookupKey("Cloudflare/zones", cfg)
+		return provider.CachedLookup(key, spec.ZoneSyncDuration(), func() ([]cloudflare.Zone, error) {
+			return listZones(ctx, api, cfg)
+		})
+	}
+
+	zoneName := cfg.ZoneName
+	if zoneName == "" {
+		zoneName = spec.DomainName
+	}
+	key := provider.LookupKey("Cloudflare/zone", cfg, zoneName)
+	return provider.CachedLookup(key, spec.ZoneSyncDuration(), func() ([]cloudflare.Zone, error) {
+		zoneID, err := api.ZoneIDByName(zoneName)
+		if err != nil {
+			return nil, err
+		}
+		zone, err := api.ZoneDetails(ctx, zoneID)
+		if err != nil {
+			return nil, err
+		}
+		return []cloudflare.Zone{zone}, nil
+	})
+}
+
+// listZones returns the active zones of the token managed in multi-zone mode, sorted by the length of the name in descending order
+func listZones(ctx context.Context, api *cloudflare.API, cfg *dnsv1.CloudflareProviderConfig) ([]cloudflare.Zone, error) {
+	resp, err := api.ListZonesContext(ctx, cloudflare.WithZoneFilters("", cfg.AccountID, "active"))
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(cfg.Zones))
+	for _, name := range cfg.Zones {
+		wanted[strings.ToLower(strings.TrimSuffix(name, "."))] = true
+	}
+	all := len(wanted) == 0
+	var zones []cloudflare.Zone
+	for _, zone := range resp.Result {
+		if all || wanted[zone.Name] {
+			zones = append(zones, zone)
+			delete(wanted, zone.Name)
+		}
+	}
+	for name := range wanted {
+		return nil, fmt.Errorf("cloudflare zone %s not found", name)
+	}
+	if len(zones) == 0 {
+		return nil, errors.New("no active cloudflare zone found")
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		return len(zones[i].Name) > len(zones[j].Name)
+	})
+	return zones, nil
+}
+
+// classifyError classifies the typed errors of cloudflare-go
+func classifyError(err error) (provider.ErrorClass, time.Duration, bool) {
+	var typed interface{ Type() cloudflare.ErrorType }
+	if !errors.As(err, &typed) {
+		return "", 0, false
+	}
+	switch typed.Type() {
+	case cloudflare.ErrorTypeRateLimit:
+		return provider.ErrorClassRateLimited, 0, true
+	case cloudflare.ErrorTypeAuthentication, cloudflare.ErrorTypeAuthorization, cloudflare.ErrorTypeRequest:
+		return provider.ErrorClassPermanent, 0, true
+	}
+	return provider.ErrorClassRetryable, 0, true
 }
 
 func init() {
+	provider.RegisterErrorClassifier(classifyError)
 	provider.Register(string(dnsv1.DNSProviderTypeCloudflare), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
 		spec := args.Spec
 		p := &CloudflareProvider{spec: spec}
@@ -111,20 +391,12 @@ func init() {
 			return nil, fmt.Errorf("cloudflare api token or key and email is required")
 		}
 
-		zoneName := spec.Cloudflare.ZoneName
-		if zoneName == "" {
-			zoneName = spec.DomainName
-		}
-		zoneID, err := p.api.ZoneIDByName(zoneName)
-		if err != nil {
-			return nil, err
-		}
-
-		zone, err := p.api.ZoneDetails(args.Ctx, zoneID)
+		zones, err := loadZones(args.Ctx, p.api, spec)
 		if err != nil {
 			return nil, err
 		}
-		p.zone = &zone
+		p.zones = zones
+		p.multiZone = spec.Cloudflare.MultiZone
 
 		return p, nil
 	})
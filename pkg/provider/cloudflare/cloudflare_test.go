@@ -0,0 +1,282 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+// testServer is a local stand-in of the zones and DNS records API of Cloudflare
+type testServer struct {
+	mu      sync.Mutex
+	zones   []cloudflare.Zone
+	records map[string]*cloudflare.DNSRecord
+	updates int
+	nextID  int
+	// the requests listing the zones
+	lists int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "zones" && r.Method == http.MethodGet {
+		s.lists++
+		zones := []cloudflare.Zone{}
+		for _, zone := range s.zones {
+			if zone.Status == r.URL.Query().Get("status") {
+				zones = append(zones, zone)
+			}
+		}
+		s.write(w, zones)
+		return
+	}
+	if len(parts) < 3 || parts[0] != "zones" || parts[2] != "dns_records" {
+		http.NotFound(w, r)
+		return
+	}
+	zoneID := parts[1]
+	switch {
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		records := []cloudflare.DNSRecord{}
+		for _, record := range s.records {
+			if record.ZoneID == zoneID && (r.URL.Query().Get("name") == "" || record.Name == r.URL.Query().Get("name")) {
+				records = append(records, *record)
+			}
+		}
+		s.write(w, records)
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		var record cloudflare.DNSRecord
+		json.NewDecoder(r.Body).Decode(&record)
+		s.nextID++
+		record.ID = fmt.Sprintf("record-%d", s.nextID)
+		record.ZoneID = zoneID
+		s.records[record.ID] = &record
+		s.write(w, record)
+	case len(parts) == 4:
+		record, ok := s.records[parts[3]]
+		if !ok || record.ZoneID != zoneID {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":81044,"message":"Record does not exist."}]}`)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.write(w, record)
+		case http.MethodPatch:
+			s.updates++
+			json.NewDecoder(r.Body).Decode(record)
+			s.write(w, record)
+		case http.MethodDelete:
+			delete(s.records, record.ID)
+			s.write(w, map[string]string{"id": record.ID})
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *testServer) write(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"errors":      []interface{}{},
+		"messages":    []interface{}{},
+		"result":      result,
+		"result_info": map[string]int{"page": 1, "total_pages": 1},
+	})
+}
+
+func newProvider(t *testing.T, server *testServer, cfg dnsv1.CloudflareProviderConfig) *CloudflareProvider {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	api, err := cloudflare.NewWithAPIToken("test-token", cloudflare.BaseURL(httpServer.URL), cloudflare.UsingRateLimit(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &CloudflareProvider{
+		spec:  &dnsv1.DNSProviderSpec{DomainName: "example.com", ProviderType: dnsv1.DNSProviderTypeCloudflare, Cloudflare: cfg},
+		api:   api,
+		zones: []cloudflare.Zone{{ID: "zone-1", Name: "example.com"}},
+	}
+}
+
+func TestCloudflareProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{records: map[string]*cloudflare.DNSRecord{}}
+	p := newProvider(t, server, dnsv1.CloudflareProviderConfig{Tags: []string{"cluster:test"}})
+
+	ttl := 300
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "www.example.com",
+		Value:      "192.168.1.1",
+		TTL:        &ttl,
+	}}
+	rec.Namespace = "default"
+	rec.Name = "www"
+	rec.Annotations = map[string]string{AnnotationKeyComment: "web frontend", AnnotationKeyTags: "team:web, cluster:test"}
+	if err := provider.CheckRecord(p, rec); err != nil {
+		t.Fatal(err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := server.records[id]
+	if created.TTL != ttl || created.Comment != provider.OwnerMarker(rec)+" web frontend" || strings.Join(created.Tags, ",") != "cluster:test,team:web" {
+		t.Fatalf("unexpected record %+v", created)
+	}
+
+	// unchanged records are not updated
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if server.updates != 0 {
+		t.Fatal("unchanged record is updated")
+	}
+
+	// the drift of ttl, comment and tags is corrected
+	created.TTL = 60
+	created.Comment = "edited in the dashboard"
+	created.Tags = nil
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if server.updates != 1 || created.TTL != ttl || created.Comment != provider.OwnerMarker(rec)+" web frontend" || len(created.Tags) != 2 {
+		t.Fatalf("drift not corrected: %+v", created)
+	}
+
+	// proxied records have automatic ttl
+	rec.Annotations[AnnotationKeyProxied] = "true"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if created.TTL != 1 || created.Proxied == nil || !*created.Proxied {
+		t.Fatalf("unexpected proxied record %+v", created)
+	}
+
+	current, err := p.GetRecord(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Owner == nil || current.Owner.Name != "www" || current.Annotations[AnnotationKeyComment] != "web frontend" || current.Annotations[AnnotationKeyTags] != "cluster:test,team:web" {
+		t.Fatalf("unexpected provider record %+v", current)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := p.ListRecords(ctx); err != nil || len(records) != 0 {
+		t.Fatalf("ListRecords after delete: %+v %v", records, err)
+	}
+}
+
+func TestCloudflareProviderMultiZone(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{
+		zones: []cloudflare.Zone{
+			{ID: "zone-1", Name: "example.com", Status: "active"},
+			{ID: "zone-2", Name: "sub.example.com", Status: "active"},
+			{ID: "zone-3", Name: "example.org", Status: "active"},
+			{ID: "zone-4", Name: "example.net", Status: "pending"},
+		},
+		records: map[string]*cloudflare.DNSRecord{},
+	}
+	cfg := dnsv1.CloudflareProviderConfig{MultiZone: true, Zones: []string{"example.com", "sub.example.com."}}
+	p := newProvider(t, server, cfg)
+	zones, err := listZones(ctx, p.api, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.zones, p.multiZone = zones, true
+	if names := strings.Join(p.Zones(), ","); names != "sub.example.com,example.com" {
+		t.Fatalf("unexpected zones %s", names)
+	}
+	if _, err := listZones(ctx, p.api, &dnsv1.CloudflareProviderConfig{MultiZone: true, Zones: []string{"example.net"}}); err == nil {
+		t.Fatal("expected a pending zone to be not found")
+	}
+
+	ids := map[string]string{}
+	for _, name := range []string{"www.example.com", "www.sub.example.com"} {
+		rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: name, Value: "192.168.1.1"}}
+		id, err := p.CreateRecord(ctx, rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+	}
+	if !strings.HasPrefix(ids["www.example.com"], "zone-1/") || !strings.HasPrefix(ids["www.sub.example.com"], "zone-2/") {
+		t.Fatalf("records are not created in the zone with the longest suffix: %v", ids)
+	}
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "www.sub.example.com"}}
+	if id, exists, err := p.SearchRecord(ctx, rec); err != nil || !exists || id != ids["www.sub.example.com"] {
+		t.Fatalf("SearchRecord: %s %v %v", id, exists, err)
+	}
+	current, err := p.GetRecord(ctx, ids["www.sub.example.com"])
+	if err != nil || current.Name != "www.sub.example.com" {
+		t.Fatalf("GetRecord: %+v %v", current, err)
+	}
+	if records, err := p.ListRecords(ctx); err != nil || len(records) != 2 {
+		t.Fatalf("ListRecords: %+v %v", records, err)
+	}
+
+	rec.Spec.Name = "www.example.org"
+	if _, err := p.CreateRecord(ctx, rec); err == nil {
+		t.Fatal("expected a name out of the zones to be rejected")
+	} else if class, _ := provider.ClassifyError(err); class != provider.ErrorClassPermanent {
+		t.Fatalf("expected a permanent error for a name out of the zones, got %v", err)
+	}
+}
+
+func TestCloudflareProviderZoneCache(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{
+		zones: []cloudflare.Zone{
+			{ID: "zone-1", Name: "example.com", Status: "active"},
+			{ID: "zone-2", Name: "example.org", Status: "active"},
+		},
+		records: map[string]*cloudflare.DNSRecord{},
+	}
+	spec := &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeCloudflare,
+		Cloudflare:   dnsv1.CloudflareProviderConfig{APIToken: t.Name(), MultiZone: true},
+	}
+	p := newProvider(t, server, spec.Cloudflare)
+
+	// the zones are listed once for all reconciles of the same spec
+	for i := 0; i < 3; i++ {
+		zones, err := loadZones(ctx, p.api, spec)
+		if err != nil || len(zones) != 2 {
+			t.Fatalf("unexpected zones %+v: %v", zones, err)
+		}
+	}
+	if server.lists != 1 {
+		t.Fatalf("expected the zones to be listed once, got %d", server.lists)
+	}
+
+	// the zones are listed again once the spec is changed
+	changed := spec.DeepCopy()
+	changed.Cloudflare.Zones = []string{"example.org"}
+	zones, err := loadZones(ctx, p.api, changed)
+	if err != nil || len(zones) != 1 || zones[0].Name != "example.org" {
+		t.Fatalf("unexpected zones %+v: %v", zones, err)
+	}
+	if server.lists != 2 {
+		t.Fatalf("expected the zones to be listed again, got %d", server.lists)
+	}
+}
@@ -0,0 +1,307 @@
+package digitalocean
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+type zoneRecord = *util.DigitalOceanRecord
+
+// DigitalOceanProvider manages the records of a DigitalOcean domain.
+// The record id is the id of the record in DigitalOcean, records of the same name and type (except CNAME)
+// with different values are kept side by side.
+// DigitalOcean has no place to store the ownership marker, so the records are never garbage collected.
+type DigitalOceanProvider struct {
+	util     *util.DigitalOceanUtils
+	spec     *dnsv1.DNSProviderSpec
+	zoneName string
+	zone     *provider.ZoneSnapshot[zoneRecord]
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// relativeName returns the name of the record relative to the domain, `@` for the apex
+func (p *DigitalOceanProvider) relativeName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == p.zoneName {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+p.zoneName)
+}
+
+func (p *DigitalOceanProvider) absoluteName(name string) string {
+	if name == "@" {
+		return p.zoneName
+	}
+	return name + "." + p.zoneName
+}
+
+func (p *DigitalOceanProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	records, err := p.util.ListRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(records))
+	for _, record := range records {
+		zone[strconv.Itoa(record.ID)] = record
+	}
+	return zone, nil
+}
+
+// newRecord builds the record described by the DNSRecord,
+// the priority, weight and port of MX and SRV records and the flags and tag of CAA records are separate fields in DigitalOcean
+func (p *DigitalOceanProvider) newRecord(rec *dnsv1.DNSRecord) (*util.DigitalOceanRecord, error) {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	record := &util.DigitalOceanRecord{
+		Name: p.relativeName(rec.Spec.Name),
+		Type: string(rec.Spec.RecordType),
+		Data: rec.Spec.Value,
+		TTL:  ttl,
+	}
+	fields := strings.Fields(rec.Spec.Value)
+	invalid := fmt.Errorf("invalid %s record value %q", rec.Spec.RecordType, rec.Spec.Value)
+	numbers := func(n int) ([]int, error) {
+		result := make([]int, n)
+		for i := range result {
+			v, err := strconv.Atoi(fields[i])
+			if err != nil {
+				return nil, invalid
+			}
+			result[i] = v
+		}
+		return result, nil
+	}
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeCNAME, dnsv1.DNSRecordTypeNS:
+		record.Data = fqdn(rec.Spec.Value)
+	case dnsv1.DNSRecordTypeTXT:
+		if unquoted, err := strconv.Unquote(rec.Spec.Value); err == nil {
+			record.Data = unquoted
+		}
+	case dnsv1.DNSRecordTypeMX:
+		if len(fields) != 2 {
+			return nil, invalid
+		}
+		n, err := numbers(1)
+		if err != nil {
+			return nil, err
+		}
+		record.Priority, record.Data = intPtr(n[0]), fqdn(fields[1])
+	case dnsv1.DNSRecordTypeSRV:
+		if len(fields) != 4 {
+			return nil, invalid
+		}
+		n, err := numbers(3)
+		if err != nil {
+			return nil, err
+		}
+		record.Priority, record.Weight, record.Port, record.Data = intPtr(n[0]), intPtr(n[1]), intPtr(n[2]), fqdn(fields[3])
+	case dnsv1.DNSRecordTypeCAA:
+		if len(fields) < 3 {
+			return nil, invalid
+		}
+		n, err := numbers(1)
+		if err != nil {
+			return nil, err
+		}
+		record.Flags, record.Tag, record.Data = intPtr(n[0]), fields[1], strings.Join(fields[2:], " ")
+		if unquoted, err := strconv.Unquote(record.Data); err == nil {
+			record.Data = unquoted
+		}
+	}
+	return record, nil
+}
+
+// dnsRecordValue returns the value of the record in the form of DNSRecord values
+func dnsRecordValue(record zoneRecord) string {
+	data := strings.TrimSuffix(record.Data, ".")
+	value := func(p *int) string {
+		if p == nil {
+			return "0"
+		}
+		return strconv.Itoa(*p)
+	}
+	switch record.Type {
+	case "MX":
+		return value(record.Priority) + " " + data
+	case "SRV":
+		return value(record.Priority) + " " + value(record.Weight) + " " + value(record.Port) + " " + data
+	case "CAA":
+		return value(record.Flags) + " " + record.Tag + " " + strconv.Quote(data)
+	}
+	return data
+}
+
+// matches reports whether the record can be managed by the DNSRecord described by the desired record
+func matches(record, desired zoneRecord) bool {
+	if record.Name != desired.Name || record.Type != desired.Type {
+		return false
+	}
+	return provider.IsSingleValueType(dnsv1.DNSRecordType(desired.Type)) || dnsRecordValue(record) == dnsRecordValue(desired)
+}
+
+func (p *DigitalOceanProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{MinTTL: 30, MultiValue: true}
+}
+
+func (p *DigitalOceanProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	if rec.Status.RecordID != "" {
+		_, ok, err := p.zone.Get(ctx, rec.Status.RecordID)
+		if err == nil && ok {
+			return rec.Status.RecordID, true, nil
+		}
+	}
+	desired, err := p.newRecord(rec)
+	if err != nil {
+		return "", false, err
+	}
+	id, _, ok, err = p.zone.Find(ctx, func(id string, record zoneRecord) bool {
+		return matches(record, desired)
+	})
+	if err != nil || !ok {
+		return "", false, err
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *DigitalOceanProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	desired, err := p.newRecord(rec)
+	if err != nil {
+		return "", err
+	}
+	record, err := p.util.CreateRecord(ctx, desired)
+	if err != nil {
+		p.zone.Invalidate()
+		return "", err
+	}
+	id = strconv.Itoa(record.ID)
+	p.zone.Put(id, record)
+	return id, nil
+}
+
+func (p *DigitalOceanProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	recordID, err := strconv.Atoi(*id)
+	if err != nil {
+		return fmt.Errorf("invalid record id %q", *id)
+	}
+	desired, err := p.newRecord(rec)
+	if err != nil {
+		return err
+	}
+	current, ok, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if ok && current.Name == desired.Name && current.Type == desired.Type && dnsRecordValue(current) == dnsRecordValue(desired) && current.TTL == desired.TTL {
+		return nil
+	}
+	if err := p.util.UpdateRecord(ctx, recordID, desired); err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	desired.ID = recordID
+	p.zone.Put(*id, desired)
+	return nil
+}
+
+func (p *DigitalOceanProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	recordID, err := strconv.Atoi(*id)
+	if err != nil {
+		return fmt.Errorf("invalid record id %q", *id)
+	}
+	if err := p.util.DeleteRecord(ctx, recordID); err != nil && !util.IsRESTStatus(err, http.StatusNotFound) {
+		p.zone.Invalidate()
+		return err
+	}
+	p.zone.Remove(*id)
+	return nil
+}
+
+func (p *DigitalOceanProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	record, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	rec := p.providerRecord(record)
+	return &rec, nil
+}
+
+func (p *DigitalOceanProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for _, record := range zone {
+		if record.Type == "SOA" {
+			continue
+		}
+		records = append(records, p.providerRecord(record))
+	}
+	return records, nil
+}
+
+func (p *DigitalOceanProvider) providerRecord(record zoneRecord) provider.ProviderRecord {
+	return provider.ProviderRecord{
+		ID:         strconv.Itoa(record.ID),
+		Name:       p.absoluteName(record.Name),
+		RecordType: dnsv1.DNSRecordType(record.Type),
+		Value:      dnsRecordValue(record),
+		TTL:        record.TTL,
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeDigitalOcean), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		token, err := args.SecretValue(spec.DigitalOcean.TokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(token) == "" {
+			return nil, errors.New("digitalocean token is required")
+		}
+		zoneName := spec.DigitalOcean.ZoneName
+		if zoneName == "" {
+			zoneName = spec.DomainName
+		}
+		dnsutil, err := util.NewDigitalOceanUtils(util.DigitalOceanAccount{
+			Token:    strings.TrimSpace(token),
+			Domain:   zoneName,
+			Endpoint: spec.DigitalOcean.Endpoint,
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := &DigitalOceanProvider{
+			util:     dnsutil,
+			spec:     spec,
+			zoneName: strings.ToLower(strings.TrimSuffix(zoneName, ".")),
+		}
+		key := string(dnsv1.DNSProviderTypeDigitalOcean) + "/" + spec.DigitalOcean.Endpoint + "/" + p.zoneName
+		p.zone = provider.GetZoneSnapshot(key, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
@@ -0,0 +1,211 @@
+package digitalocean
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testServer is a local stand-in of the DigitalOcean domain records API, which serves one record per page
+type testServer struct {
+	mu      sync.Mutex
+	records []*util.DigitalOceanRecord
+	nextID  int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"id": "unauthorized", "message": "Unable to authenticate you"})
+		return
+	}
+	const recordsPath = "/domains/example.com/records"
+	switch {
+	case r.URL.Path == recordsPath && r.Method == http.MethodGet:
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		resp := map[string]interface{}{"domain_records": []*util.DigitalOceanRecord{}}
+		if page >= 1 && page <= len(s.records) {
+			resp["domain_records"] = s.records[page-1 : page]
+		}
+		if page < len(s.records) {
+			resp["links"] = map[string]interface{}{"pages": map[string]string{"next": "https://api.digitalocean.com/v2" + recordsPath + "?page=" + strconv.Itoa(page+1)}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.URL.Path == recordsPath && r.Method == http.MethodPost:
+		var record util.DigitalOceanRecord
+		json.NewDecoder(r.Body).Decode(&record)
+		s.nextID++
+		record.ID = s.nextID
+		s.records = append(s.records, &record)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"domain_record": record})
+	case strings.HasPrefix(r.URL.Path, recordsPath+"/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, recordsPath+"/"))
+		for i, record := range s.records {
+			if record.ID != id {
+				continue
+			}
+			if r.Method == http.MethodDelete {
+				s.records = append(s.records[:i], s.records[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			var updated util.DigitalOceanRecord
+			json.NewDecoder(r.Body).Decode(&updated)
+			updated.ID = id
+			s.records[i] = &updated
+			json.NewEncoder(w).Encode(map[string]interface{}{"domain_record": updated})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"id": "not_found", "message": "The resource you were accessing could not be found."})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestDigitalOceanProvider(t *testing.T) {
+	ctx := context.Background()
+	priority := 10
+	server := &testServer{
+		records: []*util.DigitalOceanRecord{{ID: 100, Type: "MX", Name: "@", Data: "mx1.example.com", Priority: &priority, TTL: 1800}},
+		nextID:  100,
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "digitalocean"},
+		Data:       map[string][]byte{"token": []byte("test-token")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeDigitalOcean,
+		DigitalOcean: dnsv1.DigitalOceanProviderConfig{
+			TokenSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "digitalocean", Key: "token"},
+			Endpoint:       httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ttl := 300
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeMX,
+		Name:       "example.com",
+		Value:      "20 mx2.example.com",
+		TTL:        &ttl,
+	}}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := server.records[1]
+	if id != "101" || created.Name != "@" || created.Data != "mx2.example.com." || *created.Priority != 20 || created.TTL != 300 {
+		t.Fatalf("unexpected record %+v", created)
+	}
+
+	rec.Spec.Value = "30 mx2.example.com"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if *server.records[1].Priority != 30 {
+		t.Fatalf("unexpected record %+v", server.records[1])
+	}
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	for _, record := range records {
+		if record.ID == "100" && (record.Name != "example.com" || record.Value != "10 mx1.example.com") {
+			t.Fatalf("unexpected record %+v", record)
+		}
+	}
+
+	srv := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeSRV,
+		Name:       "_sip._tcp.example.com",
+		Value:      "10 60 5060 sip.example.com",
+	}}
+	srvID, err := p.CreateRecord(ctx, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record, err := p.(provider.IDNSRecordGetter).GetRecord(ctx, srvID); err != nil || record == nil || record.Value != srv.Spec.Value {
+		t.Fatalf("unexpected record %+v, err %v", record, err)
+	}
+	srv.Spec.Value = "10 60 sip.example.com"
+	if err := p.UpdateRecord(ctx, srv, &srvID); err == nil {
+		t.Fatal("expected an invalid SRV value to be rejected")
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.records) != 2 || server.records[0].ID != 100 {
+		t.Fatalf("unexpected records after delete %+v", server.records)
+	}
+}
+
+func TestDigitalOceanProviderDeadline(t *testing.T) {
+	// the stand-in never answers, so the call only ends by the deadline of the context
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "digitalocean"},
+		Data:       map[string][]byte{"token": []byte("test-token")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(context.Background(), reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeDigitalOcean,
+		DigitalOcean: dnsv1.DigitalOceanProviderConfig{
+			TokenSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "digitalocean", Key: "token"},
+			Endpoint:       httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "www.example.com", Value: "1.2.3.4"}}
+	if _, _, err := p.SearchRecord(ctx, rec); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the call took %v after the deadline", elapsed)
+	}
+}
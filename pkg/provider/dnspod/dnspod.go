@@ -0,0 +1,260 @@
+package dnspod
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+const (
+	AnnotationKeyLine = "dns.xzzpig.com/record-dnspod-line"
+
+	defaultRecordLine = "默认"
+)
+
+type zoneRecord = *util.DNSPodRecord
+
+type DNSPodProvider struct {
+	util *util.DNSPodUtils
+	spec *dnsv1.DNSProviderSpec
+	zone *provider.ZoneSnapshot[zoneRecord]
+}
+
+func (p *DNSPodProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	records, err := p.util.ListRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(records))
+	for _, record := range records {
+		zone[strconv.FormatUint(record.RecordId, 10)] = record
+	}
+	return zone, nil
+}
+
+// line returns the record line of the record, e.g. 默认, 电信, 联通
+func (p *DNSPodProvider) line(rec *dnsv1.DNSRecord) string {
+	if rec.Spec.Routing != nil && rec.Spec.Routing.Geo != "" {
+		return rec.Spec.Routing.Geo
+	}
+	if line := rec.Annotations[AnnotationKeyLine]; line != "" {
+		return line
+	}
+	if p.spec.DNSPod.RecordLine != "" {
+		return p.spec.DNSPod.RecordLine
+	}
+	return defaultRecordLine
+}
+
+func ttl(rec *dnsv1.DNSRecord) int {
+	if rec.Spec.TTL != nil {
+		return *rec.Spec.TTL
+	}
+	return config.GetConfig().Default.Record.TTL
+}
+
+// recordValue returns the value of the record in the form of DNSRecord, the preference of MX records is a separate field in DNSPod
+func recordValue(record zoneRecord) string {
+	if record.Type == "MX" && record.MX != 0 {
+		return strconv.FormatUint(record.MX, 10) + " " + record.Value
+	}
+	return record.Value
+}
+
+func valueEquals(a, b string) bool {
+	return strings.TrimSuffix(a, ".") == strings.TrimSuffix(b, ".")
+}
+
+func (p *DNSPodProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{
+		MinTTL:      1,
+		MaxTTL:      604800,
+		Ownership:   true,
+		Annotations: []string{AnnotationKeyLine},
+		// the records of the same name answer the resolution lines by routing.geo
+		RoutingPolicies: []dnsv1.DNSRoutingPolicy{dnsv1.DNSRoutingPolicyGeo},
+	}
+}
+
+func (p *DNSPodProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	if rec.Status.RecordID != "" {
+		record, ok, err := p.zone.Get(ctx, rec.Status.RecordID)
+		if err == nil && ok {
+			return strconv.FormatUint(record.RecordId, 10), true, nil
+		}
+	}
+	rr := rec.Spec.RR(p.spec)
+	line := p.line(rec)
+	id, _, ok, err = p.zone.Find(ctx, func(id string, record zoneRecord) bool {
+		return record.Name == rr && record.Line == line
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if !ok {
+		return "", false, nil
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *DNSPodProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	rr := rec.Spec.RR(p.spec)
+	id, err = p.util.CreateRecord(ctx, rr, rec.Spec.Value, string(rec.Spec.RecordType), p.line(rec), ttl(rec))
+	if err != nil {
+		p.zone.Invalidate()
+		return "", err
+	}
+	if remark := provider.OwnerMarker(rec); remark != "" {
+		if err := p.util.UpdateRecordRemark(ctx, id, remark); err != nil {
+			p.zone.Invalidate()
+			return "", err
+		}
+	}
+	p.zone.Put(id, p.newZoneRecord(id, rr, rec))
+	return id, nil
+}
+
+func (p *DNSPodProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	record, ok, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	rr := rec.Spec.RR(p.spec)
+	remark := provider.OwnerMarker(rec)
+	valueEqual := ok && record.Name == rr && record.Type == string(rec.Spec.RecordType) && valueEquals(recordValue(record), rec.Spec.Value) &&
+		record.Line == p.line(rec) && int(record.TTL) == ttl(rec)
+	remarkEqual := ok && record.Remark == remark
+	if valueEqual && remarkEqual {
+		return nil
+	}
+	if !valueEqual {
+		if err := p.util.UpdateRecord(ctx, *id, rr, rec.Spec.Value, string(rec.Spec.RecordType), p.line(rec), ttl(rec)); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	if !remarkEqual {
+		if err := p.util.UpdateRecordRemark(ctx, *id, remark); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	p.zone.Put(*id, p.newZoneRecord(*id, rr, rec))
+	return nil
+}
+
+func (p *DNSPodProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	if err := p.util.DeleteRecord(ctx, *id); err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	p.zone.Remove(*id)
+	return nil
+}
+
+func (p *DNSPodProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	record, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	rec := p.providerRecord(id, record)
+	return &rec, nil
+}
+
+func (p *DNSPodProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for id, record := range zone {
+		records = append(records, p.providerRecord(id, record))
+	}
+	return records, nil
+}
+
+func (p *DNSPodProvider) providerRecord(id string, record zoneRecord) provider.ProviderRecord {
+	name := p.spec.DomainName
+	if record.Name != "@" {
+		name = record.Name + "." + name
+	}
+	return provider.ProviderRecord{
+		ID:         id,
+		Name:       name,
+		RecordType: dnsv1.DNSRecordType(record.Type),
+		Value:      strings.TrimSuffix(recordValue(record), "."),
+		TTL:        int(record.TTL),
+		Owner:      provider.ParseOwnerMarker(record.Remark),
+		Annotations: map[string]string{
+			AnnotationKeyLine: record.Line,
+		},
+	}
+}
+
+func (p *DNSPodProvider) newZoneRecord(id string, rr string, rec *dnsv1.DNSRecord) zoneRecord {
+	recordID, _ := strconv.ParseUint(id, 10, 64)
+	return &util.DNSPodRecord{
+		RecordId: recordID,
+		Name:     rr,
+		Type:     string(rec.Spec.RecordType),
+		Line:     p.line(rec),
+		Value:    rec.Spec.Value,
+		TTL:      uint64(ttl(rec)),
+		Remark:   provider.OwnerMarker(rec),
+	}
+}
+
+// classifyError classifies the errors of the Tencent Cloud API by the error code
+func classifyError(err error) (provider.ErrorClass, time.Duration, bool) {
+	var apiErr *util.DNSPodError
+	if !errors.As(err, &apiErr) {
+		return "", 0, false
+	}
+	switch {
+	case strings.HasPrefix(apiErr.Code, "RequestLimitExceeded"):
+		return provider.ErrorClassRateLimited, 0, true
+	case strings.HasPrefix(apiErr.Code, "AuthFailure"), strings.HasPrefix(apiErr.Code, "UnauthorizedOperation"),
+		strings.HasPrefix(apiErr.Code, "InvalidParameter"), strings.HasPrefix(apiErr.Code, "MissingParameter"):
+		return provider.ErrorClassPermanent, 0, true
+	}
+	return provider.ErrorClassRetryable, 0, true
+}
+
+func init() {
+	provider.RegisterErrorClassifier(classifyError)
+	provider.Register(string(dnsv1.DNSProviderTypeDNSPod), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		secretKey := spec.DNSPod.SecretKey
+		if secretKey == "" {
+			value, err := args.SecretValue(spec.DNSPod.SecretKeySecretRef)
+			if err != nil {
+				return nil, err
+			}
+			secretKey = strings.TrimSpace(value)
+		}
+		dnsutil, err := util.NewDNSPodUtils(util.DNSPodAccount{
+			SecretID:   spec.DNSPod.SecretID,
+			SecretKey:  secretKey,
+			DomainName: spec.DomainName,
+			Endpoint:   spec.DNSPod.Endpoint,
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := &DNSPodProvider{
+			util: dnsutil,
+			spec: spec,
+		}
+		key := string(dnsv1.DNSProviderTypeDNSPod) + "/" + spec.DNSPod.SecretID + "/" + spec.DomainName
+		p.zone = provider.GetZoneSnapshot(key, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
@@ -0,0 +1,170 @@
+package dnspod
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+// testServer is a local stand-in of the DNSPod API 3.0
+type testServer struct {
+	mu      sync.Mutex
+	nextID  uint64
+	records map[uint64]*util.DNSPodRecord
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	respond := func(resp map[string]interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"Response": resp})
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=test-id/") {
+		respond(map[string]interface{}{"Error": map[string]string{"Code": "AuthFailure", "Message": "unsigned"}})
+		return
+	}
+	var req struct {
+		Domain     string
+		RecordId   uint64
+		SubDomain  string
+		RecordType string
+		RecordLine string
+		Value      string
+		MX         uint64
+		TTL        uint64
+		Remark     string
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	switch r.Header.Get("X-TC-Action") {
+	case "DescribeRecordList":
+		if len(s.records) == 0 {
+			respond(map[string]interface{}{"Error": map[string]string{"Code": "ResourceNotFound.NoDataOfRecord", "Message": "no records"}})
+			return
+		}
+		var list []*util.DNSPodRecord
+		for _, record := range s.records {
+			list = append(list, record)
+		}
+		respond(map[string]interface{}{"RecordCountInfo": map[string]int{"TotalCount": len(list)}, "RecordList": list})
+	case "CreateRecord", "ModifyRecord":
+		if req.RecordId == 0 {
+			s.nextID++
+			req.RecordId = s.nextID
+		}
+		s.records[req.RecordId] = &util.DNSPodRecord{
+			RecordId: req.RecordId, Name: req.SubDomain, Type: req.RecordType, Line: req.RecordLine,
+			Value: req.Value, MX: req.MX, TTL: req.TTL,
+		}
+		respond(map[string]interface{}{"RecordId": req.RecordId})
+	case "ModifyRecordRemark":
+		s.records[req.RecordId].Remark = req.Remark
+		respond(map[string]interface{}{})
+	case "DeleteRecord":
+		delete(s.records, req.RecordId)
+		respond(map[string]interface{}{})
+	default:
+		respond(map[string]interface{}{"Error": map[string]string{"Code": "InvalidAction", "Message": "unknown action"}})
+	}
+}
+
+func TestDNSPodProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{records: map[uint64]*util.DNSPodRecord{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	p, err := provider.New(ctx, nil, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeDNSPod,
+		DNSPod: dnsv1.DNSPodProviderConfig{
+			SecretID:  "test-id",
+			SecretKey: "test-key",
+			Endpoint:  httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeMX,
+		Name:       "mail.example.com",
+		Value:      "10 mx.example.com",
+	}}
+	rec.Name = "mail"
+	rec.Namespace = "default"
+	rec.Annotations = map[string]string{AnnotationKeyLine: "电信"}
+	if err := provider.CheckRecord(p, rec); err != nil {
+		t.Fatal(err)
+	}
+	// the annotations of an Ingress are copied to its records whatever the provider is
+	fromIngress := rec.DeepCopy()
+	fromIngress.Annotations["dns.xzzpig.com/record-proxied"] = "true"
+	if err := provider.CheckRecord(p, fromIngress); err != nil {
+		t.Fatalf("the cloudflare annotation is not ignored: %v", err)
+	}
+	rejected := rec.DeepCopy()
+	rejected.Spec.TTL = new(int)
+	if err := provider.CheckRecord(p, rejected); err == nil {
+		t.Fatal("expected ttl 0 to be rejected")
+	}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := server.records[1]
+	if created.Line != "电信" || created.MX != 10 || created.Value != "mx.example.com" || created.Remark != provider.OwnerMarker(rec) {
+		t.Fatalf("unexpected record %+v", created)
+	}
+
+	// the same name on another line is a different record
+	other := rec.DeepCopy()
+	other.Annotations[AnnotationKeyLine] = "联通"
+	if _, ok, err := p.SearchRecord(ctx, other); err != nil || ok {
+		t.Fatalf("SearchRecord on another line: ok=%v err=%v", ok, err)
+	}
+	if found, ok, err := p.SearchRecord(ctx, rec.DeepCopy()); err != nil || !ok || found != id {
+		t.Fatalf("SearchRecord by name: id=%q ok=%v err=%v", found, ok, err)
+	}
+	// routing.geo is the line of the record
+	other.Spec.Routing = &dnsv1.DNSRecordRouting{Geo: "电信"}
+	if err := provider.CheckRecord(p, other); err != nil {
+		t.Fatal(err)
+	}
+	if found, ok, err := p.SearchRecord(ctx, other); err != nil || !ok || found != id {
+		t.Fatalf("SearchRecord by routing.geo: id=%q ok=%v err=%v", found, ok, err)
+	}
+	other.Spec.Routing.Weight = new(int64)
+	if err := provider.CheckRecord(p, other); err == nil {
+		t.Fatal("expected weighted routing to be rejected")
+	}
+
+	rec.Spec.Value = "20 mx.example.com"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	current, err := p.(provider.IDNSRecordGetter).GetRecord(ctx, id)
+	if err != nil || current == nil || current.Value != "20 mx.example.com" || current.Owner == nil {
+		t.Fatalf("GetRecord after update: %+v %v", current, err)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.records) != 0 {
+		t.Fatalf("unexpected records after delete %+v", server.records)
+	}
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+// ErrorClass tells how a failed call to the provider should be retried
+type ErrorClass string
+
+const (
+	// The call may succeed later, e.g. a network error or a 5xx of the provider API
+	ErrorClassRetryable ErrorClass = "Retryable"
+	// The call was rejected by the rate limiting of the provider API
+	ErrorClassRateLimited ErrorClass = "RateLimited"
+	// The call fails until the credentials or the record are changed, e.g. an authentication or validation error
+	ErrorClassPermanent ErrorClass = "Permanent"
+)
+
+// ClassifiedError is an error with a known class, returned by providers which know better than the status code
+type ClassifiedError struct {
+	Class ErrorClass
+	// The delay requested by the provider API, 0 if unknown
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClassifier returns the class of the errors of a provider API, ok is false if the error is unknown to it
+type ErrorClassifier func(err error) (class ErrorClass, retryAfter time.Duration, ok bool)
+
+var errorClassifiers []ErrorClassifier
+
+// RegisterErrorClassifier registers the classifier of the errors of a vendor SDK, called in the init of the provider
+func RegisterErrorClassifier(classifier ErrorClassifier) {
+	errorClassifiers = append(errorClassifiers, classifier)
+}
+
+// ClassifyError returns the class of the error returned by a provider and the delay requested by the provider API,
+// unknown errors are retryable
+func ClassifyError(err error) (class ErrorClass, retryAfter time.Duration) {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class, classified.RetryAfter
+	}
+	var restErr *util.RESTError
+	if errors.As(err, &restErr) {
+		return ClassifyStatusCode(restErr.StatusCode), restErr.RetryAfter
+	}
+	for _, classifier := range errorClassifiers {
+		if class, retryAfter, ok := classifier(err); ok {
+			return class, retryAfter
+		}
+	}
+	// e.g. the errors of the AWS SDK
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return ClassifyStatusCode(statusErr.HTTPStatusCode()), 0
+	}
+	return ErrorClassRetryable, 0
+}
+
+// ClassifyStatusCode returns the class of an error response of a provider API
+func ClassifyStatusCode(statusCode int) ErrorClass {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return ErrorClassRateLimited
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusUnprocessableEntity:
+		return ErrorClassPermanent
+	}
+	return ErrorClassRetryable
+}
+
+const (
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 10 * time.Minute
+)
+
+// RetryDelay returns the delay before retrying a record failed attempts times in a row with the error,
+// the delay doubles from 5s up to 10m with up to 20% jitter, so the records failed together are not retried in lockstep.
+// Permanent errors are retried after the max delay, rate limited errors not before the delay requested by the provider.
+func RetryDelay(class ErrorClass, retryAfter time.Duration, attempts int) time.Duration {
+	delay := maxRetryDelay
+	if class != ErrorClassPermanent && attempts < 8 {
+		delay = minRetryDelay << attempts
+		if attempts < 1 {
+			delay = minRetryDelay
+		}
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	if class == ErrorClassRateLimited && retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/5)))
+}
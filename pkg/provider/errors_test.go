@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err        error
+		class      ErrorClass
+		retryAfter time.Duration
+	}{
+		{errors.New("connection reset"), ErrorClassRetryable, 0},
+		{&util.RESTError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second}, ErrorClassRateLimited, 30 * time.Second},
+		{fmt.Errorf("update: %w", &util.RESTError{StatusCode: http.StatusForbidden}), ErrorClassPermanent, 0},
+		{&util.RESTError{StatusCode: http.StatusBadGateway}, ErrorClassRetryable, 0},
+		{&ClassifiedError{Class: ErrorClassPermanent, Err: errors.New("invalid zone")}, ErrorClassPermanent, 0},
+	}
+	for _, c := range cases {
+		class, retryAfter := ClassifyError(c.err)
+		if class != c.class || retryAfter != c.retryAfter {
+			t.Errorf("ClassifyError(%v) = %s %s, want %s %s", c.err, class, retryAfter, c.class, c.retryAfter)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	within := func(delay, min time.Duration) bool {
+		return delay >= min && delay <= min+min/5
+	}
+	for attempts, want := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second} {
+		if delay := RetryDelay(ErrorClassRetryable, 0, attempts); !within(delay, want) {
+			t.Errorf("RetryDelay after %d attempts = %s, want %s", attempts, delay, want)
+		}
+	}
+	if delay := RetryDelay(ErrorClassRetryable, 0, 100); !within(delay, maxRetryDelay) {
+		t.Errorf("RetryDelay is not capped: %s", delay)
+	}
+	if delay := RetryDelay(ErrorClassPermanent, 0, 0); !within(delay, maxRetryDelay) {
+		t.Errorf("RetryDelay of permanent error = %s", delay)
+	}
+	if delay := RetryDelay(ErrorClassRateLimited, time.Minute, 0); !within(delay, time.Minute) {
+		t.Errorf("RetryDelay ignores Retry-After: %s", delay)
+	}
+}
+
+func TestWaitRateLimit(t *testing.T) {
+	ctx := context.Background()
+	config := &dnsv1.DNSProviderRateLimit{Requests: 2, Period: 60}
+	for i := 0; i < 2; i++ {
+		if delay := RateLimitDelay("test", config); delay != 0 {
+			t.Fatalf("request %d: delay=%s", i, delay)
+		}
+		if err := WaitRateLimit(ctx, "test", config); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	delay := RateLimitDelay("test", config)
+	if delay < 25*time.Second || delay > 30*time.Second {
+		t.Fatalf("expected the third request to be delayed: delay=%s", delay)
+	}
+	// the token is not taken if the wait exceeds the deadline
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := WaitRateLimit(timeoutCtx, "test", config); err == nil {
+		t.Fatal("expected the wait to exceed the deadline")
+	}
+	if next := RateLimitDelay("test", config); next > delay {
+		t.Fatalf("the token is taken: delay=%s", next)
+	}
+	if delay := RateLimitDelay("other", config); delay != 0 {
+		t.Fatalf("the rate limiter is shared by another key: %s", delay)
+	}
+	// the bucket is refilled when the config is changed
+	if delay := RateLimitDelay("test", &dnsv1.DNSProviderRateLimit{Requests: 10}); delay != 0 {
+		t.Fatalf("the rate limiter is not recreated: %s", delay)
+	}
+	if err := WaitRateLimit(ctx, "test", nil); err != nil {
+		t.Fatalf("unlimited provider is delayed: %v", err)
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	spec := &dnsv1.DNSProviderSpec{RateLimit: &dnsv1.DNSProviderRateLimit{Requests: 1, Period: 60}}
+	// every call takes a token
+	ctx, cancel, err := CallContext(context.Background(), t.Name(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("the call is not bound to the timeout")
+	}
+	if delay := RateLimitDelay(t.Name(), spec.RateLimit); delay == 0 {
+		t.Fatal("the call does not take a token")
+	}
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), time.Second)
+	defer cancelTimeout()
+	if _, cancel, err := CallContext(timeoutCtx, t.Name(), spec); err == nil {
+		cancel()
+		t.Fatal("expected the call to wait beyond the deadline")
+	}
+}
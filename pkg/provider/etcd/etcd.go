@@ -0,0 +1,292 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// The leaf key of the records written by k8s-dns-manager, in the form of `x-<type>`
+const leafPrefix = "x-"
+
+// service is a record in the SkyDNS format served by the CoreDNS etcd plugin
+type service struct {
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+	Text     string `json:"text,omitempty"`
+	TTL      uint32 `json:"ttl,omitempty"`
+	// The ownership marker, ignored by CoreDNS
+	Owner string `json:"owner,omitempty"`
+}
+
+// recordType infers the type of the record from its fields
+func (s *service) recordType() dnsv1.DNSRecordType {
+	switch {
+	case s.Text != "":
+		return dnsv1.DNSRecordTypeTXT
+	case s.Port != 0:
+		return dnsv1.DNSRecordTypeSRV
+	}
+	ip := net.ParseIP(s.Host)
+	switch {
+	case ip == nil:
+		return dnsv1.DNSRecordTypeCNAME
+	case ip.To4() != nil:
+		return dnsv1.DNSRecordTypeA
+	default:
+		return dnsv1.DNSRecordTypeAAAA
+	}
+}
+
+func (s *service) value() string {
+	switch s.recordType() {
+	case dnsv1.DNSRecordTypeTXT:
+		return s.Text
+	case dnsv1.DNSRecordTypeSRV:
+		return fmt.Sprintf("%d %d %d %s", s.Priority, s.Weight, s.Port, s.Host)
+	}
+	return s.Host
+}
+
+// EtcdProvider writes records in the SkyDNS format for the CoreDNS etcd plugin.
+// The record id is the etcd key of the record, e.g. `/skydns/com/example/www/x-a`.
+type EtcdProvider struct {
+	spec   *dnsv1.DNSProviderSpec
+	client *clientv3.Client
+	prefix string
+}
+
+// path returns the key of the name, e.g. `/skydns/com/example/www` for `www.example.com`
+func (p *EtcdProvider) path(name string) string {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(name, ".")), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return p.prefix + "/" + strings.Join(labels, "/")
+}
+
+func (p *EtcdProvider) key(rec *dnsv1.DNSRecord) string {
+	return p.path(rec.Spec.Name) + "/" + leafPrefix + strings.ToLower(string(rec.Spec.RecordType))
+}
+
+// newService builds the SkyDNS record described by the DNSRecord
+func newService(rec *dnsv1.DNSRecord) (*service, error) {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	s := &service{TTL: uint32(ttl), Owner: provider.OwnerMarker(rec)}
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeA, dnsv1.DNSRecordTypeAAAA, dnsv1.DNSRecordTypeCNAME:
+		s.Host = rec.Spec.Value
+	case dnsv1.DNSRecordTypeTXT:
+		s.Text = rec.Spec.Value
+	case dnsv1.DNSRecordTypeSRV:
+		fields := strings.Fields(rec.Spec.Value)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid SRV value %q, expected `priority weight port target`", rec.Spec.Value)
+		}
+		numbers := make([]int, 3)
+		for i := range numbers {
+			n, err := strconv.Atoi(fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid SRV value %q: %w", rec.Spec.Value, err)
+			}
+			numbers[i] = n
+		}
+		s.Priority, s.Weight, s.Port, s.Host = numbers[0], numbers[1], numbers[2], fields[3]
+	default:
+		return nil, fmt.Errorf("record type %s is not supported by etcd provider", rec.Spec.RecordType)
+	}
+	return s, nil
+}
+
+func (p *EtcdProvider) get(ctx context.Context, key string) (*service, error) {
+	resp, err := p.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	var s service
+	if err := json.Unmarshal(resp.Kvs[0].Value, &s); err != nil {
+		return nil, fmt.Errorf("invalid record %s: %w", key, err)
+	}
+	return &s, nil
+}
+
+func (p *EtcdProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{
+		RecordTypes: []dnsv1.DNSRecordType{dnsv1.DNSRecordTypeA, dnsv1.DNSRecordTypeAAAA, dnsv1.DNSRecordTypeCNAME, dnsv1.DNSRecordTypeTXT, dnsv1.DNSRecordTypeSRV},
+		Ownership:   true,
+	}
+}
+
+func (p *EtcdProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	key := p.key(rec)
+	s, err := p.get(ctx, key)
+	if err != nil || s == nil {
+		return "", false, err
+	}
+	rec.Status.RecordID = key
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *EtcdProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	s, err := newService(rec)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	key := p.key(rec)
+	if _, err := p.client.Put(ctx, key, string(data)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (p *EtcdProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	desired, err := newService(rec)
+	if err != nil {
+		return err
+	}
+	key := p.key(rec)
+	if key == *id {
+		current, err := p.get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if current != nil && *current == *desired {
+			return nil
+		}
+	}
+	data, err := json.Marshal(desired)
+	if err != nil {
+		return err
+	}
+	ops := []clientv3.Op{clientv3.OpPut(key, string(data))}
+	if key != *id {
+		// the name or type has changed, remove the old record in the same transaction
+		ops = append(ops, clientv3.OpDelete(*id))
+	}
+	if _, err := p.client.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return err
+	}
+	rec.Status.RecordID = key
+	return nil
+}
+
+func (p *EtcdProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	_, err = p.client.Delete(ctx, *id)
+	return err
+}
+
+func (p *EtcdProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	s, err := p.get(ctx, id)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	rec := p.providerRecord(id, s)
+	return &rec, nil
+}
+
+func (p *EtcdProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	resp, err := p.client.Get(ctx, p.path(p.spec.DomainName)+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var s service
+		if err := json.Unmarshal(kv.Value, &s); err != nil {
+			// not a SkyDNS record
+			continue
+		}
+		records = append(records, p.providerRecord(string(kv.Key), &s))
+	}
+	return records, nil
+}
+
+func (p *EtcdProvider) providerRecord(key string, s *service) provider.ProviderRecord {
+	labels := strings.Split(strings.TrimPrefix(key, p.prefix+"/"), "/")
+	recordType := s.recordType()
+	if leaf := labels[len(labels)-1]; strings.HasPrefix(leaf, leafPrefix) {
+		recordType = dnsv1.DNSRecordType(strings.ToUpper(strings.TrimPrefix(leaf, leafPrefix)))
+		labels = labels[:len(labels)-1]
+	}
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return provider.ProviderRecord{
+		ID:         key,
+		Name:       strings.Join(labels, "."),
+		RecordType: recordType,
+		Value:      s.value(),
+		TTL:        int(s.TTL),
+		Owner:      provider.ParseOwnerMarker(s.Owner),
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeEtcd), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		cfg := &spec.Etcd
+		if len(cfg.Endpoints) == 0 {
+			return nil, errors.New("etcd endpoints is required")
+		}
+		password, err := args.SecretValue(cfg.PasswordSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		clientCfg := clientv3.Config{
+			Endpoints:   cfg.Endpoints,
+			Username:    cfg.Username,
+			Password:    strings.TrimSpace(password),
+			DialTimeout: 10 * time.Second,
+			Logger:      zap.NewNop(),
+		}
+		var tlsData []byte
+		if cfg.TLSSecretRef != nil {
+			tlsCfg, data, err := args.TLSConfig(cfg.TLSSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			clientCfg.TLS = tlsCfg
+			tlsData = data
+		}
+		key := provider.LookupKey("Etcd/client", cfg.Endpoints, clientCfg.Username, clientCfg.Password, tlsData)
+		client, err := provider.CachedClient(key, func() (*clientv3.Client, error) {
+			return clientv3.New(clientCfg)
+		}, func(client *clientv3.Client) { client.Close() })
+		if err != nil {
+			return nil, err
+		}
+
+		prefix := cfg.Prefix
+		if prefix == "" {
+			prefix = "/skydns"
+		}
+		return &EtcdProvider{
+			spec:   spec,
+			client: client,
+			prefix: "/" + strings.Trim(prefix, "/"),
+		}, nil
+	})
+}
@@ -0,0 +1,262 @@
+package etcd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"go.etcd.io/etcd/server/v3/embed"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newCert issues a certificate signed by the parent, or a self-signed CA if parent is nil
+func newCert(t *testing.T, parent *testCert, template *x509.Certificate) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func freeURL(t *testing.T, scheme string) url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return url.URL{Scheme: scheme, Host: listener.Addr().String()}
+}
+
+// startEtcd starts an embedded etcd server requiring TLS client certificates
+func startEtcd(t *testing.T) (endpoint string, secret *corev1.Secret) {
+	ca := newCert(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	server := newCert(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "etcd"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	})
+	client := newCert(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "k8s-dns-manager"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	})
+
+	dir := t.TempDir()
+	files := map[string][]byte{"ca.crt": ca.certPEM, "server.crt": server.certPEM, "server.key": server.keyPEM}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := embed.NewConfig()
+	cfg.Dir = filepath.Join(dir, "data")
+	cfg.LogLevel = "error"
+	clientURL, peerURL := freeURL(t, "https"), freeURL(t, "http")
+	cfg.ListenClientUrls, cfg.AdvertiseClientUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	cfg.ListenPeerUrls, cfg.AdvertisePeerUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	cfg.ClientTLSInfo.TrustedCAFile = filepath.Join(dir, "ca.crt")
+	cfg.ClientTLSInfo.CertFile = filepath.Join(dir, "server.crt")
+	cfg.ClientTLSInfo.KeyFile = filepath.Join(dir, "server.key")
+	cfg.ClientTLSInfo.ClientCertAuth = true
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatal("etcd server took too long to start")
+	}
+
+	return clientURL.String(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "etcd-tls"},
+		Data:       map[string][]byte{"ca.crt": ca.certPEM, "tls.crt": client.certPEM, "tls.key": client.keyPEM},
+	}
+}
+
+func TestEtcdProvider(t *testing.T) {
+	ctx := context.Background()
+	endpoint, secret := startEtcd(t)
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeEtcd,
+		Etcd: dnsv1.EtcdProviderConfig{
+			Endpoints:    []string{endpoint},
+			TLSSecretRef: &dnsv1.ObjectReference{Namespace: "default", Name: "etcd-tls"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcd := p.(*EtcdProvider)
+
+	ttl := 60
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeSRV,
+		Name:       "_sip._tcp.example.com",
+		Value:      "10 20 5060 sip.example.com",
+		TTL:        &ttl,
+	}}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "/skydns/com/example/_tcp/_sip/x-srv" {
+		t.Fatalf("unexpected id %q", id)
+	}
+	resp, err := etcd.client.Get(ctx, id)
+	if err != nil || len(resp.Kvs) != 1 {
+		t.Fatalf("unexpected value %+v %v", resp, err)
+	}
+	var s map[string]interface{}
+	json.Unmarshal(resp.Kvs[0].Value, &s)
+	if s["host"] != "sip.example.com" || s["port"] != float64(5060) || s["priority"] != float64(10) || s["weight"] != float64(20) || s["ttl"] != float64(60) {
+		t.Fatalf("unexpected SkyDNS record %s", resp.Kvs[0].Value)
+	}
+
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeA
+	rec.Spec.Name = "www.example.com"
+	rec.Spec.Value = "10.0.0.1"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	id = rec.Status.RecordID
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "/skydns/com/example/www/x-a" || records[0].Name != "www.example.com" ||
+		records[0].RecordType != dnsv1.DNSRecordTypeA || records[0].Value != "10.0.0.1" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord after delete: ok=%v err=%v", ok, err)
+	}
+
+	if _, err := p.CreateRecord(ctx, &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeMX,
+		Name:       "example.com",
+		Value:      "10 mail.example.com",
+	}}); err == nil {
+		t.Fatal("expected MX record to be rejected")
+	}
+}
+
+func TestEtcdProviderClientCache(t *testing.T) {
+	ctx := context.Background()
+	provider.SetReconcileTimeout(500 * time.Millisecond)
+	t.Cleanup(func() { provider.SetReconcileTimeout(5 * time.Minute) })
+
+	endpoint, secret := startEtcd(t)
+	password := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "etcd-password"},
+		Data:       map[string][]byte{"password": []byte("changed")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret, password).Build()
+	spec := &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeEtcd,
+		Etcd: dnsv1.EtcdProviderConfig{
+			Endpoints:    []string{endpoint},
+			TLSSecretRef: &dnsv1.ObjectReference{Namespace: "default", Name: "etcd-tls"},
+		},
+	}
+	old, err := provider.New(ctx, reader, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another provider of the same endpoints with other credentials gets its own client
+	other := spec.DeepCopy()
+	other.Etcd.PasswordSecretRef = &dnsv1.SecretKeySelector{Namespace: "default", Name: "etcd-password", Key: "password"}
+	p, err := provider.New(ctx, reader, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.(*EtcdProvider).client == old.(*EtcdProvider).client {
+		t.Fatal("the client is shared by different credentials")
+	}
+	again, err := provider.New(ctx, reader, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.(*EtcdProvider).client != old.(*EtcdProvider).client {
+		t.Fatal("the client is replaced by the provider of other credentials")
+	}
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "www.example.com", Value: "10.0.0.1"}}
+	if _, _, err := old.SearchRecord(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client unused for longer than the reconcile timeout is closed
+	time.Sleep(700 * time.Millisecond)
+	if p, err = provider.New(ctx, reader, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.SearchRecord(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := old.SearchRecord(ctx, rec); err == nil {
+		t.Fatal("the idle client is not closed")
+	}
+}
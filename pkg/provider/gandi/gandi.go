@@ -0,0 +1,335 @@
+package gandi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+type zoneRecord = *util.GandiRRSet
+
+// GandiProvider manages the RRsets of a Gandi LiveDNS domain, each DNSRecord manages one value of an RRset,
+// so the DNSRecords of the same name and type (except CNAME) with different values are kept side by side.
+// The record id is the name, type and value of the record, e.g. `www.example.com. A 10.0.0.1`.
+// LiveDNS has no place to store the ownership marker, so the records are never garbage collected.
+type GandiProvider struct {
+	util     *util.GandiUtils
+	spec     *dnsv1.DNSProviderSpec
+	zoneName string
+	zone     *provider.ZoneSnapshot[zoneRecord]
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// rrsetKey returns the key of the RRset in the zone snapshot, e.g. `www.example.com. A`
+func rrsetKey(name string, rrtype string) string {
+	return fqdn(strings.ToLower(name)) + " " + rrtype
+}
+
+func recordID(name string, rrtype string, value string) string {
+	return rrsetKey(name, rrtype) + " " + value
+}
+
+// parseRecordID returns the key of the RRset and the value of a record id
+func parseRecordID(id string) (key string, value string, err error) {
+	fields := strings.SplitN(id, " ", 3)
+	if len(fields) != 3 {
+		return "", "", fmt.Errorf("invalid record id %q", id)
+	}
+	return fields[0] + " " + fields[1], fields[2], nil
+}
+
+// relativeName returns the name of the RRset relative to the domain, `@` for the apex
+func (p *GandiProvider) relativeName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == p.zoneName {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+p.zoneName)
+}
+
+func (p *GandiProvider) absoluteName(name string) string {
+	if name == "@" {
+		return p.zoneName
+	}
+	return name + "." + p.zoneName
+}
+
+func (p *GandiProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	rrsets, err := p.util.ListRRSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(rrsets))
+	for _, rrset := range rrsets {
+		zone[rrsetKey(p.absoluteName(rrset.Name), rrset.Type)] = rrset
+	}
+	return zone, nil
+}
+
+// rrsetValue returns the value of the DNSRecord in the zone file form stored by LiveDNS
+func rrsetValue(rec *dnsv1.DNSRecord) string {
+	value := rec.Spec.Value
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeCNAME, dnsv1.DNSRecordTypeNS, dnsv1.DNSRecordTypeMX, dnsv1.DNSRecordTypeSRV:
+		// the target host name is the last field
+		return fqdn(value)
+	case dnsv1.DNSRecordTypeTXT:
+		if !strings.HasPrefix(value, `"`) {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+// dnsRecordValue returns the value of the RRset in the form of DNSRecord values
+func dnsRecordValue(rrtype string, value string) string {
+	value = strings.TrimSuffix(value, ".")
+	if rrtype == "TXT" {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+	}
+	return value
+}
+
+func ttl(rec *dnsv1.DNSRecord) int {
+	if rec.Spec.TTL != nil {
+		return *rec.Spec.TTL
+	}
+	return config.GetConfig().Default.Record.TTL
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the values of the RRset, which is deleted if no value is left
+func (p *GandiProvider) save(ctx context.Context, key string, rrset zoneRecord) error {
+	var err error
+	if len(rrset.Values) == 0 {
+		if err = p.util.DeleteRRSet(ctx, rrset.Name, rrset.Type); util.IsRESTStatus(err, http.StatusNotFound) {
+			err = nil
+		}
+	} else {
+		err = p.util.PutRRSet(ctx, rrset)
+	}
+	if err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	if len(rrset.Values) == 0 {
+		p.zone.Remove(key)
+	} else {
+		p.zone.Put(key, rrset)
+	}
+	return nil
+}
+
+// current returns a copy of the RRset of the key, or an empty RRset if not exists
+func (p *GandiProvider) current(ctx context.Context, key string) (zoneRecord, error) {
+	rrset, ok, err := p.zone.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		name, rrtype, _ := strings.Cut(key, " ")
+		return &util.GandiRRSet{Name: p.relativeName(name), Type: rrtype}, nil
+	}
+	copied := *rrset
+	copied.Values = append([]string{}, rrset.Values...)
+	return &copied, nil
+}
+
+func (p *GandiProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{MinTTL: 300, MaxTTL: 2592000, MultiValue: true}
+}
+
+func (p *GandiProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	key := rrsetKey(rec.Spec.Name, string(rec.Spec.RecordType))
+	rrset, ok, err := p.zone.Get(ctx, key)
+	if err != nil || !ok || len(rrset.Values) == 0 {
+		return "", false, err
+	}
+	value := rrsetValue(rec)
+	if provider.IsSingleValueType(rec.Spec.RecordType) {
+		value = rrset.Values[0]
+	} else if indexOf(rrset.Values, value) < 0 {
+		return "", false, nil
+	}
+	rec.Status.RecordID = recordID(rec.Spec.Name, string(rec.Spec.RecordType), value)
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *GandiProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	key := rrsetKey(rec.Spec.Name, string(rec.Spec.RecordType))
+	rrset, err := p.current(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	value := rrsetValue(rec)
+	if provider.IsSingleValueType(rec.Spec.RecordType) {
+		rrset.Values = []string{value}
+	} else if indexOf(rrset.Values, value) < 0 {
+		rrset.Values = append(rrset.Values, value)
+	}
+	rrset.TTL = ttl(rec)
+	if err := p.save(ctx, key, rrset); err != nil {
+		return "", err
+	}
+	return recordID(rec.Spec.Name, string(rec.Spec.RecordType), value), nil
+}
+
+func (p *GandiProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	oldKey, oldValue, err := parseRecordID(*id)
+	if err != nil {
+		return err
+	}
+	key := rrsetKey(rec.Spec.Name, string(rec.Spec.RecordType))
+	value := rrsetValue(rec)
+	rrset, err := p.current(ctx, key)
+	if err != nil {
+		return err
+	}
+	if key == oldKey && rrset.TTL == ttl(rec) && indexOf(rrset.Values, value) >= 0 &&
+		(value == oldValue || indexOf(rrset.Values, oldValue) < 0) &&
+		(!provider.IsSingleValueType(rec.Spec.RecordType) || len(rrset.Values) == 1) {
+		return nil
+	}
+
+	if key != oldKey {
+		// the name or type has changed, remove the value from the old RRset
+		oldRRSet, err := p.current(ctx, oldKey)
+		if err != nil {
+			return err
+		}
+		if i := indexOf(oldRRSet.Values, oldValue); i >= 0 {
+			oldRRSet.Values = append(oldRRSet.Values[:i], oldRRSet.Values[i+1:]...)
+			if err := p.save(ctx, oldKey, oldRRSet); err != nil {
+				return err
+			}
+		}
+	} else if i := indexOf(rrset.Values, oldValue); i >= 0 {
+		rrset.Values = append(rrset.Values[:i], rrset.Values[i+1:]...)
+	}
+	if provider.IsSingleValueType(rec.Spec.RecordType) {
+		rrset.Values = []string{value}
+	} else if indexOf(rrset.Values, value) < 0 {
+		rrset.Values = append(rrset.Values, value)
+	}
+	rrset.TTL = ttl(rec)
+	if err := p.save(ctx, key, rrset); err != nil {
+		return err
+	}
+	rec.Status.RecordID = recordID(rec.Spec.Name, string(rec.Spec.RecordType), value)
+	return nil
+}
+
+func (p *GandiProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	key, value, err := parseRecordID(*id)
+	if err != nil {
+		return err
+	}
+	rrset, err := p.current(ctx, key)
+	if err != nil {
+		return err
+	}
+	i := indexOf(rrset.Values, value)
+	if i < 0 {
+		return nil
+	}
+	rrset.Values = append(rrset.Values[:i], rrset.Values[i+1:]...)
+	return p.save(ctx, key, rrset)
+}
+
+func (p *GandiProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	key, value, err := parseRecordID(id)
+	if err != nil {
+		return nil, err
+	}
+	rrset, ok, err := p.zone.Get(ctx, key)
+	if err != nil || !ok || indexOf(rrset.Values, value) < 0 {
+		return nil, err
+	}
+	rec := p.providerRecord(rrset, value)
+	return &rec, nil
+}
+
+func (p *GandiProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for _, rrset := range zone {
+		if rrset.Type == "SOA" {
+			continue
+		}
+		for _, value := range rrset.Values {
+			records = append(records, p.providerRecord(rrset, value))
+		}
+	}
+	return records, nil
+}
+
+func (p *GandiProvider) providerRecord(rrset zoneRecord, value string) provider.ProviderRecord {
+	name := p.absoluteName(rrset.Name)
+	return provider.ProviderRecord{
+		ID:         recordID(name, rrset.Type, value),
+		Name:       name,
+		RecordType: dnsv1.DNSRecordType(rrset.Type),
+		Value:      dnsRecordValue(rrset.Type, value),
+		TTL:        rrset.TTL,
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeGandi), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		token, err := args.SecretValue(spec.Gandi.TokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(token) == "" {
+			return nil, errors.New("gandi token is required")
+		}
+		zoneName := spec.Gandi.ZoneName
+		if zoneName == "" {
+			zoneName = spec.DomainName
+		}
+		dnsutil, err := util.NewGandiUtils(util.GandiAccount{
+			Token:    strings.TrimSpace(token),
+			Domain:   zoneName,
+			Endpoint: spec.Gandi.Endpoint,
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := &GandiProvider{
+			util:     dnsutil,
+			spec:     spec,
+			zoneName: strings.ToLower(strings.TrimSuffix(zoneName, ".")),
+		}
+		key := string(dnsv1.DNSProviderTypeGandi) + "/" + spec.Gandi.Endpoint + "/" + p.zoneName
+		p.zone = provider.GetZoneSnapshot(key, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
@@ -0,0 +1,158 @@
+package gandi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testServer is a local stand-in of the Gandi LiveDNS records API
+type testServer struct {
+	mu     sync.Mutex
+	rrsets map[string]*util.GandiRRSet
+	puts   int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 403, "message": "Access was denied to this resource.", "object": "HTTPForbidden", "cause": "Forbidden"})
+		return
+	}
+	const recordsPath = "/domains/example.com/records"
+	if r.URL.Path == recordsPath && r.Method == http.MethodGet {
+		rrsets := []*util.GandiRRSet{}
+		for _, rrset := range s.rrsets {
+			rrsets = append(rrsets, rrset)
+		}
+		json.NewEncoder(w).Encode(rrsets)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, recordsPath+"/")
+	name, rrtype, _ := strings.Cut(key, "/")
+	switch r.Method {
+	case http.MethodPut:
+		var body util.GandiRRSet
+		json.NewDecoder(r.Body).Decode(&body)
+		s.rrsets[key] = &util.GandiRRSet{Name: name, Type: rrtype, TTL: body.TTL, Values: body.Values}
+		s.puts++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"message": "DNS Record Created"})
+	case http.MethodDelete:
+		if _, ok := s.rrsets[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 404, "message": "The resource could not be found.", "cause": "Not Found"})
+			return
+		}
+		delete(s.rrsets, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestGandiProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{rrsets: map[string]*util.GandiRRSet{
+		"www/A": {Name: "www", Type: "A", TTL: 10800, Values: []string{"10.0.0.1"}},
+	}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gandi"},
+		Data:       map[string][]byte{"token": []byte("test-token")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeGandi,
+		Gandi: dnsv1.GandiProviderConfig{
+			TokenSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "gandi", Key: "token"},
+			Endpoint:       httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second value of www is added to the RRset
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "www.example.com",
+		Value:      "10.0.0.2",
+	}}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "www.example.com. A 10.0.0.2" || len(server.rrsets["www/A"].Values) != 2 {
+		t.Fatalf("unexpected id %q, rrsets %+v", id, server.rrsets)
+	}
+	if found, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok || found != id {
+		t.Fatalf("SearchRecord after create: id=%q ok=%v err=%v", found, ok, err)
+	}
+
+	rec.Spec.Value = "10.0.0.3"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if values := server.rrsets["www/A"].Values; len(values) != 2 || values[0] != "10.0.0.1" || values[1] != "10.0.0.3" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	id = rec.Status.RecordID
+	puts := server.puts
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil || server.puts != puts {
+		t.Fatalf("unchanged record is written again: err=%v", err)
+	}
+
+	// moving the record to another name leaves the first value of www alone
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeCNAME
+	rec.Spec.Name = "web.example.com"
+	rec.Spec.Value = "www.example.com"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	id = rec.Status.RecordID
+	if id != "web.example.com. CNAME www.example.com." || len(server.rrsets["www/A"].Values) != 1 || server.rrsets["web/CNAME"].Values[0] != "www.example.com." {
+		t.Fatalf("unexpected id %q, rrsets %+v", id, server.rrsets)
+	}
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	for _, record := range records {
+		if record.ID == id && (record.Name != "web.example.com" || record.Value != "www.example.com") {
+			t.Fatalf("unexpected record %+v", record)
+		}
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.rrsets["web/CNAME"]; ok || len(server.rrsets) != 1 {
+		t.Fatalf("unexpected rrsets after delete %+v", server.rrsets)
+	}
+}
@@ -0,0 +1,242 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	"golang.org/x/oauth2"
+)
+
+type zoneRecord = *util.GoogleDNSRRSet
+
+// GoogleProvider manages the RRsets of a Google Cloud DNS managed zone.
+// The record id is the name and type of the RRset, e.g. `www.example.com. A`.
+// Cloud DNS has no place to store the ownership marker, so the records are never garbage collected.
+type GoogleProvider struct {
+	util *util.GoogleDNSUtils
+	spec *dnsv1.DNSProviderSpec
+	zone *provider.ZoneSnapshot[zoneRecord]
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func recordID(name string, rrtype string) string {
+	return fqdn(strings.ToLower(name)) + " " + rrtype
+}
+
+func (p *GoogleProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	rrsets, err := p.util.ListRRSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(rrsets))
+	for _, rrset := range rrsets {
+		zone[recordID(rrset.Name, rrset.Type)] = rrset
+	}
+	return zone, nil
+}
+
+// rrdata returns the record data in the presentation format required by Cloud DNS
+func rrdata(rec *dnsv1.DNSRecord) string {
+	value := rec.Spec.Value
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeCNAME, dnsv1.DNSRecordTypeNS, dnsv1.DNSRecordTypeMX, dnsv1.DNSRecordTypeSRV:
+		// the target host name is the last field
+		return fqdn(value)
+	case dnsv1.DNSRecordTypeTXT:
+		if !strings.HasPrefix(value, `"`) {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+// newRRSet builds the RRset described by the DNSRecord
+func newRRSet(rec *dnsv1.DNSRecord) *util.GoogleDNSRRSet {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	return &util.GoogleDNSRRSet{
+		Name:    fqdn(strings.ToLower(rec.Spec.Name)),
+		Type:    string(rec.Spec.RecordType),
+		TTL:     ttl,
+		RRDatas: []string{rrdata(rec)},
+	}
+}
+
+func rrsetEquals(current, desired zoneRecord) bool {
+	return len(current.RRDatas) == 1 && current.RRDatas[0] == desired.RRDatas[0] && current.TTL == desired.TTL
+}
+
+func (p *GoogleProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{MaxTTL: math.MaxInt32}
+}
+
+func (p *GoogleProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	id = recordID(rec.Spec.Name, string(rec.Spec.RecordType))
+	_, ok, err = p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *GoogleProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	rrset := newRRSet(rec)
+	if err := p.util.ChangeRRSets(ctx, []*util.GoogleDNSRRSet{rrset}, nil); err != nil {
+		p.zone.Invalidate()
+		return "", err
+	}
+	id = recordID(rrset.Name, rrset.Type)
+	p.zone.Put(id, rrset)
+	return id, nil
+}
+
+func (p *GoogleProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	desired := newRRSet(rec)
+	newID := recordID(desired.Name, desired.Type)
+	current, ok, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if ok && newID == *id && rrsetEquals(current, desired) {
+		return nil
+	}
+
+	// the deletions must match the current RRsets, the old RRset is removed in the same change if the name or type has changed
+	var deletions []*util.GoogleDNSRRSet
+	if ok {
+		deletions = append(deletions, current)
+	}
+	if newID != *id {
+		existing, exists, err := p.zone.Get(ctx, newID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			deletions = append(deletions, existing)
+		}
+	}
+	if err := p.util.ChangeRRSets(ctx, []*util.GoogleDNSRRSet{desired}, deletions); err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	p.zone.Remove(*id)
+	p.zone.Put(newID, desired)
+	rec.Status.RecordID = newID
+	return nil
+}
+
+func (p *GoogleProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	current, ok, err := p.zone.Get(ctx, *id)
+	if err != nil || !ok {
+		return err
+	}
+	if err := p.util.ChangeRRSets(ctx, nil, []*util.GoogleDNSRRSet{current}); err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	p.zone.Remove(*id)
+	return nil
+}
+
+func (p *GoogleProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	rrset, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	rec := providerRecord(rrset)
+	return &rec, nil
+}
+
+func (p *GoogleProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for _, rrset := range zone {
+		if rrset.Type == "SOA" || len(rrset.RRDatas) == 0 {
+			continue
+		}
+		records = append(records, providerRecord(rrset))
+	}
+	return records, nil
+}
+
+func providerRecord(rrset zoneRecord) provider.ProviderRecord {
+	rec := provider.ProviderRecord{
+		ID:         recordID(rrset.Name, rrset.Type),
+		Name:       strings.TrimSuffix(rrset.Name, "."),
+		RecordType: dnsv1.DNSRecordType(rrset.Type),
+		TTL:        rrset.TTL,
+	}
+	if len(rrset.RRDatas) != 0 {
+		rec.Value = strings.TrimSuffix(rrset.RRDatas[0], ".")
+		if rrset.Type == "TXT" {
+			if unquoted, err := strconv.Unquote(rec.Value); err == nil {
+				rec.Value = unquoted
+			}
+		}
+	}
+	return rec
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeGoogle), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		cfg := &spec.Google
+		credentials, err := args.SecretValue(cfg.ServiceAccountSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ServiceAccountSecretRef != nil && strings.TrimSpace(credentials) == "" {
+			return nil, errors.New("google service account key is empty")
+		}
+		// the token source is shared by the providers with the same credentials
+		ts, err := provider.CachedClient(provider.LookupKey("Google/tokenSource", credentials), func() (oauth2.TokenSource, error) {
+			return util.NewGoogleTokenSource(credentials)
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		dnsutil, err := util.NewGoogleDNSUtils(util.GoogleDNSAccount{
+			Project:         cfg.Project,
+			ManagedZone:     cfg.ManagedZone,
+			CredentialsJSON: credentials,
+			Endpoint:        cfg.Endpoint,
+		}, ts)
+		if err != nil {
+			return nil, err
+		}
+		managedZone := cfg.ManagedZone
+		if managedZone == "" {
+			if managedZone, err = dnsutil.FindManagedZone(args.Ctx, spec.DomainName); err != nil {
+				return nil, fmt.Errorf("unable to find managed zone: %w", err)
+			}
+			dnsutil.SetManagedZone(managedZone)
+		}
+		p := &GoogleProvider{
+			util: dnsutil,
+			spec: spec,
+		}
+		key := string(dnsv1.DNSProviderTypeGoogle) + "/" + cfg.Project + "/" + managedZone
+		p.zone = provider.GetZoneSnapshot(key, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
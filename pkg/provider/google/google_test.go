@@ -0,0 +1,176 @@
+package google
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testServer is a local stand-in of the OAuth2 token endpoint and the Cloud DNS managed zone endpoints
+type testServer struct {
+	mu      sync.Mutex
+	rrsets  map[string]*util.GoogleDNSRRSet
+	changes int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/token" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "test-token", "token_type": "Bearer", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": "Invalid Credentials"}})
+		return
+	}
+	switch {
+	case r.URL.Path == "/dns/v1/projects/test-project/managedZones" && r.URL.Query().Get("dnsName") == "example.com.":
+		json.NewEncoder(w).Encode(map[string]interface{}{"managedZones": []map[string]string{{"name": "example-zone", "dnsName": "example.com."}}})
+	case r.URL.Path == "/dns/v1/projects/test-project/managedZones/example-zone/rrsets":
+		rrsets := []*util.GoogleDNSRRSet{}
+		for _, rrset := range s.rrsets {
+			rrsets = append(rrsets, rrset)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"rrsets": rrsets})
+	case r.URL.Path == "/dns/v1/projects/test-project/managedZones/example-zone/changes" && r.Method == http.MethodPost:
+		var change struct {
+			Additions []*util.GoogleDNSRRSet `json:"additions"`
+			Deletions []*util.GoogleDNSRRSet `json:"deletions"`
+		}
+		json.NewDecoder(r.Body).Decode(&change)
+		for _, rrset := range change.Deletions {
+			current, ok := s.rrsets[rrset.Name+" "+rrset.Type]
+			if !ok || current.TTL != rrset.TTL || len(current.RRDatas) != len(rrset.RRDatas) || current.RRDatas[0] != rrset.RRDatas[0] {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": "conditionNotMet"}})
+				return
+			}
+		}
+		for _, rrset := range change.Deletions {
+			delete(s.rrsets, rrset.Name+" "+rrset.Type)
+		}
+		for _, rrset := range change.Additions {
+			if _, ok := s.rrsets[rrset.Name+" "+rrset.Type]; ok {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": "alreadyExists"}})
+				return
+			}
+			s.rrsets[rrset.Name+" "+rrset.Type] = rrset
+		}
+		s.changes++
+		json.NewEncoder(w).Encode(map[string]string{"status": "done"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func serviceAccountKey(t *testing.T, tokenURL string) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	data, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "test-project",
+		"private_key_id": "test-key",
+		"private_key":    string(keyPEM),
+		"client_email":   "k8s-dns-manager@test-project.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	})
+	return data
+}
+
+func TestGoogleProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{rrsets: map[string]*util.GoogleDNSRRSet{
+		"example.com. SOA": {Name: "example.com.", Type: "SOA", TTL: 21600, RRDatas: []string{"ns-cloud-a1.googledomains.com. cloud-dns-hostmaster.google.com. 1 21600 3600 259200 300"}},
+	}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "google"},
+		Data:       map[string][]byte{"key.json": serviceAccountKey(t, httpServer.URL+"/token")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeGoogle,
+		Google: dnsv1.GoogleProviderConfig{
+			Project:                 "test-project",
+			ServiceAccountSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "google", Key: "key.json"},
+			Endpoint:                httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeTXT,
+		Name:       "www.example.com",
+		Value:      "hello world",
+	}}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "www.example.com. TXT" || server.rrsets[id].RRDatas[0] != `"hello world"` {
+		t.Fatalf("unexpected id %q and rrsets %+v", id, server.rrsets)
+	}
+
+	// unchanged records are not changed
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if server.changes != 1 {
+		t.Fatalf("unexpected changes %d", server.changes)
+	}
+
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeCNAME
+	rec.Spec.Value = "web.example.com"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if server.changes != 2 || len(server.rrsets) != 2 || server.rrsets["www.example.com. CNAME"].RRDatas[0] != "web.example.com." {
+		t.Fatalf("unexpected rrsets %+v", server.rrsets)
+	}
+	id = rec.Status.RecordID
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "www.example.com" || records[0].Value != "web.example.com" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.rrsets) != 1 {
+		t.Fatalf("unexpected rrsets after delete %+v", server.rrsets)
+	}
+}
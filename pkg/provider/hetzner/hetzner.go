@@ -0,0 +1,231 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+type zoneRecord = *util.HetznerDNSRecord
+
+// HetznerProvider manages the records of a Hetzner DNS zone.
+// The record id is the id of the record in Hetzner DNS, records of the same name and type (except CNAME)
+// with different values are kept side by side.
+// Hetzner DNS has no place to store the ownership marker, so the records are never garbage collected.
+type HetznerProvider struct {
+	util     *util.HetznerDNSUtils
+	spec     *dnsv1.DNSProviderSpec
+	zoneName string
+	zone     *provider.ZoneSnapshot[zoneRecord]
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// relativeName returns the name of the record relative to the zone, `@` for the apex
+func (p *HetznerProvider) relativeName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == p.zoneName {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+p.zoneName)
+}
+
+func (p *HetznerProvider) absoluteName(name string) string {
+	if name == "@" {
+		return p.zoneName
+	}
+	return name + "." + p.zoneName
+}
+
+func (p *HetznerProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	records, err := p.util.ListRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(records))
+	for _, record := range records {
+		zone[record.ID] = record
+	}
+	return zone, nil
+}
+
+// newRecord builds the record described by the DNSRecord, the value is in the zone file form stored by Hetzner DNS
+func (p *HetznerProvider) newRecord(rec *dnsv1.DNSRecord) *util.HetznerDNSRecord {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	value := rec.Spec.Value
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeCNAME, dnsv1.DNSRecordTypeNS, dnsv1.DNSRecordTypeMX, dnsv1.DNSRecordTypeSRV:
+		// the target host name is the last field
+		value = fqdn(value)
+	case dnsv1.DNSRecordTypeTXT:
+		if !strings.HasPrefix(value, `"`) {
+			value = strconv.Quote(value)
+		}
+	}
+	return &util.HetznerDNSRecord{
+		Name:  p.relativeName(rec.Spec.Name),
+		Type:  string(rec.Spec.RecordType),
+		Value: value,
+		TTL:   ttl,
+	}
+}
+
+// dnsRecordValue returns the value of the record in the form of DNSRecord values
+func dnsRecordValue(record zoneRecord) string {
+	value := strings.TrimSuffix(record.Value, ".")
+	if record.Type == "TXT" {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+	}
+	return value
+}
+
+// matches reports whether the record can be managed by the DNSRecord described by the desired record
+func matches(record, desired zoneRecord) bool {
+	if record.Name != desired.Name || record.Type != desired.Type {
+		return false
+	}
+	return provider.IsSingleValueType(dnsv1.DNSRecordType(desired.Type)) || dnsRecordValue(record) == dnsRecordValue(desired)
+}
+
+func (p *HetznerProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{MultiValue: true}
+}
+
+func (p *HetznerProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	if rec.Status.RecordID != "" {
+		_, ok, err := p.zone.Get(ctx, rec.Status.RecordID)
+		if err == nil && ok {
+			return rec.Status.RecordID, true, nil
+		}
+	}
+	desired := p.newRecord(rec)
+	id, _, ok, err = p.zone.Find(ctx, func(id string, record zoneRecord) bool {
+		return matches(record, desired)
+	})
+	if err != nil || !ok {
+		return "", false, err
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *HetznerProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	record, err := p.util.CreateRecord(ctx, p.newRecord(rec))
+	if err != nil {
+		p.zone.Invalidate()
+		return "", err
+	}
+	p.zone.Put(record.ID, record)
+	return record.ID, nil
+}
+
+func (p *HetznerProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	desired := p.newRecord(rec)
+	current, ok, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if ok && current.Name == desired.Name && current.Type == desired.Type && dnsRecordValue(current) == dnsRecordValue(desired) && current.TTL == desired.TTL {
+		return nil
+	}
+	if err := p.util.UpdateRecord(ctx, *id, desired); err != nil {
+		p.zone.Invalidate()
+		return err
+	}
+	desired.ID = *id
+	p.zone.Put(*id, desired)
+	return nil
+}
+
+func (p *HetznerProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	if err := p.util.DeleteRecord(ctx, *id); err != nil && !util.IsRESTStatus(err, http.StatusNotFound) {
+		p.zone.Invalidate()
+		return err
+	}
+	p.zone.Remove(*id)
+	return nil
+}
+
+func (p *HetznerProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	record, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	rec := p.providerRecord(record)
+	return &rec, nil
+}
+
+func (p *HetznerProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for _, record := range zone {
+		if record.Type == "SOA" {
+			continue
+		}
+		records = append(records, p.providerRecord(record))
+	}
+	return records, nil
+}
+
+func (p *HetznerProvider) providerRecord(record zoneRecord) provider.ProviderRecord {
+	return provider.ProviderRecord{
+		ID:         record.ID,
+		Name:       p.absoluteName(record.Name),
+		RecordType: dnsv1.DNSRecordType(record.Type),
+		Value:      dnsRecordValue(record),
+		TTL:        record.TTL,
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeHetzner), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		token, err := args.SecretValue(spec.Hetzner.APITokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(token) == "" {
+			return nil, errors.New("hetzner api token is required")
+		}
+		zoneName := spec.Hetzner.ZoneName
+		if zoneName == "" {
+			zoneName = spec.DomainName
+		}
+		dnsutil, err := util.NewHetznerDNSUtils(util.HetznerDNSAccount{
+			APIToken: strings.TrimSpace(token),
+			ZoneName: zoneName,
+			Endpoint: spec.Hetzner.Endpoint,
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := &HetznerProvider{
+			util:     dnsutil,
+			spec:     spec,
+			zoneName: strings.ToLower(strings.TrimSuffix(zoneName, ".")),
+		}
+		key := string(dnsv1.DNSProviderTypeHetzner) + "/" + spec.Hetzner.Endpoint + "/" + p.zoneName
+		p.zone = provider.GetZoneSnapshot(key, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
@@ -0,0 +1,159 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testServer is a local stand-in of the Hetzner DNS API, which rate limits the first request of each record
+type testServer struct {
+	mu          sync.Mutex
+	records     map[string]*util.HetznerDNSRecord
+	nextID      int
+	rateLimited map[string]bool
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Auth-API-Token") != "test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid authentication credentials"})
+		return
+	}
+	if r.Method != http.MethodGet && !s.rateLimited[r.Method+r.URL.Path] {
+		s.rateLimited[r.Method+r.URL.Path] = true
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	switch {
+	case r.URL.Path == "/zones":
+		json.NewEncoder(w).Encode(map[string]interface{}{"zones": []map[string]string{{"id": "zone1", "name": r.URL.Query().Get("name")}}})
+	case r.URL.Path == "/records" && r.Method == http.MethodGet:
+		records := []*util.HetznerDNSRecord{}
+		for _, record := range s.records {
+			records = append(records, record)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"records": records, "meta": map[string]interface{}{"pagination": map[string]int{"page": 1, "last_page": 1}}})
+	case r.URL.Path == "/records" && r.Method == http.MethodPost:
+		var record util.HetznerDNSRecord
+		json.NewDecoder(r.Body).Decode(&record)
+		s.nextID++
+		record.ID = "r" + strconv.Itoa(s.nextID)
+		s.records[record.ID] = &record
+		json.NewEncoder(w).Encode(map[string]interface{}{"record": record})
+	case strings.HasPrefix(r.URL.Path, "/records/"):
+		id := strings.TrimPrefix(r.URL.Path, "/records/")
+		if _, ok := s.records[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"message": "record not found", "code": 404}})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(s.records, id)
+			return
+		}
+		var record util.HetznerDNSRecord
+		json.NewDecoder(r.Body).Decode(&record)
+		record.ID = id
+		s.records[id] = &record
+		json.NewEncoder(w).Encode(map[string]interface{}{"record": record})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestHetznerProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{
+		records: map[string]*util.HetznerDNSRecord{
+			"soa": {ID: "soa", ZoneID: "zone1", Name: "@", Type: "SOA", Value: "hydrogen.ns.hetzner.com. dns.hetzner.com. 1 86400 10800 3600000 3600"},
+			"a1":  {ID: "a1", ZoneID: "zone1", Name: "www", Type: "A", Value: "10.0.0.1", TTL: 600},
+		},
+		rateLimited: map[string]bool{},
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hetzner"},
+		Data:       map[string][]byte{"token": []byte("test-token")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeHetzner,
+		Hetzner: dnsv1.HetznerProviderConfig{
+			APITokenSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "hetzner", Key: "token"},
+			Endpoint:          httpServer.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second value of www is a separate record
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "www.example.com",
+		Value:      "10.0.0.2",
+	}}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.records) != 3 || server.records[id].Name != "www" || server.records[id].ZoneID != "zone1" {
+		t.Fatalf("unexpected records %+v", server.records)
+	}
+
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeTXT
+	rec.Spec.Value = "hello world"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if server.records[id].Value != `"hello world"` {
+		t.Fatalf("unexpected record %+v", server.records[id])
+	}
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	for _, record := range records {
+		if record.ID == id && (record.Name != "www.example.com" || record.Value != "hello world") {
+			t.Fatalf("unexpected record %+v", record)
+		}
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatalf("deleting a missing record: %v", err)
+	}
+	if len(server.records) != 2 {
+		t.Fatalf("unexpected records after delete %+v", server.records)
+	}
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// lookup is a value loaded by the factories, e.g. the id of a hosted zone
+type lookup struct {
+	mu       sync.Mutex
+	value    any
+	loadedAt time.Time
+}
+
+var (
+	lookups   = map[string]*lookup{}
+	lookupsMu sync.Mutex
+)
+
+// LookupKey returns the key of a lookup depending on the values, e.g. the config of the spec,
+// so the value is loaded again once the spec is changed
+func LookupKey(name string, values ...any) string {
+	data, _ := json.Marshal(values)
+	sum := sha256.Sum256(data)
+	return name + "/" + hex.EncodeToString(sum[:])
+}
+
+// CachedLookup returns the value loaded for the key within ttl, or calls load.
+// It is shared by all reconciles creating the same provider, so the value is loaded once per ttl
+// instead of once per reconcile, and the concurrent reconciles wait for the same load.
+// Failed loads are not cached.
+func CachedLookup[T any](key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	lookupsMu.Lock()
+	l, ok := lookups[key]
+	if !ok {
+		l = &lookup{}
+		lookups[key] = l
+	}
+	lookupsMu.Unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if value, ok := l.value.(T); ok && time.Since(l.loadedAt) < ttl {
+		return value, nil
+	}
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	l.value = value
+	l.loadedAt = time.Now()
+	return value, nil
+}
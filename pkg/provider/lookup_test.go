@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCachedLookup(t *testing.T) {
+	loads := 0
+	load := func() (string, error) {
+		loads++
+		if loads == 1 {
+			return "", errors.New("lookup failed")
+		}
+		return "zone", nil
+	}
+	key := LookupKey(t.Name(), "example.com")
+	if _, err := CachedLookup(key, time.Hour, load); err == nil {
+		t.Fatal("expected the error of the load")
+	}
+	// the failed load is not cached
+	for i := 0; i < 2; i++ {
+		if value, err := CachedLookup(key, time.Hour, load); err != nil || value != "zone" {
+			t.Fatalf("CachedLookup = %s %v", value, err)
+		}
+	}
+	if loads != 2 {
+		t.Fatalf("loaded %d times", loads)
+	}
+	if LookupKey(t.Name(), "example.org") == key {
+		t.Fatal("the keys of different values are equal")
+	}
+	// the value expires after ttl
+	if _, err := CachedLookup(key, 0, load); err != nil || loads != 3 {
+		t.Fatalf("the expired value is not loaded again, %d loads, err %v", loads, err)
+	}
+}
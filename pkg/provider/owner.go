@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ownerMarkerPrefix = "k8s-dns-manager:"
+	// The prefix of the markers kept on the records retained after their DNSRecords are deleted
+	retainedMarkerPrefix = "k8s-dns-manager-retained:"
+)
+
+// AnnotationKeyOwnership is set by WithOwnership on the copies of the DNSRecords passed to the providers,
+// it changes the ownership marker written to the provider records
+const AnnotationKeyOwnership = "dns.xzzpig.com/ownership"
+
+type Ownership string
+
+const (
+	// OwnershipReleased strips the ownership marker from the record
+	OwnershipReleased Ownership = "Released"
+	// OwnershipRetained marks the record as retained, which is never garbage collected
+	OwnershipRetained Ownership = "Retained"
+)
+
+// WithOwnership returns a copy of the record, whose ownership marker is written as the ownership
+func WithOwnership(rec *dnsv1.DNSRecord, ownership Ownership) *dnsv1.DNSRecord {
+	rec = rec.DeepCopy()
+	if rec.Annotations == nil {
+		rec.Annotations = make(map[string]string)
+	}
+	rec.Annotations[AnnotationKeyOwnership] = string(ownership)
+	return rec
+}
+
+// RecordOwner identifies the DNSRecord that manages a provider record
+type RecordOwner struct {
+	OwnerID   string
+	Namespace string
+	Name      string
+	// If true, the DNSRecord was deleted with deletionPolicy Retain
+	Retained bool
+}
+
+// IsLocal reports whether the record is owned by this cluster
+func (o *RecordOwner) IsLocal() bool {
+	return o != nil && o.OwnerID == config.GetConfig().Owner.ID
+}
+
+// IsOwnerIDConfigured reports whether the owner id of this cluster is set to other than the default one.
+// The default owner id is shared by every cluster without NATM_OWNER_ID, so the records it owns may belong to another cluster.
+func IsOwnerIDConfigured() bool {
+	return config.GetConfig().Owner.ID != config.DefaultOwnerID
+}
+
+// OwnerMarker returns the marker written to the remark/comment of a provider record,
+// in the form of `k8s-dns-manager:<ownerID>:<namespace>/<name>`, or `k8s-dns-manager-retained:<ownerID>:<namespace>/<name>`
+// for the records marked as OwnershipRetained.
+// An empty marker is returned for the records marked as OwnershipReleased and the records without name.
+func OwnerMarker(rec *dnsv1.DNSRecord) string {
+	if rec == nil || rec.Name == "" {
+		return ""
+	}
+	prefix := ownerMarkerPrefix
+	switch Ownership(rec.Annotations[AnnotationKeyOwnership]) {
+	case OwnershipReleased:
+		return ""
+	case OwnershipRetained:
+		prefix = retainedMarkerPrefix
+	}
+	return prefix + config.GetConfig().Owner.ID + ":" + rec.Namespace + "/" + rec.Name
+}
+
+// ParseOwnerMarker parses a marker written by OwnerMarker, returns nil if it's not a marker
+func ParseOwnerMarker(marker string) *RecordOwner {
+	retained := strings.HasPrefix(marker, retainedMarkerPrefix)
+	if !retained && !strings.HasPrefix(marker, ownerMarkerPrefix) {
+		return nil
+	}
+	marker = strings.TrimPrefix(strings.TrimPrefix(marker, retainedMarkerPrefix), ownerMarkerPrefix)
+	sep := strings.LastIndex(marker, ":")
+	if sep < 0 {
+		return nil
+	}
+	namespace, name, ok := strings.Cut(marker[sep+1:], "/")
+	if !ok || name == "" {
+		return nil
+	}
+	return &RecordOwner{
+		OwnerID:   marker[:sep],
+		Namespace: namespace,
+		Name:      name,
+		Retained:  retained,
+	}
+}
+
+// ProviderRecord is a record as stored in the provider
+type ProviderRecord struct {
+	ID         string
+	Name       string
+	RecordType dnsv1.DNSRecordType
+	Value      string
+	TTL        int
+	Owner      *RecordOwner
+	// Provider specific attributes, in the form of DNSRecord annotations
+	Annotations map[string]string
+	// The routing policy of the record, nil for simple records
+	Routing *dnsv1.DNSRecordRouting
+}
+
+// DNSRecord converts the provider record to a DNSRecord, which can be passed to the IDNSProvider methods
+func (r *ProviderRecord) DNSRecord() *dnsv1.DNSRecord {
+	rec := &dnsv1.DNSRecord{
+		Spec: dnsv1.DNSRecordSpec{
+			RecordType: r.RecordType,
+			Name:       r.Name,
+			Value:      r.Value,
+			Routing:    r.Routing.DeepCopy(),
+		},
+		Status: dnsv1.DNSRecordStatus{
+			RecordID: r.ID,
+		},
+	}
+	if r.TTL != 0 {
+		ttl := r.TTL
+		rec.Spec.TTL = &ttl
+	}
+	if r.Owner != nil {
+		rec.ObjectMeta = metav1.ObjectMeta{
+			Namespace: r.Owner.Namespace,
+			Name:      r.Owner.Name,
+		}
+	}
+	if len(r.Annotations) != 0 {
+		rec.Annotations = make(map[string]string, len(r.Annotations))
+		for k, v := range r.Annotations {
+			rec.Annotations[k] = v
+		}
+	}
+	return rec
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOwnerMarker(t *testing.T) {
+	rec := &dnsv1.DNSRecord{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "www"}}
+	ownerID := config.GetConfig().Owner.ID
+
+	owner := ParseOwnerMarker(OwnerMarker(rec))
+	if owner == nil || owner.OwnerID != ownerID || owner.Namespace != "default" || owner.Name != "www" || owner.Retained || !owner.IsLocal() {
+		t.Fatalf("unexpected owner %+v of %s", owner, OwnerMarker(rec))
+	}
+
+	retained := WithOwnership(rec, OwnershipRetained)
+	owner = ParseOwnerMarker(OwnerMarker(retained))
+	if owner == nil || owner.Name != "www" || !owner.Retained || !owner.IsLocal() {
+		t.Fatalf("unexpected owner %+v of %s", owner, OwnerMarker(retained))
+	}
+	if rec.Annotations != nil {
+		t.Fatal("the ownership is set on the original record")
+	}
+
+	if marker := OwnerMarker(WithOwnership(rec, OwnershipReleased)); marker != "" {
+		t.Fatalf("the marker of the released record is %s", marker)
+	}
+	for _, marker := range []string{"", "hello", "k8s-dns-manager:", "k8s-dns-manager:default:www", "k8s-dns-manager-retained:default:default/"} {
+		if owner := ParseOwnerMarker(marker); owner != nil {
+			t.Errorf("ParseOwnerMarker(%q) = %+v", marker, owner)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+// zoneRecord is the local records of a domain and type, Pi-hole allows multiple values of a domain
+type zoneRecord = []util.PiHoleLocalRecord
+
+var supportedRecordTypes = []dnsv1.DNSRecordType{dnsv1.DNSRecordTypeA, dnsv1.DNSRecordTypeAAAA, dnsv1.DNSRecordTypeCNAME}
+
+// PiHoleProvider manages the local DNS records and local CNAME records of Pi-hole.
+// The record id is the domain and type of the record, e.g. `www.example.com A`.
+// Pi-hole has no place to store the ownership marker, so the records are never garbage collected.
+type PiHoleProvider struct {
+	util *util.PiHoleUtils
+	spec *dnsv1.DNSProviderSpec
+	zone *provider.ZoneSnapshot[zoneRecord]
+}
+
+func recordID(domain string, recordType string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, ".")) + " " + recordType
+}
+
+func (p *PiHoleProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	records, err := p.util.ListLocalRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(records))
+	for _, record := range records {
+		id := recordID(record.Domain, record.Type)
+		zone[id] = append(zone[id], record)
+	}
+	return zone, nil
+}
+
+func (p *PiHoleProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{RecordTypes: supportedRecordTypes}
+}
+
+// newLocalRecord builds the local record described by the DNSRecord
+func newLocalRecord(rec *dnsv1.DNSRecord) (util.PiHoleLocalRecord, error) {
+	record := util.PiHoleLocalRecord{
+		Domain: strings.ToLower(strings.TrimSuffix(rec.Spec.Name, ".")),
+		Type:   string(rec.Spec.RecordType),
+		Value:  strings.TrimSuffix(rec.Spec.Value, "."),
+	}
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeA, dnsv1.DNSRecordTypeAAAA:
+		ip := net.ParseIP(record.Value)
+		if ip == nil || (ip.To4() != nil) != (rec.Spec.RecordType == dnsv1.DNSRecordTypeA) {
+			return record, fmt.Errorf("invalid %s record value %q", rec.Spec.RecordType, rec.Spec.Value)
+		}
+	case dnsv1.DNSRecordTypeCNAME:
+		// only local CNAME records have a ttl
+		record.TTL = config.GetConfig().Default.Record.TTL
+		if rec.Spec.TTL != nil {
+			record.TTL = *rec.Spec.TTL
+		}
+	default:
+		return record, fmt.Errorf("record type %s is not supported by pihole provider", rec.Spec.RecordType)
+	}
+	return record, nil
+}
+
+func sameRecord(a, b *util.PiHoleLocalRecord) bool {
+	return a.Domain == b.Domain && a.Type == b.Type && a.Value == b.Value && a.TTL == b.TTL
+}
+
+func (p *PiHoleProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	id = recordID(rec.Spec.Name, string(rec.Spec.RecordType))
+	_, ok, err = p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *PiHoleProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	record, err := newLocalRecord(rec)
+	if err != nil {
+		return "", err
+	}
+	if err := p.util.AddLocalRecord(ctx, record); err != nil {
+		p.zone.Invalidate()
+		return "", err
+	}
+	id = recordID(record.Domain, record.Type)
+	p.zone.Put(id, zoneRecord{record})
+	return id, nil
+}
+
+func (p *PiHoleProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	desired, err := newLocalRecord(rec)
+	if err != nil {
+		return err
+	}
+	newID := recordID(desired.Domain, desired.Type)
+	current, _, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if newID != *id {
+		// the domain or type has changed, the records of the new id are replaced as well
+		newCurrent, _, err := p.zone.Get(ctx, newID)
+		if err != nil {
+			return err
+		}
+		current = append(append(zoneRecord{}, current...), newCurrent...)
+	}
+	if newID == *id && len(current) == 1 && sameRecord(&current[0], &desired) {
+		return nil
+	}
+
+	// Pi-hole has no update api, add the new record before removing the old ones
+	exists := false
+	for i := range current {
+		exists = exists || sameRecord(&current[i], &desired)
+	}
+	if !exists {
+		if err := p.util.AddLocalRecord(ctx, desired); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	for i := range current {
+		if sameRecord(&current[i], &desired) {
+			continue
+		}
+		if err := p.util.DeleteLocalRecord(ctx, current[i]); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	p.zone.Remove(*id)
+	p.zone.Put(newID, zoneRecord{desired})
+	rec.Status.RecordID = newID
+	return nil
+}
+
+func (p *PiHoleProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	current, _, err := p.zone.Get(ctx, *id)
+	if err != nil {
+		return err
+	}
+	for _, record := range current {
+		if err := p.util.DeleteLocalRecord(ctx, record); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	p.zone.Remove(*id)
+	return nil
+}
+
+func (p *PiHoleProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	current, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok || len(current) == 0 {
+		return nil, err
+	}
+	rec := providerRecord(&current[0])
+	return &rec, nil
+}
+
+func (p *PiHoleProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for _, current := range zone {
+		// Pi-hole is not partitioned by zones, only the records of the domain are listed
+		if len(current) == 0 || !inDomain(current[0].Domain, p.spec.DomainName) {
+			continue
+		}
+		records = append(records, providerRecord(&current[0]))
+	}
+	return records, nil
+}
+
+func inDomain(name string, domain string) bool {
+	name, domain = strings.ToLower(name), strings.ToLower(strings.TrimSuffix(domain, "."))
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+func providerRecord(record *util.PiHoleLocalRecord) provider.ProviderRecord {
+	return provider.ProviderRecord{
+		ID:         recordID(record.Domain, record.Type),
+		Name:       record.Domain,
+		RecordType: dnsv1.DNSRecordType(record.Type),
+		Value:      record.Value,
+		TTL:        record.TTL,
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypePiHole), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		password, err := args.SecretValue(spec.PiHole.PasswordSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		dnsutil, err := util.NewPiHoleUtils(util.PiHoleAccount{
+			ServerURL: spec.PiHole.ServerURL,
+			Password:  strings.TrimSpace(password),
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := &PiHoleProvider{
+			util: dnsutil,
+			spec: spec,
+		}
+		p.zone = provider.GetZoneSnapshot(string(dnsv1.DNSProviderTypePiHole)+"/"+spec.PiHole.ServerURL, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
@@ -0,0 +1,167 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testPassword = "test-password"
+
+// testServer is a local stand-in of the Pi-hole `/api/auth` and `/api/config/dns` endpoints
+type testServer struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	logins   int
+	config   map[string][]string
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/api/auth" {
+		var body struct {
+			Password string `json:"password"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Password != testPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"session": map[string]interface{}{"valid": false, "message": "password incorrect"}})
+			return
+		}
+		s.logins++
+		sid := "sid-" + string(rune('a'+s.logins))
+		s.sessions[sid] = true
+		json.NewEncoder(w).Encode(map[string]interface{}{"session": map[string]interface{}{"valid": true, "sid": sid}})
+		return
+	}
+	if !s.sessions[r.Header.Get("X-FTL-SID")] {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"key": "unauthorized", "message": "Unauthorized"}})
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/api/config/dns" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"config": map[string]interface{}{"dns": map[string]interface{}{
+			"hosts":        s.config["hosts"],
+			"cnameRecords": s.config["cnameRecords"],
+		}}})
+		return
+	}
+	item, value, ok := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/api/config/dns/"), "/")
+	value, _ = url.PathUnescape(value)
+	if !ok || (item != "hosts" && item != "cnameRecords") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		for _, v := range s.config[item] {
+			if v == value {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"key": "bad_request", "message": "Item already present"}})
+				return
+			}
+		}
+		s.config[item] = append(s.config[item], value)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		values := s.config[item][:0]
+		for _, v := range s.config[item] {
+			if v != value {
+				values = append(values, v)
+			}
+		}
+		if len(values) == len(s.config[item]) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.config[item] = values
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestPiHoleProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{sessions: map[string]bool{}, config: map[string][]string{"hosts": {"10.0.0.9 nas.example.com router.example.com"}}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pihole"},
+		Data:       map[string][]byte{"password": []byte(testPassword)},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypePiHole,
+		PiHole: dnsv1.PiHoleProviderConfig{
+			ServerURL:         httpServer.URL,
+			PasswordSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "pihole", Key: "password"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "www.example.com",
+		Value:      "10.0.0.1",
+	}}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "www.example.com A" || server.config["hosts"][1] != "10.0.0.1 www.example.com" {
+		t.Fatalf("unexpected id %q and hosts %+v", id, server.config["hosts"])
+	}
+
+	// the expired session is renewed
+	server.sessions = map[string]bool{}
+	ttl := 300
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeCNAME
+	rec.Spec.Value = "nas.example.com"
+	rec.Spec.TTL = &ttl
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if server.logins != 2 || len(server.config["hosts"]) != 1 || len(server.config["cnameRecords"]) != 1 || server.config["cnameRecords"][0] != "www.example.com,nas.example.com,300" {
+		t.Fatalf("unexpected config %+v after %d logins", server.config, server.logins)
+	}
+	id = rec.Status.RecordID
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.config["cnameRecords"]) != 0 {
+		t.Fatalf("unexpected cname records after delete %+v", server.config["cnameRecords"])
+	}
+
+	if err := provider.CheckRecord(p, &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeTXT}}); err == nil {
+		t.Fatal("expected TXT record to be rejected")
+	}
+}
@@ -0,0 +1,284 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+)
+
+// The account of the comments written by k8s-dns-manager
+const commentAccount = "k8s-dns-manager"
+
+type zoneRecord = *util.PowerDNSRRSet
+
+// PowerDNSProvider manages the RRsets of a zone by the PowerDNS Authoritative HTTP API, the changes of a batch are patched in one request.
+// The record id is the name and type of the RRset, e.g. `www.example.com. A`.
+type PowerDNSProvider struct {
+	util *util.PowerDNSUtils
+	spec *dnsv1.DNSProviderSpec
+	zone *provider.ZoneSnapshot[zoneRecord]
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func recordID(name string, rrtype string) string {
+	return fqdn(strings.ToLower(name)) + " " + rrtype
+}
+
+func (p *PowerDNSProvider) listZone(ctx context.Context) (map[string]zoneRecord, error) {
+	rrsets, err := p.util.ListRRSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := make(map[string]zoneRecord, len(rrsets))
+	for _, rrset := range rrsets {
+		zone[recordID(rrset.Name, rrset.Type)] = rrset
+	}
+	return zone, nil
+}
+
+// content returns the record content in the canonical form required by PowerDNS
+func content(rec *dnsv1.DNSRecord) string {
+	value := rec.Spec.Value
+	switch rec.Spec.RecordType {
+	case dnsv1.DNSRecordTypeCNAME, dnsv1.DNSRecordTypeNS, dnsv1.DNSRecordTypeMX, dnsv1.DNSRecordTypeSRV:
+		// the target host name is the last field
+		return fqdn(value)
+	case dnsv1.DNSRecordTypeTXT:
+		if !strings.HasPrefix(value, `"`) {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+// newRRSet builds the RRset described by the DNSRecord
+func newRRSet(rec *dnsv1.DNSRecord) *util.PowerDNSRRSet {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	rrset := &util.PowerDNSRRSet{
+		Name:       fqdn(rec.Spec.Name),
+		Type:       string(rec.Spec.RecordType),
+		TTL:        ttl,
+		ChangeType: "REPLACE",
+		Records:    []util.PowerDNSRecord{{Content: content(rec)}},
+		Comments:   []util.PowerDNSComment{},
+	}
+	if marker := provider.OwnerMarker(rec); marker != "" {
+		rrset.Comments = append(rrset.Comments, util.PowerDNSComment{Content: marker, Account: commentAccount})
+	}
+	return rrset
+}
+
+func ownerMarker(rrset zoneRecord) string {
+	for _, comment := range rrset.Comments {
+		if provider.ParseOwnerMarker(comment.Content) != nil {
+			return comment.Content
+		}
+	}
+	return ""
+}
+
+func rrsetEquals(current, desired zoneRecord) bool {
+	if len(current.Records) != 1 || current.Records[0].Content != desired.Records[0].Content || current.Records[0].Disabled {
+		return false
+	}
+	return current.TTL == desired.TTL && ownerMarker(current) == ownerMarker(desired)
+}
+
+func (p *PowerDNSProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{MaxTTL: math.MaxInt32, Ownership: true}
+}
+
+func (p *PowerDNSProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	id = recordID(rec.Spec.Name, string(rec.Spec.RecordType))
+	_, ok, err = p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *PowerDNSProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	change := &provider.RecordChange{Action: provider.ChangeActionCreate, Record: rec}
+	if err := p.ApplyChanges(ctx, []*provider.RecordChange{change}); err != nil {
+		return "", err
+	}
+	return change.ID, nil
+}
+
+func (p *PowerDNSProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	return p.ApplyChanges(ctx, []*provider.RecordChange{{Action: provider.ChangeActionUpdate, Record: rec, ID: *id}})
+}
+
+func (p *PowerDNSProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	return p.ApplyChanges(ctx, []*provider.RecordChange{{Action: provider.ChangeActionDelete, Record: rec, ID: *id}})
+}
+
+// rrsetsOf returns the RRsets patched by the change, nil if the record is unchanged.
+// The returned commit updates the zone snapshot and the ids of the change once the RRsets are patched.
+func (p *PowerDNSProvider) rrsetsOf(ctx context.Context, change *provider.RecordChange) (rrsets []*util.PowerDNSRRSet, commit func(), err error) {
+	switch change.Action {
+	case provider.ChangeActionCreate:
+		rrset := newRRSet(change.Record)
+		return []*util.PowerDNSRRSet{rrset}, func() {
+			change.ID = recordID(rrset.Name, rrset.Type)
+			p.zone.Put(change.ID, rrset)
+		}, nil
+	case provider.ChangeActionUpdate:
+		desired := newRRSet(change.Record)
+		newID := recordID(desired.Name, desired.Type)
+		current, ok, err := p.zone.Get(ctx, change.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok && newID == change.ID && rrsetEquals(current, desired) {
+			return nil, func() {}, nil
+		}
+
+		if ok && newID == change.ID {
+			// keep the comments not written by k8s-dns-manager
+			for _, comment := range current.Comments {
+				if provider.ParseOwnerMarker(comment.Content) == nil {
+					desired.Comments = append(desired.Comments, comment)
+				}
+			}
+		}
+		rrsets := []*util.PowerDNSRRSet{desired}
+		if ok && newID != change.ID {
+			// the name or type has changed, remove the old RRset in the same request
+			rrsets = append(rrsets, &util.PowerDNSRRSet{Name: current.Name, Type: current.Type, ChangeType: "DELETE"})
+		}
+		return rrsets, func() {
+			p.zone.Remove(change.ID)
+			p.zone.Put(newID, desired)
+			change.ID = newID
+			change.Record.Status.RecordID = newID
+		}, nil
+	case provider.ChangeActionDelete:
+		name, rrtype, ok := strings.Cut(change.ID, " ")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid record id %q", change.ID)
+		}
+		return []*util.PowerDNSRRSet{{Name: name, Type: rrtype, ChangeType: "DELETE"}}, func() {
+			p.zone.Remove(change.ID)
+		}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown change action %q", change.Action)
+}
+
+// ApplyChanges patches the RRsets of all changes in one request, which PowerDNS applies atomically
+func (p *PowerDNSProvider) ApplyChanges(ctx context.Context, changes []*provider.RecordChange) error {
+	var rrsets []*util.PowerDNSRRSet
+	commits := make([]func(), 0, len(changes))
+	for _, change := range changes {
+		r, commit, err := p.rrsetsOf(ctx, change)
+		if err != nil {
+			return err
+		}
+		rrsets = append(rrsets, r...)
+		commits = append(commits, commit)
+	}
+	if len(rrsets) != 0 {
+		if err := p.util.PatchRRSets(ctx, rrsets...); err != nil {
+			p.zone.Invalidate()
+			return err
+		}
+	}
+	for _, commit := range commits {
+		commit()
+	}
+	return nil
+}
+
+func (p *PowerDNSProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	rrset, ok, err := p.zone.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	rec := providerRecord(rrset)
+	return &rec, nil
+}
+
+func (p *PowerDNSProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	zone, err := p.zone.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(zone))
+	for _, rrset := range zone {
+		if rrset.Type == "SOA" || len(rrset.Records) == 0 {
+			continue
+		}
+		records = append(records, providerRecord(rrset))
+	}
+	return records, nil
+}
+
+func providerRecord(rrset zoneRecord) provider.ProviderRecord {
+	rec := provider.ProviderRecord{
+		ID:         recordID(rrset.Name, rrset.Type),
+		Name:       strings.TrimSuffix(rrset.Name, "."),
+		RecordType: dnsv1.DNSRecordType(rrset.Type),
+		TTL:        rrset.TTL,
+		Owner:      provider.ParseOwnerMarker(ownerMarker(rrset)),
+	}
+	if len(rrset.Records) != 0 {
+		rec.Value = strings.TrimSuffix(rrset.Records[0].Content, ".")
+		if rrset.Type == "TXT" {
+			if unquoted, err := strconv.Unquote(rec.Value); err == nil {
+				rec.Value = unquoted
+			}
+		}
+	}
+	return rec
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypePowerDNS), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		apiKey, err := args.SecretValue(spec.PowerDNS.APIKeySecretRef)
+		if err != nil {
+			return nil, err
+		}
+		if apiKey == "" {
+			return nil, errors.New("powerdns api key is required")
+		}
+		zone := spec.PowerDNS.Zone
+		if zone == "" {
+			zone = spec.DomainName
+		}
+		dnsutil, err := util.NewPowerDNSUtils(util.PowerDNSAccount{
+			ServerURL: spec.PowerDNS.ServerURL,
+			ServerID:  spec.PowerDNS.ServerID,
+			APIKey:    strings.TrimSpace(apiKey),
+			Zone:      zone,
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := &PowerDNSProvider{
+			util: dnsutil,
+			spec: spec,
+		}
+		key := string(dnsv1.DNSProviderTypePowerDNS) + "/" + spec.PowerDNS.ServerURL + "/" + spec.PowerDNS.ServerID + "/" + zone
+		p.zone = provider.GetZoneSnapshot(key, spec.ZoneSyncDuration(), p.listZone)
+		return p, nil
+	})
+}
@@ -0,0 +1,201 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	"github.com/xzzpig/k8s-dns-manager/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testAPIKey = "test-api-key"
+
+// testServer is a local stand-in of the `/api/v1/servers/{id}/zones` endpoints
+type testServer struct {
+	mu      sync.Mutex
+	rrsets  []*util.PowerDNSRRSet
+	patches [][]*util.PowerDNSRRSet
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("X-API-Key") != testAPIKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+		return
+	}
+	if r.URL.Path != "/api/v1/servers/localhost/zones/example.com." {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Could not find domain"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": "example.com.", "rrsets": s.rrsets})
+	case http.MethodPatch:
+		var body struct {
+			RRSets []*util.PowerDNSRRSet `json:"rrsets"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.patches = append(s.patches, body.RRSets)
+		for _, change := range body.RRSets {
+			rrsets := s.rrsets[:0]
+			for _, rrset := range s.rrsets {
+				if rrset.Name != change.Name || rrset.Type != change.Type {
+					rrsets = append(rrsets, rrset)
+				}
+			}
+			s.rrsets = rrsets
+			if change.ChangeType == "REPLACE" {
+				rrset := *change
+				rrset.ChangeType = ""
+				s.rrsets = append(s.rrsets, &rrset)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newProvider(t *testing.T, apiKey string) (provider.IDNSProvider, *testServer) {
+	server := &testServer{}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pdns"},
+		Data:       map[string][]byte{"apiKey": []byte(apiKey)},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(context.Background(), reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypePowerDNS,
+		PowerDNS: dnsv1.PowerDNSProviderConfig{
+			ServerURL:       httpServer.URL,
+			APIKeySecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "pdns", Key: "apiKey"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, server
+}
+
+func TestPowerDNSProvider(t *testing.T) {
+	ctx := context.Background()
+	p, server := newProvider(t, testAPIKey)
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeCNAME,
+		Name:       "www.example.com",
+		Value:      "web.example.com",
+	}}
+	rec.Namespace = "default"
+	rec.Name = "www"
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "www.example.com. CNAME" {
+		t.Fatalf("unexpected id %q", id)
+	}
+	created := server.rrsets[0]
+	if created.Records[0].Content != "web.example.com." || len(created.Comments) != 1 || created.Comments[0].Content != provider.OwnerMarker(rec) {
+		t.Fatalf("unexpected rrset %+v", created)
+	}
+
+	// unchanged records are not patched
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.patches) != 1 {
+		t.Fatalf("unexpected patches %+v", server.patches)
+	}
+
+	rec.Spec.Name = "blog.example.com"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	last := server.patches[len(server.patches)-1]
+	if len(last) != 2 || last[0].ChangeType != "REPLACE" || last[1].ChangeType != "DELETE" {
+		t.Fatalf("expected REPLACE and DELETE in one patch, got %+v", last)
+	}
+	id = rec.Status.RecordID
+
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "blog.example.com" || records[0].Value != "web.example.com" || !records[0].Owner.IsLocal() {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.rrsets) != 0 {
+		t.Fatalf("unexpected rrsets after delete %+v", server.rrsets)
+	}
+}
+
+func TestPowerDNSProviderUnauthorized(t *testing.T) {
+	p, _ := newProvider(t, "wrong-key")
+	_, err := p.CreateRecord(context.Background(), &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "www.example.com",
+		Value:      "192.168.1.1",
+	}})
+	if err == nil {
+		t.Fatal("expected request with a wrong api key to fail")
+	}
+}
+
+func TestPowerDNSProviderApplyChanges(t *testing.T) {
+	ctx := context.Background()
+	p, server := newProvider(t, testAPIKey)
+
+	www := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "www.example.com", Value: "10.0.0.1"}}
+	api := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "api.example.com", Value: "10.0.0.2"}}
+	changes := []*provider.RecordChange{
+		{Action: provider.ChangeActionCreate, Record: www},
+		{Action: provider.ChangeActionCreate, Record: api},
+	}
+	if err := p.(provider.IDNSBatchProvider).ApplyChanges(ctx, changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.patches) != 1 || len(server.patches[0]) != 2 {
+		t.Fatalf("expected one patch of 2 RRsets, got %+v", server.patches)
+	}
+	if changes[0].ID != "www.example.com. A" || changes[1].ID != "api.example.com. A" {
+		t.Fatalf("unexpected ids %q %q", changes[0].ID, changes[1].ID)
+	}
+
+	// the unchanged record is skipped, the renamed record is replaced in the same patch
+	api.Spec.Name = "app.example.com"
+	changes = []*provider.RecordChange{
+		{Action: provider.ChangeActionUpdate, Record: www, ID: changes[0].ID},
+		{Action: provider.ChangeActionUpdate, Record: api, ID: changes[1].ID},
+	}
+	if err := p.(provider.IDNSBatchProvider).ApplyChanges(ctx, changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.patches) != 2 || len(server.patches[1]) != 2 || api.Status.RecordID != "app.example.com. A" {
+		t.Fatalf("unexpected patches %+v, id %q", server.patches, api.Status.RecordID)
+	}
+}
@@ -2,9 +2,15 @@ package provider
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 
 	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type IDNSProvider interface {
@@ -14,9 +20,120 @@ type IDNSProvider interface {
 	DeleteRecord(ctx context.Context, record *dnsv1.DNSRecord, id *string) (err error)
 }
 
+// IDNSRecordGetter is implemented by providers which can fetch a single record by id
+type IDNSRecordGetter interface {
+	GetRecord(ctx context.Context, id string) (*ProviderRecord, error)
+}
+
+// IDNSRecordLister is implemented by providers which can list all records of their zone
+type IDNSRecordLister interface {
+	ListRecords(ctx context.Context) ([]ProviderRecord, error)
+}
+
+// IDNSProviderCapabilities is implemented by providers which declare the records they support
+type IDNSProviderCapabilities interface {
+	Capabilities() dnsv1.DNSProviderCapabilities
+}
+
+// GetCapabilities returns the capabilities declared by the provider, nil if unknown
+func GetCapabilities(p IDNSProvider) *dnsv1.DNSProviderCapabilities {
+	declarer, ok := p.(IDNSProviderCapabilities)
+	if !ok {
+		return nil
+	}
+	capabilities := declarer.Capabilities()
+	return &capabilities
+}
+
+// IDNSMultiZoneProvider is implemented by providers managing the records of several zones,
+// the DNSRecords are matched by the zones instead of spec.domainName of the DNSProvider
+type IDNSMultiZoneProvider interface {
+	// Zones returns the names of the managed zones, nil if the provider manages a single zone
+	Zones() []string
+}
+
+// GetZones returns the zones managed by the provider, nil if the provider manages the zone of spec.domainName
+func GetZones(p IDNSProvider) []string {
+	if multiZone, ok := p.(IDNSMultiZoneProvider); ok {
+		return multiZone.Zones()
+	}
+	return nil
+}
+
+// CheckRecord returns an error if the record is not supported by the capabilities of the provider
+func CheckRecord(p IDNSProvider, rec *dnsv1.DNSRecord) error {
+	capabilities := GetCapabilities(p)
+	if capabilities == nil {
+		return nil
+	}
+	return capabilities.Check(rec)
+}
+
+// IsSingleValueType reports whether a name can have only one record of the type.
+// Providers supporting multiple values keep the records of other types with the same name side by side,
+// each managed by its own DNSRecord.
+func IsSingleValueType(recordType dnsv1.DNSRecordType) bool {
+	return recordType == dnsv1.DNSRecordTypeCNAME
+}
+
 type DNSProviderFactoryArgs struct {
-	Spec *dnsv1.DNSProviderSpec
-	Ctx  context.Context
+	Spec   *dnsv1.DNSProviderSpec
+	Ctx    context.Context
+	Client client.Client
+}
+
+// Secret reads the referenced Secret
+func (args *DNSProviderFactoryArgs) Secret(namespace, name string) (*corev1.Secret, error) {
+	if args.Client == nil {
+		return nil, fmt.Errorf("unable to read secret %s/%s: no client", namespace, name)
+	}
+	var secret corev1.Secret
+	if err := args.Client.Get(args.Ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// SecretValue reads the value of the referenced Secret key
+func (args *DNSProviderFactoryArgs) SecretValue(ref *dnsv1.SecretKeySelector) (string, error) {
+	if ref == nil {
+		return "", nil
+	}
+	secret, err := args.Secret(ref.Namespace, ref.Name)
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret %s/%s", ref.Key, ref.Namespace, ref.Name)
+	}
+	return string(value), nil
+}
+
+// TLSConfig builds the TLS client config from the referenced Secret with keys `ca.crt`, `tls.crt` and `tls.key`,
+// the content of the keys is returned as well to detect changes of the Secret
+func (args *DNSProviderFactoryArgs) TLSConfig(ref *dnsv1.ObjectReference) (*tls.Config, []byte, error) {
+	secret, err := args.Secret(ref.Namespace, ref.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca := secret.Data["ca.crt"]; len(ca) != 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, nil, fmt.Errorf("invalid ca.crt in secret %s/%s", ref.Namespace, ref.Name)
+		}
+		cfg.RootCAs = pool
+	}
+	if len(secret.Data["tls.crt"]) != 0 {
+		cert, err := tls.X509KeyPair(secret.Data["tls.crt"], secret.Data["tls.key"])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid client certificate in secret %s/%s: %w", ref.Namespace, ref.Name, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	data := append(append(append([]byte{}, secret.Data["ca.crt"]...), secret.Data["tls.crt"]...), secret.Data["tls.key"]...)
+	return cfg, data, nil
 }
 
 type DNSProviderFactory func(*DNSProviderFactoryArgs) (IDNSProvider, error)
@@ -28,11 +145,30 @@ func Register(name string, factory DNSProviderFactory) {
 	providers[name] = factory
 }
 
-func New(ctx context.Context, provider *dnsv1.DNSProviderSpec) (IDNSProvider, error) {
+// CallContext takes a token from the rate limit of the spec shared by the key, usually the name of the DNSProvider,
+// and returns the context of a call to the provider API, which is cancelled after the timeout of the spec.
+// Every call to the provider API made by the controllers is rate limited this way,
+// the providers take a token by WaitCallRateLimit for every further request made in the call.
+func CallContext(ctx context.Context, key string, spec *dnsv1.DNSProviderSpec) (context.Context, context.CancelFunc, error) {
+	if err := WaitRateLimit(ctx, key, spec.RateLimit); err != nil {
+		return ctx, func() {}, err
+	}
+	ctx = context.WithValue(ctx, callRateLimitKey{}, callRateLimit{key: key, config: spec.RateLimit})
+	ctx, cancel := context.WithTimeout(ctx, spec.TimeoutDuration())
+	return ctx, cancel, nil
+}
+
+// New creates the provider of the spec, the calls made by the factory are bound to the timeout of the spec,
+// so the factories must not keep args.Ctx
+func New(ctx context.Context, c client.Client, provider *dnsv1.DNSProviderSpec) (IDNSProvider, error) {
 	if factory, ok := providers[string(provider.ProviderType)]; ok {
+		// the lookups of the factories are cached, so they are not rate limited
+		ctx, cancel := context.WithTimeout(ctx, provider.TimeoutDuration())
+		defer cancel()
 		return factory(&DNSProviderFactoryArgs{
-			Spec: provider,
-			Ctx:  ctx,
+			Spec:   provider,
+			Ctx:    ctx,
+			Client: c,
 		})
 	}
 	return nil, ErrProviderNotFound
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+)
+
+type rateLimiter struct {
+	config  dnsv1.DNSProviderRateLimit
+	limiter *rate.Limiter
+}
+
+var (
+	rateLimiters   = map[string]*rateLimiter{}
+	rateLimitersMu sync.Mutex
+)
+
+func getRateLimiter(key string, config *dnsv1.DNSProviderRateLimit) *rate.Limiter {
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+	if l, ok := rateLimiters[key]; ok && l.config == *config {
+		return l.limiter
+	}
+	// the limiter is recreated when the config is changed
+	limiter := rate.NewLimiter(rate.Every(config.PeriodDuration()/time.Duration(config.Requests)), config.BurstSize())
+	rateLimiters[key] = &rateLimiter{config: *config, limiter: limiter}
+	return limiter
+}
+
+// RateLimitDelay returns the delay until a token of the token bucket shared by the key is available, without taking it.
+// It always returns 0 if config is nil.
+func RateLimitDelay(key string, config *dnsv1.DNSProviderRateLimit) time.Duration {
+	if config == nil || config.Requests <= 0 {
+		return 0
+	}
+	// the reservation is cancelled at the time it's made, so the token is restored even if available now
+	now := time.Now()
+	reservation := getRateLimiter(key, config).ReserveN(now, 1)
+	defer reservation.CancelAt(now)
+	return reservation.DelayFrom(now)
+}
+
+// WaitRateLimit takes a token from the token bucket shared by the key, usually the name of the DNSProvider,
+// and waits until the token is available. It returns an error without taking the token if ctx is done first.
+// It never waits if config is nil.
+func WaitRateLimit(ctx context.Context, key string, config *dnsv1.DNSProviderRateLimit) error {
+	if config == nil || config.Requests <= 0 {
+		return nil
+	}
+	return getRateLimiter(key, config).Wait(ctx)
+}
+
+type callRateLimitKey struct{}
+
+// callRateLimit is the token bucket of a call to the provider, see CallContext
+type callRateLimit struct {
+	key    string
+	config *dnsv1.DNSProviderRateLimit
+}
+
+// WaitCallRateLimit takes another token from the token bucket of the call made with ctx by CallContext,
+// for the providers calling the provider API more than once in a call, e.g. to look up the records of a batch.
+// It never waits if ctx is not made by CallContext.
+func WaitCallRateLimit(ctx context.Context) error {
+	if limit, ok := ctx.Value(callRateLimitKey{}).(callRateLimit); ok {
+		return WaitRateLimit(ctx, limit.key, limit.config)
+	}
+	return nil
+}
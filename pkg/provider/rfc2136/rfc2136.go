@@ -0,0 +1,342 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+const tsigFudge = 300
+
+var tsigAlgorithms = map[string]string{
+	"hmac-md5":    dns.HmacMD5,
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// RFC2136Provider manages records by RFC 2136 dynamic updates.
+// The record id is the owner name and type of the RRset, e.g. `www.example.com. A`.
+type RFC2136Provider struct {
+	spec          *dnsv1.DNSProviderSpec
+	nameserver    string
+	zone          string
+	client        *dns.Client
+	tsigKeyName   string
+	tsigAlgorithm string
+}
+
+// axfrProvider searches and lists records by zone transfer
+type axfrProvider struct {
+	*RFC2136Provider
+}
+
+func recordID(name string, rrtype uint16) string {
+	return dns.Fqdn(name) + " " + dns.TypeToString[rrtype]
+}
+
+func parseRecordID(id string) (name string, rrtype uint16, err error) {
+	fields := strings.Fields(id)
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("invalid record id %q", id)
+	}
+	rrtype, ok := dns.StringToType[fields[1]]
+	if !ok {
+		return "", 0, fmt.Errorf("invalid record type %q", fields[1])
+	}
+	return fields[0], rrtype, nil
+}
+
+// newRR builds the resource record described by the DNSRecord
+func newRR(rec *dnsv1.DNSRecord) (dns.RR, error) {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	value := rec.Spec.Value
+	if rec.Spec.RecordType == dnsv1.DNSRecordTypeTXT && !strings.HasPrefix(value, `"`) {
+		value = strconv.Quote(value)
+	}
+	return dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(rec.Spec.Name), ttl, rec.Spec.RecordType, value))
+}
+
+func (p *RFC2136Provider) sign(m *dns.Msg) {
+	if p.tsigKeyName != "" {
+		m.SetTsig(p.tsigKeyName, p.tsigAlgorithm, tsigFudge, time.Now().Unix())
+	}
+}
+
+func (p *RFC2136Provider) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	p.sign(m)
+	resp, _, err := p.client.ExchangeContext(ctx, m, p.nameserver)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s: %s", p.nameserver, dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+// lookup returns the RRset of the given name and type
+func (p *RFC2136Provider) lookup(ctx context.Context, name string, rrtype uint16) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), rrtype)
+	m.RecursionDesired = false
+	resp, err := p.exchange(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	var rrs []dns.RR
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == rrtype && strings.EqualFold(rr.Header().Name, dns.Fqdn(name)) {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, nil
+}
+
+// transfer returns all records of the zone by AXFR
+func (p *RFC2136Provider) transfer(ctx context.Context) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetAxfr(p.zone)
+	p.sign(m)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.nameserver)
+	if err != nil {
+		return nil, err
+	}
+	// the transfer does not accept a context, so its connection is closed once ctx is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	t := &dns.Transfer{Conn: &dns.Conn{Conn: conn}, TsigSecret: p.client.TsigSecret}
+	if deadline, ok := ctx.Deadline(); ok {
+		t.ReadTimeout = time.Until(deadline)
+	}
+	envelopes, err := t.In(m, p.nameserver)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var rrs []dns.RR
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, envelope.Error
+		}
+		rrs = append(rrs, envelope.RR...)
+	}
+	return rrs, nil
+}
+
+func (p *RFC2136Provider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{MaxTTL: math.MaxInt32}
+}
+
+func (p *RFC2136Provider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	rrtype := dns.StringToType[string(rec.Spec.RecordType)]
+	rrs, err := p.lookup(ctx, rec.Spec.Name, rrtype)
+	if err != nil {
+		return "", false, err
+	}
+	if len(rrs) == 0 {
+		return "", false, nil
+	}
+	rec.Status.RecordID = recordID(rec.Spec.Name, rrtype)
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *axfrProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	rrs, err := p.transfer(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	rrtype := dns.StringToType[string(rec.Spec.RecordType)]
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == rrtype && strings.EqualFold(rr.Header().Name, dns.Fqdn(rec.Spec.Name)) {
+			rec.Status.RecordID = recordID(rec.Spec.Name, rrtype)
+			return rec.Status.RecordID, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// replace replaces the RRset of the record with the single RR described by the DNSRecord
+func (p *RFC2136Provider) replace(ctx context.Context, rec *dnsv1.DNSRecord, old string) error {
+	rr, err := newRR(rec)
+	if err != nil {
+		return err
+	}
+	m := new(dns.Msg)
+	m.SetUpdate(p.zone)
+	if old != "" {
+		name, rrtype, err := parseRecordID(old)
+		if err != nil {
+			return err
+		}
+		m.RemoveRRset([]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET}}})
+	}
+	m.RemoveRRset([]dns.RR{rr})
+	m.Insert([]dns.RR{rr})
+	_, err = p.exchange(ctx, m)
+	return err
+}
+
+func (p *RFC2136Provider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	if err := p.replace(ctx, rec, ""); err != nil {
+		return "", err
+	}
+	return recordID(rec.Spec.Name, dns.StringToType[string(rec.Spec.RecordType)]), nil
+}
+
+func (p *RFC2136Provider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	desired, err := newRR(rec)
+	if err != nil {
+		return err
+	}
+	newID := recordID(rec.Spec.Name, desired.Header().Rrtype)
+	if *id == newID {
+		rrs, err := p.lookup(ctx, rec.Spec.Name, desired.Header().Rrtype)
+		if err != nil {
+			return err
+		}
+		if len(rrs) == 1 && dns.IsDuplicate(rrs[0], desired) && rrs[0].Header().Ttl == desired.Header().Ttl {
+			return nil
+		}
+		return p.replace(ctx, rec, "")
+	}
+	// the name or type has changed, remove the old RRset in the same update
+	if err := p.replace(ctx, rec, *id); err != nil {
+		return err
+	}
+	rec.Status.RecordID = newID
+	return nil
+}
+
+func (p *RFC2136Provider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	name, rrtype, err := parseRecordID(*id)
+	if err != nil {
+		return err
+	}
+	m := new(dns.Msg)
+	m.SetUpdate(p.zone)
+	m.RemoveRRset([]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET}}})
+	_, err = p.exchange(ctx, m)
+	return err
+}
+
+func (p *RFC2136Provider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	name, rrtype, err := parseRecordID(id)
+	if err != nil {
+		return nil, err
+	}
+	rrs, err := p.lookup(ctx, name, rrtype)
+	if err != nil || len(rrs) == 0 {
+		return nil, err
+	}
+	rec := providerRecord(rrs[0])
+	return &rec, nil
+}
+
+func (p *axfrProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	rrs, err := p.transfer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(rrs))
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			continue
+		}
+		records = append(records, providerRecord(rr))
+	}
+	return records, nil
+}
+
+func providerRecord(rr dns.RR) provider.ProviderRecord {
+	hdr := rr.Header()
+	// the value is the rdata part of the presentation format
+	value := strings.TrimPrefix(rr.String(), hdr.String())
+	if hdr.Rrtype == dns.TypeTXT {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+	}
+	return provider.ProviderRecord{
+		ID:         recordID(hdr.Name, hdr.Rrtype),
+		Name:       strings.TrimSuffix(hdr.Name, "."),
+		RecordType: dnsv1.DNSRecordType(dns.TypeToString[hdr.Rrtype]),
+		Value:      value,
+		TTL:        int(hdr.Ttl),
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeRFC2136), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		cfg := &spec.RFC2136
+		if cfg.Nameserver == "" {
+			return nil, errors.New("rfc2136 nameserver is required")
+		}
+		p := &RFC2136Provider{
+			spec:       spec,
+			nameserver: cfg.Nameserver,
+			zone:       cfg.Zone,
+			client:     &dns.Client{Net: cfg.Protocol},
+		}
+		if _, _, err := net.SplitHostPort(p.nameserver); err != nil {
+			p.nameserver = net.JoinHostPort(p.nameserver, "53")
+		}
+		if p.zone == "" {
+			p.zone = spec.DomainName
+		}
+		p.zone = dns.Fqdn(p.zone)
+
+		if cfg.TSIGKeyName != "" {
+			algorithm := cfg.TSIGAlgorithm
+			if algorithm == "" {
+				algorithm = "hmac-sha256"
+			}
+			p.tsigAlgorithm = tsigAlgorithms[algorithm]
+			if p.tsigAlgorithm == "" {
+				return nil, fmt.Errorf("unsupported tsig algorithm %s", algorithm)
+			}
+			secret, err := args.SecretValue(cfg.TSIGSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			if secret == "" {
+				return nil, errors.New("rfc2136 tsig secret is required")
+			}
+			p.tsigKeyName = dns.Fqdn(cfg.TSIGKeyName)
+			p.client.TsigSecret = map[string]string{p.tsigKeyName: strings.TrimSpace(secret)}
+		}
+
+		if cfg.AXFR {
+			return &axfrProvider{p}, nil
+		}
+		return p, nil
+	})
+}
@@ -0,0 +1,259 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testZone    = "example.com."
+	testKeyName = "k8s-dns-manager."
+	testSecret  = "c2VjcmV0LWtleS1mb3ItdGVzdGluZw=="
+)
+
+// testServer is an in-memory authoritative server accepting signed dynamic updates
+type testServer struct {
+	mu      sync.Mutex
+	records []dns.RR
+}
+
+func (s *testServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if r.IsTsig() != nil {
+		if w.TsigStatus() != nil {
+			m.Rcode = dns.RcodeNotAuth
+			w.WriteMsg(m)
+			return
+		}
+		m.SetTsig(testKeyName, dns.HmacSHA256, 300, time.Now().Unix())
+	}
+
+	soa, _ := dns.NewRR(testZone + " 3600 IN SOA ns.example.com. admin.example.com. 1 3600 600 86400 60")
+	switch {
+	case r.Opcode == dns.OpcodeUpdate:
+		if r.IsTsig() == nil {
+			m.Rcode = dns.RcodeRefused
+			break
+		}
+		for _, rr := range r.Ns {
+			hdr := rr.Header()
+			if hdr.Class == dns.ClassANY {
+				s.remove(hdr.Name, hdr.Rrtype)
+			} else {
+				s.records = append(s.records, rr)
+			}
+		}
+	case r.Question[0].Qtype == dns.TypeAXFR:
+		m.Answer = append([]dns.RR{soa}, s.records...)
+		m.Answer = append(m.Answer, soa)
+	default:
+		q := r.Question[0]
+		for _, rr := range s.records {
+			if strings.EqualFold(rr.Header().Name, q.Name) && rr.Header().Rrtype == q.Qtype {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+	}
+	w.WriteMsg(m)
+}
+
+func (s *testServer) remove(name string, rrtype uint16) {
+	records := s.records[:0]
+	for _, rr := range s.records {
+		if !strings.EqualFold(rr.Header().Name, name) || rr.Header().Rrtype != rrtype {
+			records = append(records, rr)
+		}
+	}
+	s.records = records
+}
+
+func startServer(t *testing.T, network string) (string, *testServer) {
+	handler := &testServer{}
+	server := &dns.Server{
+		Net:        network,
+		Handler:    handler,
+		TsigSecret: map[string]string{testKeyName: testSecret},
+		// the default accept func rejects UPDATE messages
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() { close(started) }
+	var addr string
+	if network == "tcp" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.Listener = listener
+		addr = listener.Addr().String()
+	} else {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.PacketConn = conn
+		addr = conn.LocalAddr().String()
+	}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	return addr, handler
+}
+
+func newProvider(t *testing.T, addr string, protocol string, axfr bool) provider.IDNSProvider {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tsig"},
+		Data:       map[string][]byte{"secret": []byte(testSecret)},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(context.Background(), reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeRFC2136,
+		RFC2136: dnsv1.RFC2136ProviderConfig{
+			Nameserver:    addr,
+			Protocol:      protocol,
+			TSIGKeyName:   testKeyName,
+			TSIGAlgorithm: "hmac-sha256",
+			TSIGSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "tsig", Key: "secret"},
+			AXFR:          axfr,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRFC2136Provider(t *testing.T) {
+	for _, protocol := range []string{"udp", "tcp"} {
+		t.Run(protocol, func(t *testing.T) {
+			ctx := context.Background()
+			addr, server := startServer(t, protocol)
+			p := newProvider(t, addr, protocol, false)
+
+			ttl := 300
+			rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+				RecordType: dnsv1.DNSRecordTypeA,
+				Name:       "www.example.com",
+				Value:      "192.168.1.1",
+				TTL:        &ttl,
+			}}
+			if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+				t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+			}
+			id, err := p.CreateRecord(ctx, rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != "www.example.com. A" {
+				t.Fatalf("unexpected id %q", id)
+			}
+			if found, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok || found != id {
+				t.Fatalf("SearchRecord after create: id=%q ok=%v err=%v", found, ok, err)
+			}
+
+			rec.Spec.Value = "192.168.1.2"
+			if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+				t.Fatal(err)
+			}
+			current, err := p.(provider.IDNSRecordGetter).GetRecord(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.Value != "192.168.1.2" || current.TTL != ttl || len(server.records) != 1 {
+				t.Fatalf("unexpected record after update: %+v", current)
+			}
+
+			if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+				t.Fatalf("SearchRecord after delete: ok=%v err=%v", ok, err)
+			}
+		})
+	}
+}
+
+func TestRFC2136ProviderAXFR(t *testing.T) {
+	ctx := context.Background()
+	addr, _ := startServer(t, "tcp")
+	p := newProvider(t, addr, "tcp", true)
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeTXT,
+		Name:       "txt.example.com",
+		Value:      "hello world",
+	}}
+	if _, err := p.CreateRecord(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok {
+		t.Fatalf("SearchRecord: ok=%v err=%v", ok, err)
+	}
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "txt.example.com" || records[0].Value != "hello world" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+}
+
+func TestRFC2136ProviderRejectsWrongKey(t *testing.T) {
+	addr, _ := startServer(t, "udp")
+	p := newProvider(t, addr, "udp", false).(*RFC2136Provider)
+	p.client.TsigSecret[p.tsigKeyName] = "d3Jvbmcta2V5"
+
+	_, err := p.CreateRecord(context.Background(), &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "www.example.com",
+		Value:      "192.168.1.1",
+	}})
+	if err == nil {
+		t.Fatal("expected update signed with a wrong key to fail")
+	}
+}
+
+func TestRFC2136ProviderAXFRCancel(t *testing.T) {
+	// a nameserver accepting the transfer without ever answering
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	p := newProvider(t, listener.Addr().String(), "tcp", true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+		t.Fatalf("expected the transfer to be cancelled with ctx: %v after %v", err, time.Since(start))
+	}
+}
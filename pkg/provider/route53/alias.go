@@ -0,0 +1,95 @@
+package route53
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+)
+
+const (
+	// If `true`, the record is created as an alias to the AWS resource in spec.value, e.g. an ELB
+	AnnotationKeyAlias = "dns.xzzpig.com/record-route53-alias"
+	// The hosted zone id of the alias target, if empty, it's resolved from the hostname of the ELB
+	AnnotationKeyAliasHostedZoneID = "dns.xzzpig.com/record-route53-alias-hosted-zone-id"
+	// If `true`, the alias record inherits the health of the alias target
+	AnnotationKeyEvaluateTargetHealth = "dns.xzzpig.com/record-route53-evaluate-target-health"
+)
+
+var (
+	// e.g. `my-lb-1234567890.us-east-1.elb.amazonaws.com`
+	elbHostnameRegex = regexp.MustCompile(`\.([a-z]{2}-[a-z]+-\d)\.elb\.amazonaws\.com\.?$`)
+	// e.g. `my-nlb-0123456789abcdef.elb.us-east-1.amazonaws.com`
+	nlbHostnameRegex = regexp.MustCompile(`\.elb\.([a-z]{2}-[a-z]+-\d)\.amazonaws\.com\.?$`)
+)
+
+// The canonical hosted zone ids of Classic and Application Load Balancers
+var elbHostedZoneIDs = map[string]string{
+	"us-east-1":      "Z35SXDOTRQ7X7K",
+	"us-east-2":      "Z3AADJGX6KTTL2",
+	"us-west-1":      "Z368ELLRRE2KJ0",
+	"us-west-2":      "Z1H1FL5HABSF5",
+	"ca-central-1":   "ZQSVJUPU6J1EY",
+	"ap-south-1":     "ZP97RAFLXTNZK",
+	"ap-northeast-1": "Z14GRHDCWA56QT",
+	"ap-southeast-1": "Z1LMS91P8CMLE5",
+	"ap-southeast-2": "Z1GM3OXH4ZPM65",
+	"eu-central-1":   "Z215JYRZR1TBD5",
+	"eu-west-1":      "Z32O12XQLNTSW2",
+	"eu-west-2":      "ZHURV8PSTC4K8",
+	"sa-east-1":      "Z2P70J7HTTTPLU",
+}
+
+// The canonical hosted zone ids of Network Load Balancers
+var nlbHostedZoneIDs = map[string]string{
+	"us-east-1":      "Z26RNL4JYFTOTI",
+	"us-east-2":      "ZLMOA37VPKANP",
+	"us-west-1":      "Z24FKFUX50B4VW",
+	"us-west-2":      "Z18D5FSROUN65G",
+	"ca-central-1":   "Z2EPGBW3API2WT",
+	"ap-south-1":     "ZVDDRBQ08TROA",
+	"ap-northeast-1": "Z31USIVHYNEOWT",
+	"ap-southeast-1": "ZKVM4W9LS7TM",
+	"ap-southeast-2": "ZCT6FZBF4DROD",
+	"eu-central-1":   "Z3F0SRJ5LGBH90",
+	"eu-west-1":      "Z2IFOLAFXWLO4F",
+	"eu-west-2":      "ZD4D7Y8KGAS4G",
+	"sa-east-1":      "ZTK26PT1VY4CU",
+}
+
+// elbHostedZoneID returns the canonical hosted zone id of the load balancer hostname
+func elbHostedZoneID(hostname string) string {
+	if match := nlbHostnameRegex.FindStringSubmatch(hostname); match != nil {
+		return nlbHostedZoneIDs[match[1]]
+	}
+	if match := elbHostnameRegex.FindStringSubmatch(hostname); match != nil {
+		return elbHostedZoneIDs[match[1]]
+	}
+	return ""
+}
+
+func isAlias(rec *dnsv1.DNSRecord) bool {
+	return rec.Annotations[AnnotationKeyAlias] == "true"
+}
+
+// aliasTarget returns the alias target of the record, or nil if the record is not an alias
+func aliasTarget(rec *dnsv1.DNSRecord) (*types.AliasTarget, bool) {
+	if !isAlias(rec) {
+		return nil, true
+	}
+	hostedZoneID := rec.Annotations[AnnotationKeyAliasHostedZoneID]
+	if hostedZoneID == "" {
+		hostedZoneID = elbHostedZoneID(rec.Spec.Value)
+	}
+	if hostedZoneID == "" {
+		return nil, false
+	}
+	evaluateTargetHealth, _ := strconv.ParseBool(rec.Annotations[AnnotationKeyEvaluateTargetHealth])
+	return &types.AliasTarget{
+		DNSName:              aws.String(rec.Spec.Value),
+		HostedZoneId:         aws.String(hostedZoneID),
+		EvaluateTargetHealth: evaluateTargetHealth,
+	}, true
+}
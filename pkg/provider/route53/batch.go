@@ -0,0 +1,99 @@
+package route53
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+// changesOf returns the changes of the record sets made by the change, nil if the record is unchanged.
+// The returned commit sets the ids of the change once the changes are submitted.
+// Each lookup of the current record set takes a token from the rate limit of the call.
+func (p *Route53Provider) changesOf(ctx context.Context, change *provider.RecordChange) (changes []types.Change, commit func(), err error) {
+	rec := change.Record
+	switch change.Action {
+	case provider.ChangeActionCreate:
+		rrset, err := recordSet(rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []types.Change{{Action: types.ChangeActionUpsert, ResourceRecordSet: rrset}}, func() {
+			change.ID = recordID(rec.Spec.Name, rrset.Type, setIdentifier(rec))
+		}, nil
+	case provider.ChangeActionUpdate:
+		desired, err := recordSet(rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		name, rrtype, setID, err := parseRecordID(change.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := provider.WaitCallRateLimit(ctx); err != nil {
+			return nil, nil, err
+		}
+		current, err := p.lookup(ctx, name, rrtype, setID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if current != nil && recordSetEqual(current, desired) {
+			return nil, func() {}, nil
+		}
+		changes = []types.Change{{Action: types.ChangeActionUpsert, ResourceRecordSet: desired}}
+		newID := recordID(rec.Spec.Name, desired.Type, setIdentifier(rec))
+		if current != nil && newID != change.ID {
+			// the name, type or set identifier has changed, remove the old record set in the same batch
+			changes = append([]types.Change{{Action: types.ChangeActionDelete, ResourceRecordSet: current}}, changes...)
+		}
+		return changes, func() {
+			change.ID = newID
+			rec.Status.RecordID = newID
+		}, nil
+	case provider.ChangeActionDelete:
+		name, rrtype, setID, err := parseRecordID(change.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		// DELETE requires the exact record set
+		if err := provider.WaitCallRateLimit(ctx); err != nil {
+			return nil, nil, err
+		}
+		current, err := p.lookup(ctx, name, rrtype, setID)
+		if err != nil || current == nil {
+			return nil, func() {}, err
+		}
+		return []types.Change{{Action: types.ChangeActionDelete, ResourceRecordSet: current}}, func() {}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown change action %q", change.Action)
+}
+
+// ApplyChanges submits the changes in one ChangeResourceRecordSets call, which is atomic
+func (p *Route53Provider) ApplyChanges(ctx context.Context, changes []*provider.RecordChange) error {
+	var batch []types.Change
+	commits := make([]func(), 0, len(changes))
+	for _, change := range changes {
+		c, commit, err := p.changesOf(ctx, change)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, c...)
+		commits = append(commits, commit)
+	}
+	if len(batch) != 0 {
+		_, err := p.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(p.hostedZoneID),
+			ChangeBatch:  &types.ChangeBatch{Changes: batch},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, commit := range commits {
+		commit()
+	}
+	return nil
+}
@@ -0,0 +1,460 @@
+package route53
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+// Route53Provider manages the records of a Route 53 hosted zone, the changes of a batch are submitted in one change batch.
+// The record id is the name and type of the record set, e.g. `www.example.com. A`,
+// followed by the set identifier for the record sets with a routing policy, e.g. `www.example.com. A blue`.
+type Route53Provider struct {
+	spec         *dnsv1.DNSProviderSpec
+	client       *route53.Client
+	hostedZoneID string
+	zoneName     string
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// normalizeName returns the record name as written in DNSRecord, Route 53 escapes `*` as `\052`
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.ReplaceAll(name, `\052`, "*"), "."))
+}
+
+func recordID(name string, rrtype types.RRType, setIdentifier string) string {
+	id := fqdn(normalizeName(name)) + " " + string(rrtype)
+	if setIdentifier != "" {
+		id += " " + setIdentifier
+	}
+	return id
+}
+
+func parseRecordID(id string) (name string, rrtype types.RRType, setIdentifier string, err error) {
+	fields := strings.SplitN(id, " ", 3)
+	if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+		return "", "", "", fmt.Errorf("invalid record id %q", id)
+	}
+	if len(fields) == 3 {
+		setIdentifier = fields[2]
+	}
+	return fields[0], types.RRType(fields[1]), setIdentifier, nil
+}
+
+// setIdentifier returns the set identifier of the record set described by the DNSRecord
+func setIdentifier(rec *dnsv1.DNSRecord) string {
+	if rec.Spec.Routing == nil {
+		return ""
+	}
+	return rec.Spec.Routing.SetIdentifier
+}
+
+// recordType returns the type of the record set, alias CNAME records are created as A records
+func recordType(rec *dnsv1.DNSRecord) types.RRType {
+	if isAlias(rec) && rec.Spec.RecordType == dnsv1.DNSRecordTypeCNAME {
+		return types.RRTypeA
+	}
+	return types.RRType(rec.Spec.RecordType)
+}
+
+// recordSet builds the record set described by the DNSRecord
+func recordSet(rec *dnsv1.DNSRecord) (*types.ResourceRecordSet, error) {
+	target, ok := aliasTarget(rec)
+	if !ok {
+		return nil, fmt.Errorf("unable to resolve the hosted zone id of alias target %s, set it by annotation %s", rec.Spec.Value, AnnotationKeyAliasHostedZoneID)
+	}
+	rrset := &types.ResourceRecordSet{
+		Name: aws.String(fqdn(rec.Spec.Name)),
+		Type: recordType(rec),
+	}
+	if err := applyRouting(rrset, rec.Spec.Routing); err != nil {
+		return nil, err
+	}
+	if target != nil {
+		rrset.AliasTarget = target
+		return rrset, nil
+	}
+
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	value := rec.Spec.Value
+	if rec.Spec.RecordType == dnsv1.DNSRecordTypeTXT && !strings.HasPrefix(value, `"`) {
+		value = strconv.Quote(value)
+	}
+	rrset.TTL = aws.Int64(int64(ttl))
+	rrset.ResourceRecords = []types.ResourceRecord{{Value: aws.String(value)}}
+	return rrset, nil
+}
+
+// applyRouting sets the routing policy of the record set, Route 53 allows one of weighted, geolocation and failover routing
+func applyRouting(rrset *types.ResourceRecordSet, routing *dnsv1.DNSRecordRouting) error {
+	if routing == nil {
+		return nil
+	}
+	policies := 0
+	for _, set := range []bool{routing.Weight != nil, routing.Geo != "", routing.Failover != ""} {
+		if set {
+			policies++
+		}
+	}
+	if policies > 1 {
+		return errors.New("only one of routing.weight, routing.geo and routing.failover can be set for route53")
+	}
+	if policies == 1 && routing.SetIdentifier == "" {
+		return errors.New("routing.setIdentifier is required by route53 for weighted, geo and failover records")
+	}
+	if policies == 0 && routing.SetIdentifier != "" {
+		return errors.New("routing.setIdentifier requires one of routing.weight, routing.geo and routing.failover for route53")
+	}
+
+	if routing.SetIdentifier != "" {
+		rrset.SetIdentifier = aws.String(routing.SetIdentifier)
+	}
+	if routing.Weight != nil {
+		rrset.Weight = aws.Int64(*routing.Weight)
+	}
+	if routing.Geo != "" {
+		location, err := geoLocation(routing.Geo)
+		if err != nil {
+			return err
+		}
+		rrset.GeoLocation = location
+	}
+	switch routing.Failover {
+	case dnsv1.DNSRecordFailoverRolePrimary:
+		rrset.Failover = types.ResourceRecordSetFailoverPrimary
+	case dnsv1.DNSRecordFailoverRoleSecondary:
+		rrset.Failover = types.ResourceRecordSetFailoverSecondary
+	}
+	if routing.HealthCheckID != "" {
+		rrset.HealthCheckId = aws.String(routing.HealthCheckID)
+	}
+	return nil
+}
+
+// geoLocation parses routing.geo, e.g. `continent:EU`, `US`, `US-CA` or `*` for the default location
+func geoLocation(geo string) (*types.GeoLocation, error) {
+	if strings.HasPrefix(geo, "continent:") {
+		continent := strings.TrimPrefix(geo, "continent:")
+		if continent == "" {
+			return nil, fmt.Errorf("invalid routing.geo %q", geo)
+		}
+		return &types.GeoLocation{ContinentCode: aws.String(strings.ToUpper(continent))}, nil
+	}
+	country, subdivision, _ := strings.Cut(geo, "-")
+	if country == "" {
+		return nil, fmt.Errorf("invalid routing.geo %q", geo)
+	}
+	location := &types.GeoLocation{CountryCode: aws.String(strings.ToUpper(country))}
+	if subdivision != "" {
+		location.SubdivisionCode = aws.String(strings.ToUpper(subdivision))
+	}
+	return location, nil
+}
+
+// geoString formats the geolocation of a record set as routing.geo
+func geoString(location *types.GeoLocation) string {
+	if location == nil {
+		return ""
+	}
+	if location.ContinentCode != nil {
+		return "continent:" + aws.ToString(location.ContinentCode)
+	}
+	geo := aws.ToString(location.CountryCode)
+	if location.SubdivisionCode != nil {
+		geo += "-" + aws.ToString(location.SubdivisionCode)
+	}
+	return geo
+}
+
+// routingOf returns the routing policy of the record set, nil for simple record sets
+func routingOf(rrset *types.ResourceRecordSet) *dnsv1.DNSRecordRouting {
+	if rrset.SetIdentifier == nil && rrset.HealthCheckId == nil {
+		return nil
+	}
+	routing := &dnsv1.DNSRecordRouting{
+		SetIdentifier: aws.ToString(rrset.SetIdentifier),
+		Geo:           geoString(rrset.GeoLocation),
+		HealthCheckID: aws.ToString(rrset.HealthCheckId),
+	}
+	if rrset.Weight != nil {
+		weight := aws.ToInt64(rrset.Weight)
+		routing.Weight = &weight
+	}
+	switch rrset.Failover {
+	case types.ResourceRecordSetFailoverPrimary:
+		routing.Failover = dnsv1.DNSRecordFailoverRolePrimary
+	case types.ResourceRecordSetFailoverSecondary:
+		routing.Failover = dnsv1.DNSRecordFailoverRoleSecondary
+	}
+	return routing
+}
+
+func routingEqual(a, b *types.ResourceRecordSet) bool {
+	return aws.ToString(a.SetIdentifier) == aws.ToString(b.SetIdentifier) &&
+		(a.Weight == nil) == (b.Weight == nil) && aws.ToInt64(a.Weight) == aws.ToInt64(b.Weight) &&
+		geoString(a.GeoLocation) == geoString(b.GeoLocation) &&
+		a.Failover == b.Failover &&
+		aws.ToString(a.HealthCheckId) == aws.ToString(b.HealthCheckId)
+}
+
+func recordSetEqual(a, b *types.ResourceRecordSet) bool {
+	if normalizeName(aws.ToString(a.Name)) != normalizeName(aws.ToString(b.Name)) || a.Type != b.Type || !routingEqual(a, b) {
+		return false
+	}
+	if (a.AliasTarget == nil) != (b.AliasTarget == nil) {
+		return false
+	}
+	if a.AliasTarget != nil {
+		return normalizeName(aws.ToString(a.AliasTarget.DNSName)) == normalizeName(aws.ToString(b.AliasTarget.DNSName)) &&
+			aws.ToString(a.AliasTarget.HostedZoneId) == aws.ToString(b.AliasTarget.HostedZoneId) &&
+			a.AliasTarget.EvaluateTargetHealth == b.AliasTarget.EvaluateTargetHealth
+	}
+	if aws.ToInt64(a.TTL) != aws.ToInt64(b.TTL) || len(a.ResourceRecords) != len(b.ResourceRecords) {
+		return false
+	}
+	for i := range a.ResourceRecords {
+		if aws.ToString(a.ResourceRecords[i].Value) != aws.ToString(b.ResourceRecords[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the record set of the given name, type and set identifier, or nil if not found
+func (p *Route53Provider) lookup(ctx context.Context, name string, rrtype types.RRType, setIdentifier string) (*types.ResourceRecordSet, error) {
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(p.hostedZoneID),
+		StartRecordName: aws.String(fqdn(name)),
+		StartRecordType: rrtype,
+		MaxItems:        aws.Int32(1),
+	}
+	if setIdentifier != "" {
+		input.StartRecordIdentifier = aws.String(setIdentifier)
+	}
+	output, err := p.client.ListResourceRecordSets(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	for i := range output.ResourceRecordSets {
+		rrset := &output.ResourceRecordSets[i]
+		if normalizeName(aws.ToString(rrset.Name)) == normalizeName(name) && rrset.Type == rrtype &&
+			aws.ToString(rrset.SetIdentifier) == setIdentifier {
+			return rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *Route53Provider) Capabilities() dnsv1.DNSProviderCapabilities {
+	return dnsv1.DNSProviderCapabilities{
+		MaxTTL:      math.MaxInt32,
+		Annotations: []string{AnnotationKeyAlias, AnnotationKeyAliasHostedZoneID, AnnotationKeyEvaluateTargetHealth},
+		RoutingPolicies: []dnsv1.DNSRoutingPolicy{
+			dnsv1.DNSRoutingPolicyWeighted, dnsv1.DNSRoutingPolicyGeo, dnsv1.DNSRoutingPolicyFailover, dnsv1.DNSRoutingPolicyHealthCheck,
+		},
+	}
+}
+
+func (p *Route53Provider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	rrtype := recordType(rec)
+	rrset, err := p.lookup(ctx, rec.Spec.Name, rrtype, setIdentifier(rec))
+	if err != nil {
+		return "", false, err
+	}
+	if rrset == nil {
+		return "", false, nil
+	}
+	rec.Status.RecordID = recordID(rec.Spec.Name, rrtype, setIdentifier(rec))
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *Route53Provider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	change := &provider.RecordChange{Action: provider.ChangeActionCreate, Record: rec}
+	if err := p.ApplyChanges(ctx, []*provider.RecordChange{change}); err != nil {
+		return "", err
+	}
+	return change.ID, nil
+}
+
+func (p *Route53Provider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	return p.ApplyChanges(ctx, []*provider.RecordChange{{Action: provider.ChangeActionUpdate, Record: rec, ID: *id}})
+}
+
+func (p *Route53Provider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	return p.ApplyChanges(ctx, []*provider.RecordChange{{Action: provider.ChangeActionDelete, Record: rec, ID: *id}})
+}
+
+func (p *Route53Provider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	name, rrtype, setIdentifier, err := parseRecordID(id)
+	if err != nil {
+		return nil, err
+	}
+	rrset, err := p.lookup(ctx, name, rrtype, setIdentifier)
+	if err != nil || rrset == nil {
+		return nil, err
+	}
+	rec := providerRecord(rrset)
+	return &rec, nil
+}
+
+func (p *Route53Provider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	var records []provider.ProviderRecord
+	input := &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(p.hostedZoneID)}
+	for {
+		output, err := p.client.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for i := range output.ResourceRecordSets {
+			rrset := &output.ResourceRecordSets[i]
+			// the SOA and NS records of the zone apex are managed by Route 53
+			if rrset.Type == types.RRTypeSoa || (rrset.Type == types.RRTypeNs && normalizeName(aws.ToString(rrset.Name)) == p.zoneName) {
+				continue
+			}
+			records = append(records, providerRecord(rrset))
+		}
+		if !output.IsTruncated {
+			return records, nil
+		}
+		input.StartRecordName = output.NextRecordName
+		input.StartRecordType = output.NextRecordType
+		input.StartRecordIdentifier = output.NextRecordIdentifier
+	}
+}
+
+func providerRecord(rrset *types.ResourceRecordSet) provider.ProviderRecord {
+	rec := provider.ProviderRecord{
+		ID:         recordID(aws.ToString(rrset.Name), rrset.Type, aws.ToString(rrset.SetIdentifier)),
+		Name:       normalizeName(aws.ToString(rrset.Name)),
+		RecordType: dnsv1.DNSRecordType(rrset.Type),
+		TTL:        int(aws.ToInt64(rrset.TTL)),
+		Routing:    routingOf(rrset),
+	}
+	if rrset.AliasTarget != nil {
+		rec.Value = strings.TrimSuffix(aws.ToString(rrset.AliasTarget.DNSName), ".")
+		rec.Annotations = map[string]string{
+			AnnotationKeyAlias:                "true",
+			AnnotationKeyAliasHostedZoneID:    aws.ToString(rrset.AliasTarget.HostedZoneId),
+			AnnotationKeyEvaluateTargetHealth: strconv.FormatBool(rrset.AliasTarget.EvaluateTargetHealth),
+		}
+		return rec
+	}
+	if len(rrset.ResourceRecords) != 0 {
+		rec.Value = aws.ToString(rrset.ResourceRecords[0].Value)
+		if rrset.Type == types.RRTypeTxt {
+			if unquoted, err := strconv.Unquote(rec.Value); err == nil {
+				rec.Value = unquoted
+			}
+		}
+	}
+	return rec
+}
+
+// findHostedZone returns the id of the hosted zone with the name and type
+func findHostedZone(ctx context.Context, client *route53.Client, zoneName string, zoneType dnsv1.Route53ZoneType) (string, error) {
+	output, err := client.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName: aws.String(fqdn(zoneName)),
+	})
+	if err != nil {
+		return "", err
+	}
+	private := zoneType == dnsv1.Route53ZoneTypePrivate
+	var ids []string
+	for _, zone := range output.HostedZones {
+		if normalizeName(aws.ToString(zone.Name)) != normalizeName(zoneName) {
+			continue
+		}
+		if zone.Config != nil && zone.Config.PrivateZone != private {
+			continue
+		}
+		ids = append(ids, strings.TrimPrefix(aws.ToString(zone.Id), "/hostedzone/"))
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("%s hosted zone %s not found", strings.ToLower(string(zoneType)), zoneName)
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("found %d %s hosted zones named %s, set hostedZoneId to choose one", len(ids), strings.ToLower(string(zoneType)), zoneName)
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeRoute53), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		cfg := &spec.Route53
+
+		region := cfg.Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		optFns := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(region)}
+		if cfg.AccessKeyID != "" {
+			secret, err := args.SecretValue(cfg.SecretAccessKeySecretRef)
+			if err != nil {
+				return nil, err
+			}
+			if secret == "" {
+				return nil, errors.New("route53 secret access key is required")
+			}
+			optFns = append(optFns, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, strings.TrimSpace(secret), "")))
+		}
+		awsCfg, err := awsconfig.LoadDefaultConfig(args.Ctx, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		client := route53.NewFromConfig(awsCfg, func(o *route53.Options) {
+			if cfg.Endpoint != "" {
+				o.EndpointResolver = route53.EndpointResolverFromURL(cfg.Endpoint)
+			}
+		})
+
+		zoneName := cfg.ZoneName
+		if zoneName == "" {
+			zoneName = spec.DomainName
+		}
+		hostedZoneID := cfg.HostedZoneID
+		if hostedZoneID == "" {
+			zoneType := cfg.ZoneType
+			if zoneType == "" {
+				zoneType = dnsv1.Route53ZoneTypePublic
+			}
+			// the discovery is shared by the reconciles of all records, as Route 53 allows 5 requests per second per account
+			key := provider.LookupKey("Route53/hostedZone", cfg, zoneName, zoneType)
+			hostedZoneID, err = provider.CachedLookup(key, spec.ZoneSyncDuration(), func() (string, error) {
+				return findHostedZone(args.Ctx, client, zoneName, zoneType)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &Route53Provider{
+			spec:         spec,
+			client:       client,
+			hostedZoneID: hostedZoneID,
+			zoneName:     normalizeName(zoneName),
+		}, nil
+	})
+}
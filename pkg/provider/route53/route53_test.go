@@ -0,0 +1,488 @@
+package route53
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type testAliasTarget struct {
+	HostedZoneId         string
+	DNSName              string
+	EvaluateTargetHealth bool
+}
+
+type testGeoLocation struct {
+	ContinentCode   string `xml:",omitempty"`
+	CountryCode     string `xml:",omitempty"`
+	SubdivisionCode string `xml:",omitempty"`
+}
+
+type testRRSet struct {
+	Name            string
+	Type            string
+	SetIdentifier   string           `xml:",omitempty"`
+	Weight          *int64           `xml:",omitempty"`
+	GeoLocation     *testGeoLocation `xml:",omitempty"`
+	Failover        string           `xml:",omitempty"`
+	HealthCheckId   string           `xml:",omitempty"`
+	TTL             int64            `xml:",omitempty"`
+	AliasTarget     *testAliasTarget `xml:",omitempty"`
+	ResourceRecords *struct {
+		ResourceRecord []struct{ Value string }
+	} `xml:",omitempty"`
+}
+
+type testChange struct {
+	Action            string
+	ResourceRecordSet testRRSet
+}
+
+type testHostedZone struct {
+	Id              string
+	Name            string
+	CallerReference string
+	Config          struct{ PrivateZone bool }
+}
+
+// testServer is a local stand-in of the Route 53 API
+type testServer struct {
+	mu      sync.Mutex
+	zones   []testHostedZone
+	records map[string][]testRRSet
+	batches [][]testChange
+	// the requests of ListHostedZonesByName
+	lookups int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/2013-04-01/")
+	switch {
+	case path == "hostedzonesbyname":
+		s.lookups++
+		s.write(w, struct {
+			XMLName     xml.Name `xml:"ListHostedZonesByNameResponse"`
+			HostedZones struct{ HostedZone []testHostedZone }
+			IsTruncated bool
+			MaxItems    int
+		}{HostedZones: struct{ HostedZone []testHostedZone }{HostedZone: s.zones}, MaxItems: 100})
+	case strings.HasPrefix(path, "hostedzone/") && r.Method == http.MethodGet:
+		zoneID := strings.Split(path, "/")[1]
+		query := r.URL.Query()
+		var records []testRRSet
+		start := testRRSet{Name: query.Get("name"), Type: query.Get("type"), SetIdentifier: query.Get("identifier")}
+		for _, rrset := range s.records[zoneID] {
+			if start.Name != "" && rrsetLess(rrset, start) {
+				continue
+			}
+			records = append(records, rrset)
+		}
+		if query.Get("maxitems") == "1" && len(records) > 1 {
+			records = records[:1]
+		}
+		s.write(w, struct {
+			XMLName            xml.Name `xml:"ListResourceRecordSetsResponse"`
+			ResourceRecordSets struct{ ResourceRecordSet []testRRSet }
+			IsTruncated        bool
+			MaxItems           int
+		}{ResourceRecordSets: struct{ ResourceRecordSet []testRRSet }{ResourceRecordSet: records}, MaxItems: 100})
+	case strings.HasPrefix(path, "hostedzone/") && r.Method == http.MethodPost:
+		zoneID := strings.Split(path, "/")[1]
+		var req struct {
+			ChangeBatch struct {
+				Changes struct{ Change []testChange }
+			}
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		changes := req.ChangeBatch.Changes.Change
+		s.batches = append(s.batches, changes)
+		for _, change := range changes {
+			s.remove(zoneID, change.ResourceRecordSet)
+			if change.Action == "UPSERT" {
+				s.records[zoneID] = append(s.records[zoneID], change.ResourceRecordSet)
+			}
+		}
+		sort.Slice(s.records[zoneID], func(i, j int) bool {
+			return rrsetLess(s.records[zoneID][i], s.records[zoneID][j])
+		})
+		fmt.Fprint(w, `<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C1</Id><Status>PENDING</Status><SubmittedAt>2023-01-01T00:00:00Z</SubmittedAt></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// rrsetLess orders the record sets by name, type and set identifier like Route 53
+func rrsetLess(a, b testRRSet) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return a.SetIdentifier < b.SetIdentifier
+}
+
+func (s *testServer) remove(zoneID string, removed testRRSet) {
+	records := s.records[zoneID][:0]
+	for _, rrset := range s.records[zoneID] {
+		if rrset.Name != removed.Name || rrset.Type != removed.Type || rrset.SetIdentifier != removed.SetIdentifier {
+			records = append(records, rrset)
+		}
+	}
+	s.records[zoneID] = records
+}
+
+func (s *testServer) write(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func startServer(t *testing.T) *testServer {
+	server := &testServer{records: map[string][]testRRSet{}}
+	for _, zone := range []struct {
+		id      string
+		private bool
+	}{{"ZPUBLIC", false}, {"ZPRIVATE", true}} {
+		hostedZone := testHostedZone{Id: "/hostedzone/" + zone.id, Name: "example.com.", CallerReference: zone.id}
+		hostedZone.Config.PrivateZone = zone.private
+		server.zones = append(server.zones, hostedZone)
+	}
+	return server
+}
+
+func newProvider(t *testing.T, server *testServer, cfg dnsv1.Route53ProviderConfig) provider.IDNSProvider {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "aws"},
+		Data:       map[string][]byte{"secret": []byte("test-secret")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	cfg.Endpoint = httpServer.URL
+	cfg.AccessKeyID = "test-key"
+	cfg.SecretAccessKeySecretRef = &dnsv1.SecretKeySelector{Namespace: "default", Name: "aws", Key: "secret"}
+	p, err := provider.New(context.Background(), reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeRoute53,
+		Route53:      cfg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRoute53ProviderHostedZoneDiscovery(t *testing.T) {
+	server := startServer(t)
+	if p := newProvider(t, server, dnsv1.Route53ProviderConfig{}).(*Route53Provider); p.hostedZoneID != "ZPUBLIC" {
+		t.Fatalf("unexpected public hosted zone %s", p.hostedZoneID)
+	}
+	if p := newProvider(t, server, dnsv1.Route53ProviderConfig{ZoneType: dnsv1.Route53ZoneTypePrivate}).(*Route53Provider); p.hostedZoneID != "ZPRIVATE" {
+		t.Fatalf("unexpected private hosted zone %s", p.hostedZoneID)
+	}
+}
+
+func TestRoute53ProviderHostedZoneCache(t *testing.T) {
+	server := startServer(t)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "aws"},
+		Data:       map[string][]byte{"secret": []byte("test-secret")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	spec := &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeRoute53,
+		Route53: dnsv1.Route53ProviderConfig{
+			Endpoint:                 httpServer.URL,
+			AccessKeyID:              "test-key",
+			SecretAccessKeySecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "aws", Key: "secret"},
+		},
+	}
+
+	// the provider is created on every reconcile of every record
+	for i := 0; i < 3; i++ {
+		p, err := provider.New(context.Background(), reader, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.(*Route53Provider).hostedZoneID != "ZPUBLIC" {
+			t.Fatalf("unexpected hosted zone %s", p.(*Route53Provider).hostedZoneID)
+		}
+	}
+	if server.lookups != 1 {
+		t.Fatalf("the hosted zone is discovered %d times", server.lookups)
+	}
+
+	// a changed spec discovers the hosted zone again
+	spec.Route53.ZoneType = dnsv1.Route53ZoneTypePrivate
+	p, err := provider.New(context.Background(), reader, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.(*Route53Provider).hostedZoneID != "ZPRIVATE" || server.lookups != 2 {
+		t.Fatalf("unexpected hosted zone %s after %d lookups", p.(*Route53Provider).hostedZoneID, server.lookups)
+	}
+}
+
+func TestRoute53Provider(t *testing.T) {
+	ctx := context.Background()
+	server := startServer(t)
+	p := newProvider(t, server, dnsv1.Route53ProviderConfig{})
+
+	ttl := 300
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "www.example.com",
+		Value:      "192.168.1.1",
+		TTL:        &ttl,
+	}}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "www.example.com. A" {
+		t.Fatalf("unexpected id %q", id)
+	}
+	if found, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok || found != id {
+		t.Fatalf("SearchRecord after create: id=%q ok=%v err=%v", found, ok, err)
+	}
+
+	// unchanged records are not submitted
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.batches) != 1 {
+		t.Fatalf("unexpected change batches %+v", server.batches)
+	}
+
+	rec.Spec.Name = "web.example.com"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	last := server.batches[len(server.batches)-1]
+	if len(last) != 2 || last[0].Action != "DELETE" || last[1].Action != "UPSERT" {
+		t.Fatalf("expected DELETE and UPSERT in one batch, got %+v", last)
+	}
+	id = rec.Status.RecordID
+	current, err := p.(provider.IDNSRecordGetter).GetRecord(ctx, id)
+	if err != nil || current == nil {
+		t.Fatalf("GetRecord: %+v %v", current, err)
+	}
+	if current.Name != "web.example.com" || current.Value != "192.168.1.1" || current.TTL != ttl {
+		t.Fatalf("unexpected record after update: %+v", current)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := p.(provider.IDNSRecordLister).ListRecords(ctx); err != nil || len(records) != 0 {
+		t.Fatalf("ListRecords after delete: %+v %v", records, err)
+	}
+}
+
+func TestRoute53ProviderAlias(t *testing.T) {
+	ctx := context.Background()
+	server := startServer(t)
+	p := newProvider(t, server, dnsv1.Route53ProviderConfig{})
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeCNAME,
+		Name:       "lb.example.com",
+		Value:      "my-lb-1234567890.us-east-1.elb.amazonaws.com",
+	}}
+	rec.Annotations = map[string]string{AnnotationKeyAlias: "true"}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "lb.example.com. A" {
+		t.Fatalf("unexpected id %q", id)
+	}
+	target := server.records["ZPUBLIC"][0].AliasTarget
+	if target == nil || target.HostedZoneId != "Z35SXDOTRQ7X7K" {
+		t.Fatalf("unexpected alias target %+v", target)
+	}
+
+	rec.Spec.Value = "my-nlb-0123456789abcdef.elb.example.amazonaws.com"
+	if err := p.UpdateRecord(ctx, rec, &id); err == nil {
+		t.Fatal("expected alias target of unknown region to fail")
+	}
+}
+
+func TestRoute53ProviderRouting(t *testing.T) {
+	ctx := context.Background()
+	server := startServer(t)
+	p := newProvider(t, server, dnsv1.Route53ProviderConfig{})
+
+	newRecord := func(setIdentifier, value string, weight int64) *dnsv1.DNSRecord {
+		return &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+			RecordType: dnsv1.DNSRecordTypeA,
+			Name:       "app.example.com",
+			Value:      value,
+			Routing:    &dnsv1.DNSRecordRouting{SetIdentifier: setIdentifier, Weight: &weight},
+		}}
+	}
+	blue, green := newRecord("blue", "192.168.1.1", 90), newRecord("green", "192.168.1.2", 10)
+	blueID, err := p.CreateRecord(ctx, blue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	greenID, err := p.CreateRecord(ctx, green)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blueID != "app.example.com. A blue" || greenID != "app.example.com. A green" {
+		t.Fatalf("unexpected ids %q %q", blueID, greenID)
+	}
+	if found, ok, err := p.SearchRecord(ctx, green); err != nil || !ok || found != greenID {
+		t.Fatalf("SearchRecord of the second record set: id=%q ok=%v err=%v", found, ok, err)
+	}
+
+	*green.Spec.Routing.Weight = 50
+	if err := p.UpdateRecord(ctx, green, &greenID); err != nil {
+		t.Fatal(err)
+	}
+	current, err := p.(provider.IDNSRecordGetter).GetRecord(ctx, greenID)
+	if err != nil || current == nil || current.Routing == nil || *current.Routing.Weight != 50 || current.Value != "192.168.1.2" {
+		t.Fatalf("unexpected record after update: %+v %v", current, err)
+	}
+	if blueRec, _ := p.(provider.IDNSRecordGetter).GetRecord(ctx, blueID); blueRec == nil || *blueRec.Routing.Weight != 90 {
+		t.Fatalf("the other record set is changed: %+v", blueRec)
+	}
+
+	if err := p.DeleteRecord(ctx, blue, &blueID); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := p.(provider.IDNSRecordLister).ListRecords(ctx); err != nil || len(records) != 1 || records[0].ID != greenID {
+		t.Fatalf("ListRecords after delete: %+v %v", records, err)
+	}
+
+	geo := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeA,
+		Name:       "geo.example.com",
+		Value:      "192.168.1.3",
+		Routing:    &dnsv1.DNSRecordRouting{SetIdentifier: "eu", Geo: "continent:EU", HealthCheckID: "hc-1"},
+	}}
+	geoID, err := p.CreateRecord(ctx, geo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := p.(provider.IDNSRecordGetter).GetRecord(ctx, geoID); current == nil || current.Routing.Geo != "continent:EU" || current.Routing.HealthCheckID != "hc-1" {
+		t.Fatalf("unexpected geo record %+v", current)
+	}
+
+	for _, routing := range []*dnsv1.DNSRecordRouting{
+		{Weight: green.Spec.Routing.Weight},
+		{SetIdentifier: "both", Weight: green.Spec.Routing.Weight, Failover: dnsv1.DNSRecordFailoverRolePrimary},
+		{SetIdentifier: "none"},
+	} {
+		invalid := newRecord("", "192.168.1.4", 0)
+		invalid.Spec.Routing = routing
+		if _, err := p.CreateRecord(ctx, invalid); err == nil {
+			t.Errorf("expected routing %+v to be rejected", routing)
+		}
+	}
+}
+
+func TestRoute53ProviderBatching(t *testing.T) {
+	ctx := context.Background()
+	server := startServer(t)
+	p := newProvider(t, server, dnsv1.Route53ProviderConfig{})
+	spec := &dnsv1.DNSProviderSpec{Batch: &dnsv1.DNSProviderBatchConfig{Window: 100}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			change := &provider.RecordChange{Action: provider.ChangeActionCreate, Record: &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+				RecordType: dnsv1.DNSRecordTypeA,
+				Name:       fmt.Sprintf("host%d.example.com", i),
+				Value:      "192.168.1.1",
+			}}}
+			if err := provider.ApplyChanges(ctx, "route53-batching", spec, p, change); err != nil {
+				t.Error(err)
+			}
+			if change.ID != fmt.Sprintf("host%d.example.com. A", i) {
+				t.Errorf("unexpected id %q", change.ID)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(server.batches) != 1 || len(server.batches[0]) != 5 {
+		t.Fatalf("expected one batch of 5 changes, got %+v", server.batches)
+	}
+
+	// an invalid change does not fail the other changes of the batch
+	server.batches = nil
+	invalid := &provider.RecordChange{Action: provider.ChangeActionUpdate, Record: &dnsv1.DNSRecord{}, ID: "invalid"}
+	valid := &provider.RecordChange{Action: provider.ChangeActionDelete, Record: &dnsv1.DNSRecord{}, ID: "host0.example.com. A"}
+	errs := make(chan error, 2)
+	for _, change := range []*provider.RecordChange{invalid, valid} {
+		go func(change *provider.RecordChange) {
+			errs <- provider.ApplyChanges(ctx, "route53-batching", spec, p, change)
+		}(change)
+	}
+	failed := 0
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			failed++
+		}
+	}
+	if failed != 1 || len(server.records["ZPUBLIC"]) != 4 {
+		t.Fatalf("expected only the invalid change to fail, failed=%d records=%+v", failed, server.records["ZPUBLIC"])
+	}
+}
+
+func TestRoute53ProviderBatchRateLimit(t *testing.T) {
+	ctx := context.Background()
+	server := startServer(t)
+	p := newProvider(t, server, dnsv1.Route53ProviderConfig{})
+	spec := &dnsv1.DNSProviderSpec{RateLimit: &dnsv1.DNSProviderRateLimit{Requests: 3, Period: 3600}}
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Name: "www.example.com", Value: "192.168.1.1"}}
+	create := &provider.RecordChange{Action: provider.ChangeActionCreate, Record: rec}
+	if err := provider.ApplyChanges(ctx, "route53-ratelimit", spec, p, create); err != nil {
+		t.Fatal(err)
+	}
+	if delay := provider.RateLimitDelay("route53-ratelimit", spec.RateLimit); delay != 0 {
+		t.Fatalf("expected the create to take one token, delay=%v", delay)
+	}
+	// the lookup of the deleted record set takes a token as well
+	if err := provider.ApplyChanges(ctx, "route53-ratelimit", spec, p, &provider.RecordChange{Action: provider.ChangeActionDelete, Record: rec, ID: create.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if delay := provider.RateLimitDelay("route53-ratelimit", spec.RateLimit); delay == 0 {
+		t.Fatal("expected the lookup and the delete to take a token each")
+	}
+	if len(server.records["ZPUBLIC"]) != 0 {
+		t.Fatalf("unexpected records %+v", server.records["ZPUBLIC"])
+	}
+}
@@ -0,0 +1,84 @@
+package webhook
+
+// ProtocolVersion is the version of the webhook provider protocol, returned by the capabilities endpoint
+const ProtocolVersion = "v1"
+
+// The endpoints of the webhook, relative to the base URL
+const (
+	// GET, returns Capabilities
+	PathCapabilities = "/capabilities"
+	// POST Request with Record, returns Response with ID and Found
+	PathSearch = "/search"
+	// POST Request with Record, returns Response with ID
+	PathCreate = "/create"
+	// POST Request with ID and Record, returns Response with the new ID if it's changed
+	PathUpdate = "/update"
+	// POST Request with ID and Record
+	PathDelete = "/delete"
+	// POST Request, returns Response with Records, only required if Capabilities.List is true
+	PathList = "/list"
+)
+
+// Capabilities is the features supported by the webhook
+type Capabilities struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	// The record types supported by the webhook, all types are supported if empty
+	RecordTypes []string `json:"recordTypes,omitempty"`
+	// Whether the list endpoint is implemented, which enables garbage collection and zone import
+	List bool `json:"list,omitempty"`
+	// The ttl range accepted by the webhook, unlimited if zero
+	MinTTL int `json:"minTTL,omitempty"`
+	MaxTTL int `json:"maxTTL,omitempty"`
+	// Whether the records of the same name and type with different values are kept side by side
+	MultiValue bool `json:"multiValue,omitempty"`
+	// The `dns.xzzpig.com/record-` annotations understood by the webhook, a trailing `*` matches any suffix,
+	// all of them are accepted if empty
+	Annotations []string `json:"annotations,omitempty"`
+	// The routing policies supported by the webhook, Weighted, Geo, Failover or HealthCheck,
+	// the records with a routing policy are rejected if empty
+	RoutingPolicies []string `json:"routingPolicies,omitempty"`
+}
+
+// Routing is the routing policy of a record, see the routing of DNSRecord
+type Routing struct {
+	SetIdentifier string `json:"setIdentifier,omitempty"`
+	Weight        *int64 `json:"weight,omitempty"`
+	Geo           string `json:"geo,omitempty"`
+	// Primary or Secondary
+	Failover      string `json:"failover,omitempty"`
+	HealthCheckID string `json:"healthCheckId,omitempty"`
+}
+
+// Record is a DNS record in the webhook protocol
+type Record struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	TTL   int    `json:"ttl,omitempty"`
+	// The ownership marker of the record, should be stored by the webhook and returned by list
+	Owner string `json:"owner,omitempty"`
+	// The `dns.xzzpig.com/record-` annotations of the DNSRecord
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// The routing policy of the record, nil for simple records
+	Routing *Routing `json:"routing,omitempty"`
+}
+
+// Request is the body of the POST endpoints
+type Request struct {
+	// The domain name of the DNSProvider
+	Zone string `json:"zone"`
+	// The settings of the DNSProvider
+	Settings map[string]string `json:"settings,omitempty"`
+	// The id of the record to update or delete
+	ID     string  `json:"id,omitempty"`
+	Record *Record `json:"record,omitempty"`
+}
+
+// Response is the body returned by the POST endpoints, the error should be set with a non-2xx status
+type Response struct {
+	ID      string   `json:"id,omitempty"`
+	Found   bool     `json:"found,omitempty"`
+	Records []Record `json:"records,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
@@ -0,0 +1,287 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/generator"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+)
+
+// WebhookProvider delegates the records to an out-of-tree webhook speaking the protocol in protocol.go.
+// The record id is chosen by the webhook.
+type WebhookProvider struct {
+	spec         *dnsv1.DNSProviderSpec
+	client       *http.Client
+	url          string
+	token        string
+	capabilities *Capabilities
+}
+
+// listingWebhookProvider is a webhook provider which implements the list endpoint
+type listingWebhookProvider struct {
+	*WebhookProvider
+}
+
+func (p *WebhookProvider) do(ctx context.Context, method string, path string, body interface{}, resp interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	httpResp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= 300 {
+		var respErr Response
+		if err := json.NewDecoder(httpResp.Body).Decode(&respErr); err != nil || respErr.Error == "" {
+			return fmt.Errorf("webhook %s: %s", path, httpResp.Status)
+		}
+		return fmt.Errorf("webhook %s: %s: %s", path, httpResp.Status, respErr.Error)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func (p *WebhookProvider) call(ctx context.Context, path string, id string, rec *Record) (*Response, error) {
+	var resp Response
+	req := &Request{
+		Zone:     p.spec.DomainName,
+		Settings: p.spec.Webhook.Settings,
+		ID:       id,
+		Record:   rec,
+	}
+	if err := p.do(ctx, http.MethodPost, path, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// newRecord builds the record described by the DNSRecord
+func newRecord(rec *dnsv1.DNSRecord) *Record {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	r := &Record{
+		Name:  rec.Spec.Name,
+		Type:  string(rec.Spec.RecordType),
+		Value: rec.Spec.Value,
+		TTL:   ttl,
+		Owner: provider.OwnerMarker(rec),
+	}
+	if routing := rec.Spec.Routing; routing != nil {
+		r.Routing = &Routing{
+			SetIdentifier: routing.SetIdentifier,
+			Weight:        routing.Weight,
+			Geo:           routing.Geo,
+			Failover:      string(routing.Failover),
+			HealthCheckID: routing.HealthCheckID,
+		}
+	}
+	// only the record annotations are passed, the webhook may use them as provider specific attributes
+	for k, v := range rec.Annotations {
+		if strings.HasPrefix(k, generator.AnnotationKeyRecordPrefix) {
+			if r.Annotations == nil {
+				r.Annotations = map[string]string{}
+			}
+			r.Annotations[k] = v
+		}
+	}
+	return r
+}
+
+// dnsRecordRouting converts the routing to the routing of DNSRecord
+func (r *Routing) dnsRecordRouting() *dnsv1.DNSRecordRouting {
+	if r == nil {
+		return nil
+	}
+	return &dnsv1.DNSRecordRouting{
+		SetIdentifier: r.SetIdentifier,
+		Weight:        r.Weight,
+		Geo:           r.Geo,
+		Failover:      dnsv1.DNSRecordFailoverRole(r.Failover),
+		HealthCheckID: r.HealthCheckID,
+	}
+}
+
+func (p *WebhookProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	capabilities := dnsv1.DNSProviderCapabilities{
+		MinTTL:      p.capabilities.MinTTL,
+		MaxTTL:      p.capabilities.MaxTTL,
+		MultiValue:  p.capabilities.MultiValue,
+		Ownership:   p.capabilities.List,
+		Annotations: p.capabilities.Annotations,
+	}
+	for _, t := range p.capabilities.RecordTypes {
+		capabilities.RecordTypes = append(capabilities.RecordTypes, dnsv1.DNSRecordType(t))
+	}
+	for _, policy := range p.capabilities.RoutingPolicies {
+		capabilities.RoutingPolicies = append(capabilities.RoutingPolicies, dnsv1.DNSRoutingPolicy(policy))
+	}
+	if len(capabilities.Annotations) == 0 {
+		capabilities.Annotations = []string{generator.AnnotationKeyRecordPrefix + "*"}
+	}
+	return capabilities
+}
+
+func (p *WebhookProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	resp, err := p.call(ctx, PathSearch, "", newRecord(rec))
+	if err != nil || !resp.Found {
+		return "", false, err
+	}
+	if resp.ID == "" {
+		return "", false, errors.New("webhook search: no id returned for the found record")
+	}
+	rec.Status.RecordID = resp.ID
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *WebhookProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	resp, err := p.call(ctx, PathCreate, "", newRecord(rec))
+	if err != nil {
+		return "", err
+	}
+	if resp.ID == "" {
+		return "", errors.New("webhook create: no id returned for the created record")
+	}
+	return resp.ID, nil
+}
+
+func (p *WebhookProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	resp, err := p.call(ctx, PathUpdate, *id, newRecord(rec))
+	if err != nil {
+		return err
+	}
+	if resp.ID != "" {
+		rec.Status.RecordID = resp.ID
+	}
+	return nil
+}
+
+func (p *WebhookProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	_, err = p.call(ctx, PathDelete, *id, newRecord(rec))
+	return err
+}
+
+func (p *listingWebhookProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	resp, err := p.call(ctx, PathList, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(resp.Records))
+	for _, r := range resp.Records {
+		records = append(records, provider.ProviderRecord{
+			ID:          r.ID,
+			Name:        r.Name,
+			RecordType:  dnsv1.DNSRecordType(r.Type),
+			Value:       r.Value,
+			TTL:         r.TTL,
+			Owner:       provider.ParseOwnerMarker(r.Owner),
+			Annotations: r.Annotations,
+			Routing:     r.Routing.dnsRecordRouting(),
+		})
+	}
+	return records, nil
+}
+
+func (p *listingWebhookProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	records, err := p.ListRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if records[i].ID == id {
+			return &records[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeWebhook), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		cfg := &spec.Webhook
+		if cfg.URL == "" {
+			return nil, errors.New("webhook url is required")
+		}
+		token, err := args.SecretValue(cfg.TokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		timeout := time.Duration(cfg.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		var tlsData []byte
+		if cfg.TLSSecretRef != nil {
+			tlsCfg, data, err := args.TLSConfig(cfg.TLSSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsCfg
+			tlsData = data
+		}
+
+		p := &WebhookProvider{
+			spec:  spec,
+			url:   strings.TrimSuffix(cfg.URL, "/"),
+			token: strings.TrimSpace(token),
+		}
+		p.client, err = provider.CachedClient(provider.LookupKey("Webhook/client", p.url, timeout, tlsData), func() (*http.Client, error) {
+			return &http.Client{Timeout: timeout, Transport: transport}, nil
+		}, (*http.Client).CloseIdleConnections)
+		if err != nil {
+			return nil, err
+		}
+
+		// the capabilities are negotiated again once per zone sync interval
+		p.capabilities, err = provider.CachedLookup(provider.LookupKey("Webhook/capabilities", p.url, p.token, timeout, tlsData), spec.ZoneSyncDuration(), func() (*Capabilities, error) {
+			var capabilities Capabilities
+			if err := p.do(args.Ctx, http.MethodGet, PathCapabilities, nil, &capabilities); err != nil {
+				return nil, fmt.Errorf("unable to negotiate capabilities: %w", err)
+			}
+			if capabilities.ProtocolVersion != ProtocolVersion {
+				return nil, fmt.Errorf("unsupported webhook protocol version %q, %s is required", capabilities.ProtocolVersion, ProtocolVersion)
+			}
+			return &capabilities, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if p.capabilities.List {
+			return &listingWebhookProvider{p}, nil
+		}
+		return p, nil
+	})
+}
@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testServer is a minimal webhook keeping the records in memory
+type testServer struct {
+	mu       sync.Mutex
+	records  map[string]Record
+	nextID   int
+	settings map[string]string
+	// the count of the capabilities requests
+	negotiations int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(Response{Error: "invalid token"})
+		return
+	}
+	if r.URL.Path == PathCapabilities {
+		s.negotiations++
+		json.NewEncoder(w).Encode(Capabilities{ProtocolVersion: ProtocolVersion, RecordTypes: []string{"A", "TXT"}, List: true})
+		return
+	}
+	var req Request
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Zone != "example.com" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{Error: "unknown zone " + req.Zone})
+		return
+	}
+	s.settings = req.Settings
+
+	var resp Response
+	switch r.URL.Path {
+	case PathSearch:
+		for id, rec := range s.records {
+			if rec.Name == req.Record.Name && rec.Type == req.Record.Type {
+				resp.ID, resp.Found = id, true
+			}
+		}
+	case PathCreate:
+		s.nextID++
+		resp.ID = strconv.Itoa(s.nextID)
+		s.records[resp.ID] = *req.Record
+	case PathUpdate:
+		if _, ok := s.records[req.ID]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(Response{Error: "record " + req.ID + " not found"})
+			return
+		}
+		s.records[req.ID] = *req.Record
+	case PathDelete:
+		delete(s.records, req.ID)
+	case PathList:
+		for id, rec := range s.records {
+			rec.ID = id
+			resp.Records = append(resp.Records, rec)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestWebhookProvider(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{records: map[string]Record{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "webhook"},
+		Data:       map[string][]byte{"token": []byte("test-token\n")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeWebhook,
+		Webhook: dnsv1.WebhookProviderConfig{
+			URL:            httpServer.URL + "/",
+			TokenSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "webhook", Key: "token"},
+			Settings:       map[string]string{"view": "internal"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.CheckRecord(p, &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeCNAME}}); err == nil {
+		t.Fatal("expected CNAME to be rejected by the capabilities")
+	}
+	weighted := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{RecordType: dnsv1.DNSRecordTypeA, Routing: &dnsv1.DNSRecordRouting{Weight: new(int64)}}}
+	if err := provider.CheckRecord(p, weighted); err == nil {
+		t.Fatal("expected the routing policy to be rejected by the capabilities")
+	}
+
+	rec := &dnsv1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "www",
+			Annotations: map[string]string{"dns.xzzpig.com/record-weight": "10", "other": "ignored"},
+		},
+		Spec: dnsv1.DNSRecordSpec{
+			RecordType: dnsv1.DNSRecordTypeA,
+			Name:       "www.example.com",
+			Value:      "10.0.0.1",
+		},
+	}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := server.records[id]
+	if stored.Owner != provider.OwnerMarker(rec) || stored.TTL == 0 || len(stored.Annotations) != 1 || server.settings["view"] != "internal" {
+		t.Fatalf("unexpected stored record %+v, settings %v", stored, server.settings)
+	}
+	if found, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok || found != id {
+		t.Fatalf("SearchRecord after create: id=%q ok=%v err=%v", found, ok, err)
+	}
+
+	rec.Spec.Value = "10.0.0.2"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if server.records[id].Value != "10.0.0.2" {
+		t.Fatalf("unexpected records %+v", server.records)
+	}
+
+	getter, ok := p.(provider.IDNSRecordGetter)
+	if !ok {
+		t.Fatal("expected the provider to implement IDNSRecordGetter")
+	}
+	got, err := getter.GetRecord(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Value != "10.0.0.2" || !got.Owner.IsLocal() || got.Owner.Name != "www" {
+		t.Fatalf("unexpected record %+v", got)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.records) != 0 {
+		t.Fatalf("unexpected records after delete %+v", server.records)
+	}
+	if err := p.UpdateRecord(ctx, rec, &id); err == nil || err.Error() != "webhook /update: 404 Not Found: record 1 not found" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestWebhookProviderCache(t *testing.T) {
+	ctx := context.Background()
+	server := &testServer{records: map[string]Record{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "webhook"},
+		Data:       map[string][]byte{"token": []byte("test-token")},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	newProvider := func(timeout int64) *WebhookProvider {
+		p, err := provider.New(ctx, reader, &dnsv1.DNSProviderSpec{
+			DomainName:   "example.com",
+			ProviderType: dnsv1.DNSProviderTypeWebhook,
+			Webhook: dnsv1.WebhookProviderConfig{
+				URL:            httpServer.URL,
+				TokenSecretRef: &dnsv1.SecretKeySelector{Namespace: "default", Name: "webhook", Key: "token"},
+				Timeout:        timeout,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.(*listingWebhookProvider).WebhookProvider
+	}
+
+	p1, p2 := newProvider(10), newProvider(10)
+	if p1.client != p2.client || server.negotiations != 1 {
+		t.Fatalf("expected the client and capabilities to be shared, negotiations=%d", server.negotiations)
+	}
+	// another config of the same url gets its own client, the client of the other config stays usable
+	p3 := newProvider(20)
+	if p3.client == p1.client || server.negotiations != 2 {
+		t.Fatalf("expected another client for another config, negotiations=%d", server.negotiations)
+	}
+	if p4 := newProvider(10); p4.client != p1.client || server.negotiations != 2 {
+		t.Fatalf("expected the client of the first config to be kept, negotiations=%d", server.negotiations)
+	}
+}
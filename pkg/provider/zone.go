@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ZoneListFunc lists every record of a zone, keyed by the provider record id.
+type ZoneListFunc[T any] func(ctx context.Context) (map[string]T, error)
+
+// ZoneSnapshot is an in-memory copy of the records of a zone.
+// It is shared by all reconciles using the same provider, so the zone is listed
+// at most once per sync interval. Writes should be reported with Put/Remove to keep
+// the snapshot current without listing the zone again.
+type ZoneSnapshot[T any] struct {
+	mu       sync.Mutex
+	interval time.Duration
+	list     ZoneListFunc[T]
+	records  map[string]T
+	syncedAt time.Time
+}
+
+var (
+	zoneSnapshots   = map[string]any{}
+	zoneSnapshotsMu sync.Mutex
+)
+
+// GetZoneSnapshot returns the snapshot registered under key, creating it if necessary.
+// The interval and list function of an existing snapshot are replaced by the given ones,
+// as they may come from a newer version of the DNSProvider spec.
+func GetZoneSnapshot[T any](key string, interval time.Duration, list ZoneListFunc[T]) *ZoneSnapshot[T] {
+	zoneSnapshotsMu.Lock()
+	defer zoneSnapshotsMu.Unlock()
+
+	snapshot, ok := zoneSnapshots[key].(*ZoneSnapshot[T])
+	if !ok {
+		snapshot = &ZoneSnapshot[T]{}
+		zoneSnapshots[key] = snapshot
+	}
+	snapshot.mu.Lock()
+	snapshot.interval = interval
+	snapshot.list = list
+	snapshot.mu.Unlock()
+	return snapshot
+}
+
+func (s *ZoneSnapshot[T]) synced() bool {
+	return s.records != nil && time.Since(s.syncedAt) < s.interval
+}
+
+func (s *ZoneSnapshot[T]) sync(ctx context.Context) error {
+	if s.synced() {
+		return nil
+	}
+	records, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+	s.records = records
+	s.syncedAt = time.Now()
+	return nil
+}
+
+// Synced reports whether the zone is listed within the sync interval, so the lookups do not list it again.
+func (s *ZoneSnapshot[T]) Synced() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.synced()
+}
+
+// Get returns the record with the given id.
+func (s *ZoneSnapshot[T]) Get(ctx context.Context, id string) (record T, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = s.sync(ctx); err != nil {
+		return
+	}
+	record, ok = s.records[id]
+	return
+}
+
+// Find returns the record with the lowest id accepted by match,
+// so the same record is found every time if several records of a name are accepted.
+func (s *ZoneSnapshot[T]) Find(ctx context.Context, match func(id string, record T) bool) (id string, record T, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = s.sync(ctx); err != nil {
+		return
+	}
+	ids := make([]string, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if record := s.records[id]; match(id, record) {
+			return id, record, true, nil
+		}
+	}
+	return
+}
+
+// List returns all records of the zone.
+func (s *ZoneSnapshot[T]) List(ctx context.Context) (records map[string]T, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = s.sync(ctx); err != nil {
+		return
+	}
+	records = make(map[string]T, len(s.records))
+	for id, record := range s.records {
+		records[id] = record
+	}
+	return
+}
+
+// Put adds or replaces a record after it was written to the provider.
+func (s *ZoneSnapshot[T]) Put(id string, record T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.records != nil {
+		s.records[id] = record
+	}
+}
+
+// Remove drops a record after it was deleted from the provider.
+func (s *ZoneSnapshot[T]) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, id)
+}
+
+// Invalidate forces the next lookup to list the zone again.
+func (s *ZoneSnapshot[T]) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = nil
+}
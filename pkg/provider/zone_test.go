@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingList returns a list function of the records, which counts its calls
+func countingList(records map[string]string, calls *int32) ZoneListFunc[string] {
+	return func(ctx context.Context) (map[string]string, error) {
+		atomic.AddInt32(calls, 1)
+		zone := make(map[string]string, len(records))
+		for id, record := range records {
+			zone[id] = record
+		}
+		return zone, nil
+	}
+}
+
+func TestZoneSnapshotSync(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	records := map[string]string{"1": "a.example.com", "2": "b.example.com"}
+	snapshot := GetZoneSnapshot(t.Name(), time.Hour, countingList(records, &calls))
+
+	if record, ok, err := snapshot.Get(ctx, "1"); err != nil || !ok || record != "a.example.com" {
+		t.Fatalf("Get: %s %v %v", record, ok, err)
+	}
+	if zone, err := snapshot.List(ctx); err != nil || len(zone) != 2 {
+		t.Fatalf("List: %v %v", zone, err)
+	}
+	if calls != 1 {
+		t.Fatalf("the zone is listed %d times within the interval", calls)
+	}
+
+	// the writes are reported without listing the zone again
+	snapshot.Put("3", "c.example.com")
+	snapshot.Remove("1")
+	zone, err := snapshot.List(ctx)
+	if err != nil || len(zone) != 2 || zone["3"] != "c.example.com" || zone["1"] != "" {
+		t.Fatalf("List after writes: %v %v", zone, err)
+	}
+	// the returned map is a copy
+	delete(zone, "3")
+	if _, ok, _ := snapshot.Get(ctx, "3"); !ok || calls != 1 {
+		t.Fatalf("the snapshot is changed by its copy, calls %d", calls)
+	}
+
+	// a failed write invalidates the snapshot, which is listed again
+	snapshot.Invalidate()
+	if _, ok, err := snapshot.Get(ctx, "3"); err != nil || ok || calls != 2 {
+		t.Fatalf("Get after Invalidate: ok=%v err=%v calls=%d", ok, err, calls)
+	}
+	// Put is ignored until the zone is listed, so a partial snapshot is never served
+	snapshot.Invalidate()
+	snapshot.Put("4", "d.example.com")
+	if _, ok, err := snapshot.Get(ctx, "4"); err != nil || ok || calls != 3 {
+		t.Fatalf("Get after Put on invalidated snapshot: ok=%v err=%v calls=%d", ok, err, calls)
+	}
+
+	// the snapshot expires after the interval of the latest spec
+	GetZoneSnapshot(t.Name(), time.Nanosecond, countingList(records, &calls))
+	time.Sleep(time.Millisecond)
+	if _, _, err := snapshot.Get(ctx, "1"); err != nil || calls != 4 {
+		t.Fatalf("Get after expiry: err=%v calls=%d", err, calls)
+	}
+}
+
+func TestZoneSnapshotListError(t *testing.T) {
+	ctx := context.Background()
+	fail := true
+	snapshot := GetZoneSnapshot(t.Name(), time.Hour, func(ctx context.Context) (map[string]string, error) {
+		if fail {
+			return nil, errors.New("list failed")
+		}
+		return map[string]string{"1": "a.example.com"}, nil
+	})
+	if _, _, err := snapshot.Get(ctx, "1"); err == nil {
+		t.Fatal("expected the error of the list")
+	}
+	// the failed list is not cached
+	fail = false
+	if _, ok, err := snapshot.Get(ctx, "1"); err != nil || !ok {
+		t.Fatalf("Get after recovery: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestZoneSnapshotFind(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	records := map[string]string{}
+	for i := 0; i < 50; i++ {
+		records[strconv.Itoa(100+i)] = "www.example.com"
+	}
+	records["099"] = "api.example.com"
+	snapshot := GetZoneSnapshot(t.Name(), time.Hour, countingList(records, &calls))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the records of the same name are found in a stable order
+			id, _, ok, err := snapshot.Find(ctx, func(id string, record string) bool { return record == "www.example.com" })
+			if err != nil || !ok || id != "100" {
+				t.Errorf("Find: %s %v %v", id, ok, err)
+			}
+			snapshot.Put("200", "www.example.com")
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("the zone is listed %d times by concurrent lookups", calls)
+	}
+	if _, _, ok, err := snapshot.Find(ctx, func(id string, record string) bool { return record == "mail.example.com" }); err != nil || ok {
+		t.Fatalf("Find of a missing record: ok=%v err=%v", ok, err)
+	}
+}
@@ -0,0 +1,418 @@
+package zonefile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/config"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// entry is a resource record of the file, with the comment following it on the same line
+type entry struct {
+	rr      dns.RR
+	comment string
+}
+
+func (e *entry) id() string {
+	return recordID(e.rr.Header().Name, e.rr.Header().Rrtype)
+}
+
+// file is the parsed content of the zone file or hosts file
+type file struct {
+	// the SOA record, always nil for hosts files
+	soa     *dns.SOA
+	entries []entry
+}
+
+// remove removes all entries of the record id, returns whether any entry is removed
+func (f *file) remove(id string) bool {
+	entries := f.entries[:0]
+	for _, e := range f.entries {
+		if e.id() != id {
+			entries = append(entries, e)
+		}
+	}
+	removed := len(entries) != len(f.entries)
+	f.entries = entries
+	return removed
+}
+
+func (f *file) find(id string) []entry {
+	var found []entry
+	for _, e := range f.entries {
+		if e.id() == id {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// ZoneFileProvider renders the records into a zone file or hosts file stored in a ConfigMap,
+// which can be mounted by BIND, dnsmasq or CoreDNS.
+// The record id is the owner name and type of the RRset, e.g. `www.example.com. A`.
+type ZoneFileProvider struct {
+	spec       *dnsv1.DNSProviderSpec
+	client     client.Client
+	configMap  types.NamespacedName
+	key        string
+	format     dnsv1.ZoneFileFormat
+	origin     string
+	nameserver string
+	hostmaster string
+}
+
+func recordID(name string, rrtype uint16) string {
+	return strings.ToLower(dns.Fqdn(name)) + " " + dns.TypeToString[rrtype]
+}
+
+// newRR builds the resource record described by the DNSRecord
+func (p *ZoneFileProvider) newRR(rec *dnsv1.DNSRecord) (dns.RR, error) {
+	ttl := config.GetConfig().Default.Record.TTL
+	if rec.Spec.TTL != nil {
+		ttl = *rec.Spec.TTL
+	}
+	if p.format == dnsv1.ZoneFileFormatHosts {
+		if rec.Spec.RecordType != dnsv1.DNSRecordTypeA && rec.Spec.RecordType != dnsv1.DNSRecordTypeAAAA {
+			return nil, fmt.Errorf("record type %s is not supported by hosts file", rec.Spec.RecordType)
+		}
+		// hosts files have no ttl
+		ttl = 0
+	}
+	value := rec.Spec.Value
+	if rec.Spec.RecordType == dnsv1.DNSRecordTypeTXT && !strings.HasPrefix(value, `"`) {
+		value = strconv.Quote(value)
+	}
+	return dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(rec.Spec.Name), ttl, rec.Spec.RecordType, value))
+}
+
+func (p *ZoneFileProvider) parse(data string) (*file, error) {
+	if p.format == dnsv1.ZoneFileFormatHosts {
+		return parseHosts(data)
+	}
+	return parseZone(data, p.origin)
+}
+
+func parseZone(data string, origin string) (*file, error) {
+	f := &file{}
+	zp := dns.NewZoneParser(strings.NewReader(data), origin, "")
+	zp.SetIncludeAllowed(false)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if soa, isSOA := rr.(*dns.SOA); isSOA {
+			f.soa = soa
+			continue
+		}
+		comment := strings.TrimSpace(strings.TrimPrefix(zp.Comment(), ";"))
+		f.entries = append(f.entries, entry{rr: rr, comment: comment})
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("invalid zone file: %w", err)
+	}
+	return f, nil
+}
+
+func parseHosts(data string) (*file, error) {
+	f := &file{}
+	for _, line := range strings.Split(data, "\n") {
+		line, comment, _ := strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		rrtype := "A"
+		if strings.Contains(fields[0], ":") {
+			rrtype = "AAAA"
+		}
+		for _, name := range fields[1:] {
+			rr, err := dns.NewRR(fmt.Sprintf("%s 0 IN %s %s", dns.Fqdn(name), rrtype, fields[0]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid hosts file line %q: %w", line, err)
+			}
+			f.entries = append(f.entries, entry{rr: rr, comment: strings.TrimSpace(comment)})
+		}
+	}
+	return f, nil
+}
+
+func (p *ZoneFileProvider) render(f *file) string {
+	sort.SliceStable(f.entries, func(i, j int) bool {
+		return f.entries[i].id() < f.entries[j].id()
+	})
+	var sb strings.Builder
+	if p.format == dnsv1.ZoneFileFormatHosts {
+		for _, e := range f.entries {
+			sb.WriteString(rdata(e.rr) + " " + strings.TrimSuffix(e.rr.Header().Name, "."))
+			if e.comment != "" {
+				sb.WriteString(" # " + e.comment)
+			}
+			sb.WriteString("\n")
+		}
+		return sb.String()
+	}
+	sb.WriteString("$ORIGIN " + p.origin + "\n")
+	sb.WriteString(f.soa.String() + "\n")
+	for _, e := range f.entries {
+		sb.WriteString(e.rr.String())
+		if e.comment != "" {
+			sb.WriteString(" ; " + e.comment)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// bumpSerial increases the SOA serial in the form of `YYYYMMDDnn`, a new SOA record is added if not exists
+func (p *ZoneFileProvider) bumpSerial(f *file) {
+	serial := uint32(0)
+	if f.soa == nil {
+		f.soa = &dns.SOA{
+			Hdr:     dns.RR_Header{Name: p.origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+			Ns:      p.nameserver,
+			Mbox:    p.hostmaster,
+			Refresh: 3600,
+			Retry:   600,
+			Expire:  86400,
+			Minttl:  60,
+		}
+		// a zone requires at least one nameserver at the apex
+		if len(f.find(recordID(p.origin, dns.TypeNS))) == 0 {
+			f.entries = append(f.entries, entry{rr: &dns.NS{
+				Hdr: dns.RR_Header{Name: p.origin, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 3600},
+				Ns:  p.nameserver,
+			}})
+		}
+	} else {
+		serial = f.soa.Serial + 1
+	}
+	if today := dateSerial(time.Now()); serial < today {
+		serial = today
+	}
+	f.soa.Serial = serial
+}
+
+func dateSerial(t time.Time) uint32 {
+	t = t.UTC()
+	return uint32(t.Year()*1000000 + int(t.Month())*10000 + t.Day()*100)
+}
+
+// load reads and parses the file, an empty ConfigMap is returned if not exists
+func (p *ZoneFileProvider) load(ctx context.Context) (*corev1.ConfigMap, *file, error) {
+	var cm corev1.ConfigMap
+	if err := p.client.Get(ctx, p.configMap, &cm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, err
+		}
+		cm.Namespace = p.configMap.Namespace
+		cm.Name = p.configMap.Name
+	}
+	f, err := p.parse(cm.Data[p.key])
+	if err != nil {
+		return nil, nil, fmt.Errorf("configmap %s key %s: %w", p.configMap, p.key, err)
+	}
+	return &cm, f, nil
+}
+
+// isConflict reports whether the ConfigMap is modified or created by a concurrent writer
+func isConflict(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
+
+// modify applies the change to the file and saves it if changed, retrying on conflicts with concurrent writers
+func (p *ZoneFileProvider) modify(ctx context.Context, change func(f *file) (changed bool, err error)) error {
+	return retry.OnError(retry.DefaultBackoff, isConflict, func() error {
+		cm, f, err := p.load(ctx)
+		if err != nil {
+			return err
+		}
+		changed, err := change(f)
+		if err != nil || !changed {
+			return err
+		}
+		if p.format == dnsv1.ZoneFileFormatZone {
+			p.bumpSerial(f)
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[p.key] = p.render(f)
+		if cm.ResourceVersion == "" {
+			return p.client.Create(ctx, cm)
+		}
+		return p.client.Update(ctx, cm)
+	})
+}
+
+func (p *ZoneFileProvider) Capabilities() dnsv1.DNSProviderCapabilities {
+	capabilities := dnsv1.DNSProviderCapabilities{MaxTTL: math.MaxInt32, Ownership: true}
+	if p.format == dnsv1.ZoneFileFormatHosts {
+		// hosts files have no ttl, so any ttl is accepted and ignored
+		capabilities.RecordTypes = []dnsv1.DNSRecordType{dnsv1.DNSRecordTypeA, dnsv1.DNSRecordTypeAAAA}
+		capabilities.MaxTTL = 0 // unlimited
+	}
+	return capabilities
+}
+
+func (p *ZoneFileProvider) SearchRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, ok bool, err error) {
+	_, f, err := p.load(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	id = recordID(rec.Spec.Name, dns.StringToType[string(rec.Spec.RecordType)])
+	if len(f.find(id)) == 0 {
+		return "", false, nil
+	}
+	rec.Status.RecordID = id
+	return rec.Status.RecordID, true, nil
+}
+
+func (p *ZoneFileProvider) CreateRecord(ctx context.Context, rec *dnsv1.DNSRecord) (id string, err error) {
+	rr, err := p.newRR(rec)
+	if err != nil {
+		return "", err
+	}
+	e := entry{rr: rr, comment: provider.OwnerMarker(rec)}
+	err = p.modify(ctx, func(f *file) (bool, error) {
+		f.remove(e.id())
+		f.entries = append(f.entries, e)
+		return true, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return e.id(), nil
+}
+
+func (p *ZoneFileProvider) UpdateRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	rr, err := p.newRR(rec)
+	if err != nil {
+		return err
+	}
+	desired := entry{rr: rr, comment: provider.OwnerMarker(rec)}
+	newID := desired.id()
+	err = p.modify(ctx, func(f *file) (bool, error) {
+		if current := f.find(*id); newID == *id && len(current) == 1 &&
+			dns.IsDuplicate(current[0].rr, desired.rr) && current[0].rr.Header().Ttl == desired.rr.Header().Ttl &&
+			current[0].comment == desired.comment {
+			return false, nil
+		}
+		// the name or type may have changed, the old RRset is removed in the same write
+		f.remove(*id)
+		f.remove(newID)
+		f.entries = append(f.entries, desired)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	rec.Status.RecordID = newID
+	return nil
+}
+
+func (p *ZoneFileProvider) DeleteRecord(ctx context.Context, rec *dnsv1.DNSRecord, id *string) (err error) {
+	return p.modify(ctx, func(f *file) (bool, error) {
+		return f.remove(*id), nil
+	})
+}
+
+func (p *ZoneFileProvider) GetRecord(ctx context.Context, id string) (*provider.ProviderRecord, error) {
+	_, f, err := p.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := f.find(id)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	rec := providerRecord(&found[0])
+	return &rec, nil
+}
+
+func (p *ZoneFileProvider) ListRecords(ctx context.Context) ([]provider.ProviderRecord, error) {
+	_, f, err := p.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]provider.ProviderRecord, 0, len(f.entries))
+	for i := range f.entries {
+		records = append(records, providerRecord(&f.entries[i]))
+	}
+	return records, nil
+}
+
+// rdata returns the rdata part of the presentation format
+func rdata(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+func providerRecord(e *entry) provider.ProviderRecord {
+	hdr := e.rr.Header()
+	value := rdata(e.rr)
+	if hdr.Rrtype == dns.TypeTXT {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+	} else {
+		value = strings.TrimSuffix(value, ".")
+	}
+	return provider.ProviderRecord{
+		ID:         e.id(),
+		Name:       strings.TrimSuffix(hdr.Name, "."),
+		RecordType: dnsv1.DNSRecordType(dns.TypeToString[hdr.Rrtype]),
+		Value:      value,
+		TTL:        int(hdr.Ttl),
+		Owner:      provider.ParseOwnerMarker(e.comment),
+	}
+}
+
+func init() {
+	provider.Register(string(dnsv1.DNSProviderTypeZoneFile), func(args *provider.DNSProviderFactoryArgs) (provider.IDNSProvider, error) {
+		spec := args.Spec
+		cfg := &spec.ZoneFile
+		if cfg.ConfigMapRef.Namespace == "" || cfg.ConfigMapRef.Name == "" {
+			return nil, errors.New("zonefile configMapRef is required")
+		}
+		if args.Client == nil {
+			return nil, errors.New("zonefile provider requires a client")
+		}
+		p := &ZoneFileProvider{
+			spec:       spec,
+			client:     args.Client,
+			configMap:  types.NamespacedName{Namespace: cfg.ConfigMapRef.Namespace, Name: cfg.ConfigMapRef.Name},
+			key:        cfg.Key,
+			format:     cfg.Format,
+			origin:     dns.Fqdn(spec.DomainName),
+			nameserver: cfg.Nameserver,
+			hostmaster: cfg.Hostmaster,
+		}
+		if p.format == "" {
+			p.format = dnsv1.ZoneFileFormatZone
+		}
+		if p.key == "" {
+			p.key = spec.DomainName + ".zone"
+			if p.format == dnsv1.ZoneFileFormatHosts {
+				p.key = "hosts"
+			}
+		}
+		if p.nameserver == "" {
+			p.nameserver = "ns." + p.origin
+		}
+		if p.hostmaster == "" {
+			p.hostmaster = "hostmaster." + p.origin
+		}
+		p.nameserver, p.hostmaster = dns.Fqdn(p.nameserver), dns.Fqdn(p.hostmaster)
+		return p, nil
+	})
+}
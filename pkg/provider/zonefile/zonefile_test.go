@@ -0,0 +1,154 @@
+package zonefile
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	dnsv1 "github.com/xzzpig/k8s-dns-manager/api/dns/v1"
+	"github.com/xzzpig/k8s-dns-manager/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newProvider(t *testing.T, format dnsv1.ZoneFileFormat) (provider.IDNSProvider, client.Client) {
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	p, err := provider.New(context.Background(), c, &dnsv1.DNSProviderSpec{
+		DomainName:   "example.com",
+		ProviderType: dnsv1.DNSProviderTypeZoneFile,
+		ZoneFile: dnsv1.ZoneFileProviderConfig{
+			ConfigMapRef: dnsv1.ObjectReference{Namespace: "default", Name: "zone"},
+			Format:       format,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, c
+}
+
+func readFile(t *testing.T, c client.Client, key string) string {
+	var cm corev1.ConfigMap
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "zone"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	return cm.Data[key]
+}
+
+func serial(t *testing.T, data string) uint32 {
+	f, err := parseZone(data, "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.soa == nil {
+		t.Fatalf("no SOA record in zone file:\n%s", data)
+	}
+	return f.soa.Serial
+}
+
+func TestZoneFileProvider(t *testing.T) {
+	ctx := context.Background()
+	p, c := newProvider(t, "")
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeTXT,
+		Name:       "www.example.com",
+		Value:      "hello world",
+	}}
+	rec.Namespace = "default"
+	rec.Name = "www"
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || ok {
+		t.Fatalf("SearchRecord before create: ok=%v err=%v", ok, err)
+	}
+	id, err := p.CreateRecord(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "www.example.com. TXT" {
+		t.Fatalf("unexpected id %q", id)
+	}
+	data := readFile(t, c, "example.com.zone")
+	if !strings.Contains(data, `"hello world" ; `+provider.OwnerMarker(rec)) || !strings.Contains(data, "\tNS\tns.example.com.") {
+		t.Fatalf("unexpected zone file:\n%s", data)
+	}
+	first := serial(t, data)
+	if first < dateSerial(time.Now()) {
+		t.Fatalf("unexpected serial %d", first)
+	}
+
+	// unchanged records do not bump the serial
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if serial(t, readFile(t, c, "example.com.zone")) != first {
+		t.Fatal("serial is bumped without change")
+	}
+
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeCNAME
+	rec.Spec.Value = "web.example.com"
+	if err := p.UpdateRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	id = rec.Status.RecordID
+	if serial(t, readFile(t, c, "example.com.zone")) != first+1 {
+		t.Fatal("serial is not bumped on change")
+	}
+	records, err := p.(provider.IDNSRecordLister).ListRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].ID != "www.example.com. CNAME" || records[1].Value != "web.example.com" || !records[1].Owner.IsLocal() {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err := p.DeleteRecord(ctx, rec, &id); err != nil {
+		t.Fatal(err)
+	}
+	if record, err := p.(provider.IDNSRecordGetter).GetRecord(ctx, id); err != nil || record != nil {
+		t.Fatalf("GetRecord after delete: %+v %v", record, err)
+	}
+}
+
+func TestZoneFileProviderHosts(t *testing.T) {
+	ctx := context.Background()
+	p, c := newProvider(t, dnsv1.ZoneFileFormatHosts)
+
+	rec := &dnsv1.DNSRecord{Spec: dnsv1.DNSRecordSpec{
+		RecordType: dnsv1.DNSRecordTypeAAAA,
+		Name:       "www.example.com",
+		Value:      "2001:db8::1",
+	}}
+	if _, err := p.CreateRecord(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+	if data := readFile(t, c, "hosts"); data != "2001:db8::1 www.example.com\n" {
+		t.Fatalf("unexpected hosts file %q", data)
+	}
+	if _, ok, err := p.SearchRecord(ctx, rec); err != nil || !ok {
+		t.Fatalf("SearchRecord after create: ok=%v err=%v", ok, err)
+	}
+
+	rec.Spec.RecordType = dnsv1.DNSRecordTypeCNAME
+	if _, err := p.CreateRecord(ctx, rec); err == nil {
+		t.Fatal("expected CNAME record to be rejected by hosts file")
+	}
+}
+
+func TestParseZone(t *testing.T) {
+	f, err := parseZone(`$ORIGIN example.com.
+@ 3600 IN SOA ns hostmaster 2023010100 3600 600 86400 60
+mail 300 IN A 10.0.0.2 ; added by hand
+`, "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.soa.Serial != 2023010100 || len(f.entries) != 1 || f.entries[0].comment != "added by hand" ||
+		f.entries[0].rr.Header().Rrtype != dns.TypeA || f.entries[0].id() != "mail.example.com. A" {
+		t.Fatalf("unexpected file %+v", f)
+	}
+}
@@ -0,0 +1,103 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type AdGuardAccount struct {
+	// The URL of the AdGuard Home web interface, e.g. http://adguard.lan:3000
+	ServerURL string `json:"server-url"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+}
+
+// AdGuardRewrite is a DNS rewrite rule, the answer is an IP address for A/AAAA records or a domain for CNAME records
+type AdGuardRewrite struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+}
+
+type AdGuardUtils struct {
+	account AdGuardAccount
+	client  *http.Client
+	baseURL string
+}
+
+func NewAdGuardUtils(account AdGuardAccount) (*AdGuardUtils, error) {
+	if account.ServerURL == "" {
+		return nil, fmt.Errorf("adguard serverURL is required")
+	}
+	return &AdGuardUtils{
+		account: account,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: strings.TrimSuffix(account.ServerURL, "/"),
+	}, nil
+}
+
+func (dns *AdGuardUtils) do(ctx context.Context, method string, path string, body interface{}, resp interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, dns.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if dns.account.Username != "" {
+		req.SetBasicAuth(dns.account.Username, dns.account.Password)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := dns.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= 300 {
+		// errors are returned as plain text
+		message, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024))
+		if len(bytes.TrimSpace(message)) == 0 {
+			return NewRESTError("adguard", method, path, httpResp, "")
+		}
+		return NewRESTError("adguard", method, path, httpResp, string(bytes.TrimSpace(message)))
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// ListRewrites returns all DNS rewrite rules
+func (dns *AdGuardUtils) ListRewrites(ctx context.Context) ([]AdGuardRewrite, error) {
+	var rewrites []AdGuardRewrite
+	if err := dns.do(ctx, http.MethodGet, "/control/rewrite/list", nil, &rewrites); err != nil {
+		return nil, err
+	}
+	return rewrites, nil
+}
+
+func (dns *AdGuardUtils) AddRewrite(ctx context.Context, rewrite AdGuardRewrite) error {
+	return dns.do(ctx, http.MethodPost, "/control/rewrite/add", rewrite, nil)
+}
+
+// UpdateRewrite replaces the target rule with the update one in one request
+func (dns *AdGuardUtils) UpdateRewrite(ctx context.Context, target AdGuardRewrite, update AdGuardRewrite) error {
+	return dns.do(ctx, http.MethodPut, "/control/rewrite/update", map[string]AdGuardRewrite{"target": target, "update": update}, nil)
+}
+
+func (dns *AdGuardUtils) DeleteRewrite(ctx context.Context, rewrite AdGuardRewrite) error {
+	return dns.do(ctx, http.MethodPost, "/control/rewrite/delete", rewrite, nil)
+}
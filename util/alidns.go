@@ -1,34 +1,265 @@
 package util
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	alidns "github.com/alibabacloud-go/alidns-20150109/client"
 	openapi "github.com/alibabacloud-go/darabonba-openapi/client"
 	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/credentials-go/credentials"
+)
+
+const (
+	aliDnsEndpoint = "dns.aliyuncs.com"
+	aliStsEndpoint = "sts.aliyuncs.com"
+	// The timeouts of the SDK in milliseconds, the SDK does not accept a context
+	aliDnsConnectTimeout = 5000
+	aliDnsReadTimeout    = 30000
+	// The session name of the assumed RAM roles if not set
+	aliDefaultRoleSessionName = "k8s-dns-manager"
+)
+
+// The credential types of AliDnsAccount, the names follow github.com/aliyun/credentials-go
+const (
+	AliCredentialTypeAccessKey   = "access_key"
+	AliCredentialTypeSTS         = "sts"
+	AliCredentialTypeRAMRoleARN  = "ram_role_arn"
+	AliCredentialTypeECSRAMRole  = "ecs_ram_role"
+	AliCredentialTypeOIDCRoleARN = "oidc_role_arn"
 )
 
 /**
- * 使用AK&SK初始化账号Client
- * @param accessKeyId
- * @param accessKeySecret
+ * 使用账号的凭证初始化Client
+ * @param account
  * @return Client
  * @throws Exception
  */
-func CreateAliDnsClient(accessKeyId *string, accessKeySecret *string) (_result *alidns.Client, _err error) {
-	config := &openapi.Config{}
-	// 您的AccessKey ID
-	config.AccessKeyId = accessKeyId
-	// 您的AccessKey Secret
-	config.AccessKeySecret = accessKeySecret
-	// 访问的域名
-	config.Endpoint = tea.String("dns.aliyuncs.com")
+func CreateAliDnsClient(account *AliDnsAccount) (_result *alidns.Client, _err error) {
+	credential, err := createAliCredential(account)
+	if err != nil {
+		return nil, err
+	}
+	config := &openapi.Config{
+		Credential: credential,
+		// 固定的超时, 使被放弃的调用最终结束
+		ConnectTimeout: tea.Int(aliDnsConnectTimeout),
+		ReadTimeout:    tea.Int(aliDnsReadTimeout),
+	}
+	// 访问的域名, 可以带有协议, 如 http://127.0.0.1:8080
+	config.Endpoint = tea.String(account.Endpoint)
+	if protocol, endpoint, ok := strings.Cut(account.Endpoint, "://"); ok {
+		config.Protocol = tea.String(protocol)
+		config.Endpoint = tea.String(endpoint)
+	}
+	if account.Endpoint == "" {
+		config.Endpoint = tea.String(aliDnsEndpoint)
+		if account.RegionID != "" {
+			config.Endpoint = tea.String("alidns." + account.RegionID + ".aliyuncs.com")
+		}
+	}
+	if account.RegionID != "" {
+		config.RegionId = tea.String(account.RegionID)
+	}
 	_result, _err = alidns.NewClient(config)
 	return _result, _err
 }
 
+func createAliCredential(account *AliDnsAccount) (credentials.Credential, error) {
+	roleSessionName := account.RoleSessionName
+	if roleSessionName == "" {
+		roleSessionName = aliDefaultRoleSessionName
+	}
+	roleArn := account.RoleArn
+	if roleArn == "" {
+		roleArn = os.Getenv("ALIBABA_CLOUD_ROLE_ARN")
+	}
+	config := &credentials.Config{
+		AccessKeyId:     tea.String(account.AccessKeyID),
+		AccessKeySecret: tea.String(account.AccessKeySecret),
+	}
+	switch account.CredentialType {
+	case "", AliCredentialTypeAccessKey:
+		config.Type = tea.String(AliCredentialTypeAccessKey)
+	case AliCredentialTypeSTS:
+		config.Type = tea.String(AliCredentialTypeSTS)
+		config.SecurityToken = tea.String(account.SecurityToken)
+	case AliCredentialTypeRAMRoleARN:
+		config.Type = tea.String(AliCredentialTypeRAMRoleARN)
+		config.RoleArn = tea.String(roleArn)
+		config.RoleSessionName = tea.String(roleSessionName)
+	case AliCredentialTypeECSRAMRole:
+		config.Type = tea.String(AliCredentialTypeECSRAMRole)
+		config.RoleName = tea.String(account.RoleName)
+	case AliCredentialTypeOIDCRoleARN:
+		credential := &aliOIDCCredential{
+			endpoint:        account.STSEndpoint,
+			roleArn:         roleArn,
+			oidcProviderArn: account.OIDCProviderArn,
+			oidcTokenFile:   account.OIDCTokenFile,
+			roleSessionName: roleSessionName,
+			client:          &http.Client{Timeout: 30 * time.Second},
+		}
+		if credential.endpoint == "" {
+			credential.endpoint = aliStsEndpoint
+			if account.RegionID != "" {
+				credential.endpoint = "sts." + account.RegionID + ".aliyuncs.com"
+			}
+		}
+		if !strings.Contains(credential.endpoint, "://") {
+			credential.endpoint = "https://" + credential.endpoint
+		}
+		if credential.oidcProviderArn == "" {
+			credential.oidcProviderArn = os.Getenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN")
+		}
+		if credential.oidcTokenFile == "" {
+			credential.oidcTokenFile = os.Getenv("ALIBABA_CLOUD_OIDC_TOKEN_FILE")
+		}
+		if credential.roleArn == "" || credential.oidcProviderArn == "" || credential.oidcTokenFile == "" {
+			return nil, fmt.Errorf("roleArn, oidcProviderArn and oidcTokenFile are required by the %s credential", AliCredentialTypeOIDCRoleARN)
+		}
+		return credential, nil
+	default:
+		return nil, fmt.Errorf("unknown aliyun credential type %q", account.CredentialType)
+	}
+	return credentials.NewCredential(config)
+}
+
+// aliOIDCCredential is the RAM role assumed with the OIDC token by AssumeRoleWithOIDC of STS, like RRSA of ACK.
+// The token file is read again on each refresh, as it is rotated by the kubelet.
+type aliOIDCCredential struct {
+	endpoint        string
+	roleArn         string
+	oidcProviderArn string
+	oidcTokenFile   string
+	roleSessionName string
+	client          *http.Client
+
+	mu              sync.Mutex
+	accessKeyID     string
+	accessKeySecret string
+	securityToken   string
+	expiration      time.Time
+}
+
+// The credential is refreshed when it expires in the period
+const aliCredentialRefreshBefore = 3 * time.Minute
+
+// refresh assumes the role again if the credential is about to expire
+func (c *aliOIDCCredential) refresh() (accessKeyID, accessKeySecret, securityToken string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Until(c.expiration) > aliCredentialRefreshBefore {
+		return c.accessKeyID, c.accessKeySecret, c.securityToken, nil
+	}
+
+	token, err := os.ReadFile(c.oidcTokenFile)
+	if err != nil {
+		return "", "", "", err
+	}
+	query := url.Values{
+		"Action":    {"AssumeRoleWithOIDC"},
+		"Format":    {"JSON"},
+		"Version":   {"2015-04-01"},
+		"Timestamp": {time.Now().UTC().Format("2006-01-02T15:04:05Z")},
+	}
+	form := url.Values{
+		"RoleArn":         {c.roleArn},
+		"OIDCProviderArn": {c.oidcProviderArn},
+		"OIDCToken":       {strings.TrimSpace(string(token))},
+		"RoleSessionName": {c.roleSessionName},
+		"DurationSeconds": {strconv.Itoa(3600)},
+	}
+	resp, err := c.client.PostForm(c.endpoint+"/?"+query.Encode(), form)
+	if err != nil {
+		return "", "", "", err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Code        string
+		Message     string
+		Credentials *struct {
+			AccessKeyId     string
+			AccessKeySecret string
+			SecurityToken   string
+			Expiration      string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", "", "", fmt.Errorf("AssumeRoleWithOIDC returned %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Credentials == nil {
+		return "", "", "", fmt.Errorf("AssumeRoleWithOIDC returned %s: [%s] %s", resp.Status, body.Code, body.Message)
+	}
+	expiration, err := time.Parse(time.RFC3339, body.Credentials.Expiration)
+	if err != nil {
+		return "", "", "", err
+	}
+	c.accessKeyID = body.Credentials.AccessKeyId
+	c.accessKeySecret = body.Credentials.AccessKeySecret
+	c.securityToken = body.Credentials.SecurityToken
+	c.expiration = expiration
+	return c.accessKeyID, c.accessKeySecret, c.securityToken, nil
+}
+
+func (c *aliOIDCCredential) GetAccessKeyId() (*string, error) {
+	accessKeyID, _, _, err := c.refresh()
+	if err != nil {
+		return nil, err
+	}
+	return tea.String(accessKeyID), nil
+}
+
+func (c *aliOIDCCredential) GetAccessKeySecret() (*string, error) {
+	_, accessKeySecret, _, err := c.refresh()
+	if err != nil {
+		return nil, err
+	}
+	return tea.String(accessKeySecret), nil
+}
+
+func (c *aliOIDCCredential) GetSecurityToken() (*string, error) {
+	_, _, securityToken, err := c.refresh()
+	if err != nil {
+		return nil, err
+	}
+	return tea.String(securityToken), nil
+}
+
+func (c *aliOIDCCredential) GetBearerToken() *string {
+	return tea.String("")
+}
+
+func (c *aliOIDCCredential) GetType() *string {
+	return tea.String(AliCredentialTypeOIDCRoleARN)
+}
+
 type AliDnsAccount struct {
 	AccessKeyID     string `json:"accessKey-id"`
 	AccessKeySecret string `json:"accessKey-secret"`
 	DomainName      string `json:"domain-name"`
+	// One of the AliCredentialType constants, access_key if empty
+	CredentialType  string `json:"credential-type"`
+	SecurityToken   string `json:"security-token"`
+	RoleArn         string `json:"role-arn"`
+	RoleSessionName string `json:"role-session-name"`
+	RoleName        string `json:"role-name"`
+	OIDCProviderArn string `json:"oidc-provider-arn"`
+	OIDCTokenFile   string `json:"oidc-token-file"`
+	RegionID        string `json:"region-id"`
+	// The endpoint of the Alibaba Cloud DNS API, alidns.<region>.aliyuncs.com or dns.aliyuncs.com if empty.
+	// The protocol is https unless the endpoint is prefixed by another one, e.g. http://
+	Endpoint string `json:"endpoint"`
+	// The endpoint of STS used by the oidc_role_arn credential, sts.<region>.aliyuncs.com or sts.aliyuncs.com if empty
+	STSEndpoint string `json:"sts-endpoint"`
 }
 
 type AliDNSUtils struct {
@@ -40,7 +271,7 @@ func NewAliDnsUtils(account AliDnsAccount) (*AliDNSUtils, error) {
 	dnsUtils := AliDNSUtils{
 		account: account,
 	}
-	client, err := CreateAliDnsClient(tea.String(account.AccessKeyID), tea.String(account.AccessKeySecret))
+	client, err := CreateAliDnsClient(&account)
 	if err != nil {
 		return nil, err
 	}
@@ -48,62 +279,128 @@ func NewAliDnsUtils(account AliDnsAccount) (*AliDNSUtils, error) {
 	return &dnsUtils, nil
 }
 
-func (dns *AliDNSUtils) GetRecordCount() (int64, error) {
-	resp, err := dns.client.DescribeDomainRecords(&alidns.DescribeDomainRecordsRequest{
-		DomainName: tea.String(dns.account.DomainName),
-		PageSize:   tea.Int64(1),
-	})
-	if err != nil {
-		return 0, err
+// aliDnsCall runs the call of the SDK, which does not accept a context, and returns early once the ctx is done.
+// The abandoned call ends by the read timeout of the client.
+func aliDnsCall[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
 	}
-	return *resp.Body.TotalCount, nil
-}
-
-func (dns *AliDNSUtils) ListRecords() ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
-	totalCount, err := dns.GetRecordCount()
-	if err != nil {
-		return nil, err
+	type result struct {
+		value T
+		err   error
 	}
-	resp, err := dns.client.DescribeDomainRecords(&alidns.DescribeDomainRecordsRequest{
-		DomainName: tea.String(dns.account.DomainName),
-		PageSize:   tea.Int64(totalCount),
-	})
-	if err != nil {
-		return nil, err
+	done := make(chan result, 1)
+	go func() {
+		value, err := call()
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
 	}
-	return resp.Body.DomainRecords.Record, nil
 }
 
-func (dns *AliDNSUtils) FindRecordById(id string) (*alidns.DescribeDomainRecordInfoResponseBody, error) {
-	resp, err := dns.client.DescribeDomainRecordInfo(&alidns.DescribeDomainRecordInfoRequest{
-		RecordId: tea.String(id),
-	})
-	if err != nil {
-		return nil, err
+// The max page size allowed by DescribeDomainRecords
+const aliDnsMaxPageSize = 500
+
+// describeRecords returns the records of all pages of the request
+func (dns *AliDNSUtils) describeRecords(ctx context.Context, request *alidns.DescribeDomainRecordsRequest) ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
+	var records []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
+	request.DomainName = tea.String(dns.account.DomainName)
+	request.PageSize = tea.Int64(aliDnsMaxPageSize)
+	for page := int64(1); ; page++ {
+		request.PageNumber = tea.Int64(page)
+		resp, err := aliDnsCall(ctx, func() (*alidns.DescribeDomainRecordsResponse, error) {
+			return dns.client.DescribeDomainRecords(request)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Body.DomainRecords == nil || len(resp.Body.DomainRecords.Record) == 0 {
+			break
+		}
+		records = append(records, resp.Body.DomainRecords.Record...)
+		if page*aliDnsMaxPageSize >= tea.Int64Value(resp.Body.TotalCount) {
+			break
+		}
 	}
-	return resp.Body, nil
+	return records, nil
+}
 
+func (dns *AliDNSUtils) ListRecords(ctx context.Context) ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
+	return dns.describeRecords(ctx, &alidns.DescribeDomainRecordsRequest{})
 }
 
-func (dns *AliDNSUtils) FindRecordByRR(rr string) (*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
-	records, err := dns.ListRecords()
+// FindRecordsByRR returns the records of the RR and the type, the records of all types if Type is empty.
+// The records are filtered by the server, RRKeyWord matches the RR fuzzily, so the RR is matched exactly again.
+func (dns *AliDNSUtils) FindRecordsByRR(ctx context.Context, rr string, Type string) ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
+	request := &alidns.DescribeDomainRecordsRequest{
+		SearchMode: tea.String("ADVANCED"),
+		RRKeyWord:  tea.String(rr),
+	}
+	if Type != "" {
+		request.TypeKeyWord = tea.String(Type)
+	}
+	records, err := dns.describeRecords(ctx, request)
 	if err != nil {
 		return nil, err
 	}
+	var result []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
 	for _, record := range records {
-		if *record.RR == rr {
-			return record, nil
+		if tea.StringValue(record.RR) == rr && (Type == "" || tea.StringValue(record.Type) == Type) {
+			result = append(result, record)
 		}
 	}
-	return nil, nil
+	return result, nil
+}
+
+// recordValue splits the preference out of the value of MX records, which is a separate parameter in Aliyun and DNSPod
+func recordValue(Type string, Value string) (string, uint64) {
+	if Type != "MX" {
+		return Value, 0
+	}
+	preference, value, ok := strings.Cut(Value, " ")
+	if !ok {
+		return Value, 0
+	}
+	mx, err := strconv.ParseUint(preference, 10, 64)
+	if err != nil {
+		return Value, 0
+	}
+	return strings.TrimSpace(value), mx
+}
+
+// aliDnsLine returns the line of the request, the default line is used if empty
+func aliDnsLine(Line string) *string {
+	if Line == "" {
+		return nil
+	}
+	return tea.String(Line)
 }
 
-func (dns *AliDNSUtils) CreateRecord(RR string, Value string, Type string) (string, error) {
-	resp, err := dns.client.AddDomainRecord(&alidns.AddDomainRecordRequest{
-		DomainName: tea.String(dns.account.DomainName),
-		RR:         tea.String(RR),
-		Type:       tea.String(Type),
-		Value:      tea.String(Value),
+// aliDnsPriority returns the priority of the request, which is only used by MX records
+func aliDnsPriority(mx uint64) *int64 {
+	if mx == 0 {
+		return nil
+	}
+	return tea.Int64(int64(mx))
+}
+
+// CreateRecord creates the record on the line, the preference of MX records in Value is sent as the priority
+func (dns *AliDNSUtils) CreateRecord(ctx context.Context, RR string, Value string, Type string, Line string) (string, error) {
+	value, mx := recordValue(Type, Value)
+	resp, err := aliDnsCall(ctx, func() (*alidns.AddDomainRecordResponse, error) {
+		return dns.client.AddDomainRecord(&alidns.AddDomainRecordRequest{
+			DomainName: tea.String(dns.account.DomainName),
+			RR:         tea.String(RR),
+			Type:       tea.String(Type),
+			Value:      tea.String(value),
+			Priority:   aliDnsPriority(mx),
+			Line:       aliDnsLine(Line),
+		})
 	})
 	if err != nil {
 		return "", err
@@ -111,30 +408,36 @@ func (dns *AliDNSUtils) CreateRecord(RR string, Value string, Type string) (stri
 	return *resp.Body.RecordId, nil
 }
 
-func (dns *AliDNSUtils) DeleteRecord(recordId string) error {
-	_, err := dns.client.DeleteDomainRecord(&alidns.DeleteDomainRecordRequest{
-		RecordId: tea.String(recordId),
+func (dns *AliDNSUtils) DeleteRecord(ctx context.Context, recordId string) error {
+	_, err := aliDnsCall(ctx, func() (*alidns.DeleteDomainRecordResponse, error) {
+		return dns.client.DeleteDomainRecord(&alidns.DeleteDomainRecordRequest{
+			RecordId: tea.String(recordId),
+		})
 	})
 	return err
 }
 
-func (dns *AliDNSUtils) DeleteRecordByRR(rr string) error {
-	record, err := dns.FindRecordByRR(rr)
-	if err != nil {
-		return err
-	}
-	if record == nil {
-		return nil
-	}
-	return dns.DeleteRecord(*record.RecordId)
+func (dns *AliDNSUtils) UpdateRecord(ctx context.Context, RecordId string, RR string, Value string, Type string, Line string) error {
+	value, mx := recordValue(Type, Value)
+	_, err := aliDnsCall(ctx, func() (*alidns.UpdateDomainRecordResponse, error) {
+		return dns.client.UpdateDomainRecord(&alidns.UpdateDomainRecordRequest{
+			RecordId: tea.String(RecordId),
+			RR:       tea.String(RR),
+			Type:     tea.String(Type),
+			Value:    tea.String(value),
+			Priority: aliDnsPriority(mx),
+			Line:     aliDnsLine(Line),
+		})
+	})
+	return err
 }
 
-func (dns *AliDNSUtils) UpdateRecord(RecordId string, RR string, Value string, Type string) error {
-	_, err := dns.client.UpdateDomainRecord((&alidns.UpdateDomainRecordRequest{
-		RecordId: tea.String(RecordId),
-		RR:       tea.String(RR),
-		Type:     tea.String(Type),
-		Value:    tea.String(Value),
-	}))
+func (dns *AliDNSUtils) UpdateRecordRemark(ctx context.Context, RecordId string, Remark string) error {
+	_, err := aliDnsCall(ctx, func() (*alidns.UpdateDomainRecordRemarkResponse, error) {
+		return dns.client.UpdateDomainRecordRemark(&alidns.UpdateDomainRecordRemarkRequest{
+			RecordId: tea.String(RecordId),
+			Remark:   tea.String(Remark),
+		})
+	})
 	return err
 }
@@ -0,0 +1,190 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/client"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestAliOIDCCredential(t *testing.T) {
+	for _, env := range []string{"ALIBABA_CLOUD_ROLE_ARN", "ALIBABA_CLOUD_OIDC_PROVIDER_ARN", "ALIBABA_CLOUD_OIDC_TOKEN_FILE"} {
+		t.Setenv(env, "")
+	}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("Action") != "AssumeRoleWithOIDC" || r.PostFormValue("OIDCToken") != "oidc-token" ||
+			r.PostFormValue("RoleArn") != "acs:ram::1:role/dns" || r.PostFormValue("RoleSessionName") != aliDefaultRoleSessionName {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"Code":"InvalidParameter","Message":"invalid request"}`))
+			return
+		}
+		w.Write([]byte(`{"Credentials":{"AccessKeyId":"STS.id","AccessKeySecret":"secret","SecurityToken":"token","Expiration":"` +
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}}`))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("oidc-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	credential, err := createAliCredential(&AliDnsAccount{
+		CredentialType:  AliCredentialTypeOIDCRoleARN,
+		RoleArn:         "acs:ram::1:role/dns",
+		OIDCProviderArn: "acs:ram::1:oidc-provider/ack",
+		OIDCTokenFile:   tokenFile,
+		STSEndpoint:     server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessKeyID, err := credential.GetAccessKeyId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	securityToken, err := credential.GetSecurityToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tea.StringValue(accessKeyID) != "STS.id" || tea.StringValue(securityToken) != "token" {
+		t.Fatalf("unexpected credential %s %s", tea.StringValue(accessKeyID), tea.StringValue(securityToken))
+	}
+	if calls != 1 {
+		t.Fatalf("the credential is assumed %d times before expiration", calls)
+	}
+
+	if _, err := createAliCredential(&AliDnsAccount{CredentialType: AliCredentialTypeOIDCRoleARN, STSEndpoint: server.URL}); err == nil {
+		t.Fatal("expected the OIDC credential without role to be rejected")
+	}
+}
+
+// aliDnsServer is a local stand-in of the record API of Alibaba Cloud DNS
+type aliDnsServer struct {
+	records []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
+	// the requests of DescribeDomainRecords
+	pages int
+}
+
+func (s *aliDnsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	w.Header().Set("Content-Type", "application/json")
+	if action := r.Form.Get("Action"); action != "DescribeDomainRecords" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"Code":"InvalidAction.NotFound","Message":"unknown action %s"}`, action)
+		return
+	}
+	s.pages++
+	var matched []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
+	for _, record := range s.records {
+		if tea.StringValue(record.DomainName) != r.Form.Get("DomainName") {
+			continue
+		}
+		if r.Form.Get("SearchMode") == "ADVANCED" {
+			if !strings.Contains(tea.StringValue(record.RR), r.Form.Get("RRKeyWord")) ||
+				(r.Form.Get("TypeKeyWord") != "" && tea.StringValue(record.Type) != r.Form.Get("TypeKeyWord")) {
+				continue
+			}
+		}
+		matched = append(matched, record)
+	}
+	page, _ := strconv.Atoi(r.Form.Get("PageNumber"))
+	size, _ := strconv.Atoi(r.Form.Get("PageSize"))
+	start, end := (page-1)*size, page*size
+	if start > len(matched) {
+		start = len(matched)
+	}
+	if end > len(matched) {
+		end = len(matched)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"RequestId":     "test",
+		"TotalCount":    len(matched),
+		"PageNumber":    page,
+		"PageSize":      size,
+		"DomainRecords": map[string]interface{}{"Record": matched[start:end]},
+	})
+}
+
+func newAliDnsUtils(t *testing.T, server *aliDnsServer) *AliDNSUtils {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	dnsUtils, err := NewAliDnsUtils(AliDnsAccount{
+		AccessKeyID:     "test-id",
+		AccessKeySecret: "test-secret",
+		DomainName:      "example.com",
+		Endpoint:        httpServer.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dnsUtils
+}
+
+func aliDnsRecord(id string, rr string, Type string, value string) *alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord {
+	return &alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+		DomainName: tea.String("example.com"),
+		RecordId:   tea.String(id),
+		RR:         tea.String(rr),
+		Type:       tea.String(Type),
+		Value:      tea.String(value),
+		Line:       tea.String("default"),
+	}
+}
+
+func TestAliDNSUtilsFindRecordsByRR(t *testing.T) {
+	server := &aliDnsServer{records: []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+		aliDnsRecord("1", "www", "TXT", "v=spf1 -all"),
+		aliDnsRecord("2", "www", "A", "192.168.1.1"),
+		aliDnsRecord("3", "www2", "A", "192.168.1.2"),
+	}}
+	dnsUtils := newAliDnsUtils(t, server)
+
+	records, err := dnsUtils.FindRecordsByRR(context.Background(), "www", "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || tea.StringValue(records[0].RecordId) != "2" {
+		t.Fatalf("expected the A record of www, got %v", records)
+	}
+	// the fuzzy keyword www also matches www2
+	records, err = dnsUtils.FindRecordsByRR(context.Background(), "www", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected the records of all types of www, got %v", records)
+	}
+	if records, err := dnsUtils.FindRecordsByRR(context.Background(), "www", "CNAME"); err != nil || len(records) != 0 {
+		t.Fatalf("expected no CNAME record of www, got %v %v", records, err)
+	}
+}
+
+func TestAliDNSUtilsListRecords(t *testing.T) {
+	server := &aliDnsServer{}
+	for i := 0; i < aliDnsMaxPageSize*2+1; i++ {
+		server.records = append(server.records, aliDnsRecord(strconv.Itoa(i), "host"+strconv.Itoa(i), "A", "192.168.1.1"))
+	}
+	server.records = append(server.records, &alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+		DomainName: tea.String("example.org"), RecordId: tea.String("other"), RR: tea.String("www"), Type: tea.String("A"),
+	})
+	dnsUtils := newAliDnsUtils(t, server)
+
+	records, err := dnsUtils.ListRecords(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != aliDnsMaxPageSize*2+1 || server.pages != 3 {
+		t.Fatalf("expected %d records in 3 pages, got %d in %d", aliDnsMaxPageSize*2+1, len(records), server.pages)
+	}
+}
@@ -0,0 +1,290 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const azureDNSAPIVersion = "2018-05-01"
+
+type AzureDNSAccount struct {
+	SubscriptionID string `json:"subscription-id"`
+	ResourceGroup  string `json:"resource-group"`
+	ZoneName       string `json:"zone-name"`
+	TenantID       string `json:"tenant-id"`
+	ClientID       string `json:"client-id"`
+	// The client secret of the service principal, the workload identity or the managed identity is used if empty
+	ClientSecret string `json:"client-secret"`
+	// The endpoint of Azure Resource Manager, e.g. https://management.azure.com
+	Endpoint string `json:"endpoint"`
+	// The endpoint of Microsoft Entra ID, e.g. https://login.microsoftonline.com
+	AuthorityHost string `json:"authority-host"`
+}
+
+type AzureRecordSet struct {
+	Name string `json:"name,omitempty"`
+	// The resource type, e.g. `Microsoft.Network/dnszones/A`
+	Type       string                   `json:"type,omitempty"`
+	Properties AzureRecordSetProperties `json:"properties"`
+}
+
+// RecordType returns the DNS record type of the record set, e.g. `A`
+func (rs *AzureRecordSet) RecordType() string {
+	return rs.Type[strings.LastIndex(rs.Type, "/")+1:]
+}
+
+type AzureRecordSetProperties struct {
+	TTL         int               `json:"TTL"`
+	FQDN        string            `json:"fqdn,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ARecords    []AzureARecord    `json:"ARecords,omitempty"`
+	AAAARecords []AzureAAAARecord `json:"AAAARecords,omitempty"`
+	CNAMERecord *AzureCNAMERecord `json:"CNAMERecord,omitempty"`
+	TXTRecords  []AzureTXTRecord  `json:"TXTRecords,omitempty"`
+	MXRecords   []AzureMXRecord   `json:"MXRecords,omitempty"`
+	SRVRecords  []AzureSRVRecord  `json:"SRVRecords,omitempty"`
+	NSRecords   []AzureNSRecord   `json:"NSRecords,omitempty"`
+	CAARecords  []AzureCAARecord  `json:"caaRecords,omitempty"`
+	SOARecord   *json.RawMessage  `json:"SOARecord,omitempty"`
+}
+
+type AzureARecord struct {
+	IPv4Address string `json:"ipv4Address"`
+}
+
+type AzureAAAARecord struct {
+	IPv6Address string `json:"ipv6Address"`
+}
+
+type AzureCNAMERecord struct {
+	CNAME string `json:"cname"`
+}
+
+type AzureTXTRecord struct {
+	Value []string `json:"value"`
+}
+
+type AzureMXRecord struct {
+	Preference int    `json:"preference"`
+	Exchange   string `json:"exchange"`
+}
+
+type AzureSRVRecord struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Target   string `json:"target"`
+}
+
+type AzureNSRecord struct {
+	NSDName string `json:"nsdname"`
+}
+
+type AzureCAARecord struct {
+	Flags int    `json:"flags"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+type AzureDNSUtils struct {
+	account AzureDNSAccount
+	client  *http.Client
+	zoneURL string
+}
+
+// azureTokenSource requests the tokens of Azure Resource Manager by the client secret,
+// the federated token of the workload identity, or the managed identity in order
+type azureTokenSource struct {
+	account AzureDNSAccount
+	client  *http.Client
+}
+
+func (ts *azureTokenSource) Token() (*oauth2.Token, error) {
+	resource := strings.TrimSuffix(ts.account.Endpoint, "/") + "/"
+	var req *http.Request
+	var err error
+	federatedTokenFile := os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+	if ts.account.ClientSecret != "" || federatedTokenFile != "" {
+		if ts.account.TenantID == "" || ts.account.ClientID == "" {
+			return nil, fmt.Errorf("azure tenantId and clientId are required")
+		}
+		form := url.Values{
+			"grant_type": {"client_credentials"},
+			"client_id":  {ts.account.ClientID},
+			"scope":      {resource + ".default"},
+		}
+		if ts.account.ClientSecret != "" {
+			form.Set("client_secret", ts.account.ClientSecret)
+		} else {
+			// the token file is rotated by the workload identity webhook, so it's read on every request
+			assertion, err := os.ReadFile(federatedTokenFile)
+			if err != nil {
+				return nil, err
+			}
+			form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+			form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+		}
+		tokenURL := strings.TrimSuffix(ts.account.AuthorityHost, "/") + "/" + url.PathEscape(ts.account.TenantID) + "/oauth2/v2.0/token"
+		req, err = http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := url.Values{"api-version": {"2018-02-01"}, "resource": {resource}}
+		if ts.account.ClientID != "" {
+			query.Set("client_id", ts.account.ClientID)
+		}
+		req, err = http.NewRequest(http.MethodGet, "http://169.254.169.254/metadata/identity/oauth2/token?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata", "true")
+	}
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		AccessToken      string      `json:"access_token"`
+		TokenType        string      `json:"token_type"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("azure token: %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode >= 300 || body.AccessToken == "" {
+		return nil, fmt.Errorf("azure token: %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	token := &oauth2.Token{AccessToken: body.AccessToken, TokenType: body.TokenType}
+	// the managed identity returns expires_in as a string
+	if expiresIn, err := body.ExpiresIn.Int64(); err == nil {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// withDefaults fills the defaults of the endpoints and the credentials of the account
+func (account AzureDNSAccount) withDefaults() AzureDNSAccount {
+	if account.Endpoint == "" {
+		account.Endpoint = "https://management.azure.com"
+	}
+	if account.AuthorityHost == "" {
+		account.AuthorityHost = "https://login.microsoftonline.com"
+	}
+	if account.TenantID == "" {
+		account.TenantID = os.Getenv("AZURE_TENANT_ID")
+	}
+	if account.ClientID == "" {
+		account.ClientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	account.ZoneName = strings.TrimSuffix(account.ZoneName, ".")
+	return account
+}
+
+// NewAzureTokenSource creates the token source of the credentials of the account
+func NewAzureTokenSource(account AzureDNSAccount) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &azureTokenSource{account: account.withDefaults(), client: &http.Client{Timeout: 30 * time.Second}})
+}
+
+// NewAzureDNSUtils creates the utils of the account authorized by ts, see NewAzureTokenSource
+func NewAzureDNSUtils(account AzureDNSAccount, ts oauth2.TokenSource) (*AzureDNSUtils, error) {
+	if account.SubscriptionID == "" || account.ResourceGroup == "" {
+		return nil, fmt.Errorf("azure subscriptionId and resourceGroup are required")
+	}
+	account = account.withDefaults()
+	return &AzureDNSUtils{
+		account: account,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &oauth2.Transport{Source: ts},
+		},
+		zoneURL: strings.TrimSuffix(account.Endpoint, "/") + "/subscriptions/" + url.PathEscape(account.SubscriptionID) +
+			"/resourceGroups/" + url.PathEscape(account.ResourceGroup) + "/providers/Microsoft.Network/dnsZones/" + url.PathEscape(account.ZoneName),
+	}, nil
+}
+
+func (dns *AzureDNSUtils) do(ctx context.Context, method string, rawURL string, body interface{}, resp interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := dns.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= 300 {
+		var respErr struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(httpResp.Body).Decode(&respErr); err != nil || respErr.Error.Message == "" {
+			return fmt.Errorf("azure dns %s %s: %s", method, dns.account.ZoneName, httpResp.Status)
+		}
+		return fmt.Errorf("azure dns %s %s: %s: %s", method, dns.account.ZoneName, respErr.Error.Code, respErr.Error.Message)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func (dns *AzureDNSUtils) recordSetURL(recordType string, name string) string {
+	return dns.zoneURL + "/" + url.PathEscape(recordType) + "/" + url.PathEscape(name) + "?api-version=" + azureDNSAPIVersion
+}
+
+// ListRecordSets returns all record sets of the zone
+func (dns *AzureDNSUtils) ListRecordSets(ctx context.Context) ([]*AzureRecordSet, error) {
+	var recordSets []*AzureRecordSet
+	next := dns.zoneURL + "/all?api-version=" + azureDNSAPIVersion
+	for next != "" {
+		var resp struct {
+			Value    []*AzureRecordSet `json:"value"`
+			NextLink string            `json:"nextLink"`
+		}
+		if err := dns.do(ctx, http.MethodGet, next, nil, &resp); err != nil {
+			return nil, err
+		}
+		recordSets = append(recordSets, resp.Value...)
+		next = resp.NextLink
+	}
+	return recordSets, nil
+}
+
+// PutRecordSet creates or replaces the record set of the relative name, e.g. `www` or `@`
+func (dns *AzureDNSUtils) PutRecordSet(ctx context.Context, recordType string, name string, properties *AzureRecordSetProperties) error {
+	return dns.do(ctx, http.MethodPut, dns.recordSetURL(recordType, name), map[string]interface{}{"properties": properties}, nil)
+}
+
+func (dns *AzureDNSUtils) DeleteRecordSet(ctx context.Context, recordType string, name string) error {
+	return dns.do(ctx, http.MethodDelete, dns.recordSetURL(recordType, name), nil, nil)
+}
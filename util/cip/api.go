@@ -1,6 +1,7 @@
 package cip
 
 import (
+	"context"
 	"regexp"
 	"time"
 )
@@ -31,21 +32,21 @@ func New() (cip *Cip) {
 	return
 }
 
-func (cip *Cip) MyIPv4() (ip string) {
+func (cip *Cip) MyIPv4(ctx context.Context) (ip string) {
 	regx := regexp.MustCompile(RegxIPv4)
-	return cip.FastWGetWithVailder(cip.ApiIPv4, func(s string) string {
+	return cip.FastWGetWithVailder(ctx, cip.ApiIPv4, func(s string) string {
 		return regx.FindString((s))
 	})
 }
 
-func (cip *Cip) MyIPv6() (ip string) {
+func (cip *Cip) MyIPv6(ctx context.Context) (ip string) {
 	regx := regexp.MustCompile(RegxIPv6)
-	return cip.FastWGetWithVailder(cip.ApiIPv6, func(s string) string {
+	return cip.FastWGetWithVailder(ctx, cip.ApiIPv6, func(s string) string {
 		return regx.FindString((s))
 	})
 }
 
-func (cip *Cip) FastWGetWithVailder(ipAPI []string, vailder func(string) string) (ip string) {
+func (cip *Cip) FastWGetWithVailder(ctx context.Context, ipAPI []string, vailder func(string) string) (ip string) {
 	var (
 		length   = len(ipAPI)
 		ipMap    = make(map[string]int, length/5)
@@ -54,11 +55,16 @@ func (cip *Cip) FastWGetWithVailder(ipAPI []string, vailder func(string) string)
 	)
 	for _, url := range ipAPI {
 		go func(url string) {
-			cchan <- vailder(wGet(url, cip.MinTimeout))
+			cchan <- vailder(wGet(ctx, url, cip.MinTimeout))
 		}(url)
 	}
 	for i := 0; i < length; i++ {
-		v := <-cchan
+		var v string
+		select {
+		case v = <-cchan:
+		case <-ctx.Done():
+			return
+		}
 		if len(v) == 0 {
 			continue
 		}
@@ -74,8 +80,8 @@ func (cip *Cip) FastWGetWithVailder(ipAPI []string, vailder func(string) string)
 	}
 
 	// Use First ipAPI as failsafe
-	if len(ip) == 0 {
-		ip = vailder(wGet(ipAPI[0], 5*cip.MinTimeout))
+	if len(ip) == 0 && ctx.Err() == nil {
+		ip = vailder(wGet(ctx, ipAPI[0], 5*cip.MinTimeout))
 	}
 	return
 }
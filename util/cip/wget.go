@@ -1,6 +1,7 @@
 package cip
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
@@ -13,15 +14,15 @@ var UA_OSs = []string{
 	"Android 7.0; Mobile", "iPhone; CPU iPhone OS 12_1 like Mac OS X",
 }
 
-func wGet(url string, timeout time.Duration) (str string) {
-	request, err := http.NewRequest("GET", url, nil)
+func wGet(ctx context.Context, url string, timeout time.Duration) (str string) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return
+	}
 	request.Header.Set(
 		"User-Agent",
 		fmt.Sprintf("Mozilla/5.0 (%s)", UA_OSs[rand.Intn(len(UA_OSs))]),
 	)
-	if err != nil {
-		return
-	}
 	client := &http.Client{
 		Timeout: timeout,
 	}
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type DigitalOceanAccount struct {
+	Token  string `json:"token"`
+	Domain string `json:"domain"`
+	// The endpoint of the DigitalOcean API, e.g. https://api.digitalocean.com/v2
+	Endpoint string `json:"endpoint"`
+}
+
+type DigitalOceanRecord struct {
+	ID   int    `json:"id,omitempty"`
+	Type string `json:"type"`
+	// The name relative to the domain, `@` for the apex
+	Name string `json:"name"`
+	// The address of A and AAAA records, the target of CNAME, MX, NS and SRV records, the value of TXT and CAA records
+	Data     string `json:"data"`
+	Priority *int   `json:"priority"`
+	Port     *int   `json:"port"`
+	Weight   *int   `json:"weight"`
+	Flags    *int   `json:"flags"`
+	Tag      string `json:"tag,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
+}
+
+type DigitalOceanUtils struct {
+	account    DigitalOceanAccount
+	rest       *RESTClient
+	recordsURL string
+}
+
+func digitalOceanErrorMessage(body []byte) string {
+	var respErr struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &respErr) != nil {
+		return ""
+	}
+	return respErr.Message
+}
+
+func NewDigitalOceanUtils(account DigitalOceanAccount) (*DigitalOceanUtils, error) {
+	if account.Token == "" {
+		return nil, fmt.Errorf("digitalocean token is required")
+	}
+	if account.Endpoint == "" {
+		account.Endpoint = "https://api.digitalocean.com/v2"
+	}
+	account.Domain = strings.TrimSuffix(account.Domain, ".")
+	rest := NewRESTClient("digitalocean", account.Endpoint, http.Header{"Authorization": {"Bearer " + account.Token}})
+	rest.ErrorMessage = digitalOceanErrorMessage
+	return &DigitalOceanUtils{
+		account:    account,
+		rest:       rest,
+		recordsURL: "/domains/" + url.PathEscape(account.Domain) + "/records",
+	}, nil
+}
+
+// ListRecords returns all records of the domain
+func (dns *DigitalOceanUtils) ListRecords(ctx context.Context) ([]*DigitalOceanRecord, error) {
+	var records []*DigitalOceanRecord
+	for page := 1; ; page++ {
+		var resp struct {
+			DomainRecords []*DigitalOceanRecord `json:"domain_records"`
+			Links         struct {
+				Pages struct {
+					Next string `json:"next"`
+				} `json:"pages"`
+			} `json:"links"`
+		}
+		query := url.Values{"page": {strconv.Itoa(page)}, "per_page": {"200"}}
+		if err := dns.rest.Do(ctx, http.MethodGet, dns.recordsURL, query, nil, &resp); err != nil {
+			return nil, err
+		}
+		records = append(records, resp.DomainRecords...)
+		if resp.Links.Pages.Next == "" || len(resp.DomainRecords) == 0 {
+			return records, nil
+		}
+	}
+}
+
+// CreateRecord creates the record in the domain, returns the created record
+func (dns *DigitalOceanUtils) CreateRecord(ctx context.Context, record *DigitalOceanRecord) (*DigitalOceanRecord, error) {
+	var resp struct {
+		DomainRecord *DigitalOceanRecord `json:"domain_record"`
+	}
+	if err := dns.rest.Do(ctx, http.MethodPost, dns.recordsURL, nil, record, &resp); err != nil {
+		return nil, err
+	}
+	return resp.DomainRecord, nil
+}
+
+func (dns *DigitalOceanUtils) UpdateRecord(ctx context.Context, id int, record *DigitalOceanRecord) error {
+	return dns.rest.Do(ctx, http.MethodPut, dns.recordsURL+"/"+strconv.Itoa(id), nil, record, nil)
+}
+
+func (dns *DigitalOceanUtils) DeleteRecord(ctx context.Context, id int) error {
+	return dns.rest.Do(ctx, http.MethodDelete, dns.recordsURL+"/"+strconv.Itoa(id), nil, nil, nil)
+}
@@ -0,0 +1,232 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	dnsPodEndpoint = "https://dnspod.tencentcloudapi.com"
+	dnsPodService  = "dnspod"
+	dnsPodVersion  = "2021-03-23"
+	// The max page size allowed by DescribeRecordList
+	dnsPodMaxPageSize = 3000
+	// The error code of DescribeRecordList when the domain has no records
+	dnsPodErrNoRecord = "ResourceNotFound.NoDataOfRecord"
+)
+
+type DNSPodAccount struct {
+	SecretID   string `json:"secret-id"`
+	SecretKey  string `json:"secret-key"`
+	DomainName string `json:"domain-name"`
+	// The endpoint of the Tencent Cloud API, https://dnspod.tencentcloudapi.com if empty
+	Endpoint string `json:"endpoint"`
+}
+
+type DNSPodRecord struct {
+	RecordId uint64 `json:"RecordId"`
+	Name     string `json:"Name"`
+	Type     string `json:"Type"`
+	Line     string `json:"Line"`
+	Value    string `json:"Value"`
+	TTL      uint64 `json:"TTL"`
+	MX       uint64 `json:"MX"`
+	Remark   string `json:"Remark"`
+}
+
+// DNSPodError is the error returned by the Tencent Cloud API
+type DNSPodError struct {
+	Code      string
+	Message   string
+	RequestId string
+}
+
+func (e *DNSPodError) Error() string {
+	return fmt.Sprintf("[%s] %s (RequestId: %s)", e.Code, e.Message, e.RequestId)
+}
+
+type DNSPodUtils struct {
+	account DNSPodAccount
+	client  *http.Client
+}
+
+func NewDNSPodUtils(account DNSPodAccount) (*DNSPodUtils, error) {
+	if account.SecretID == "" || account.SecretKey == "" {
+		return nil, fmt.Errorf("dnspod secretId and secretKey is required")
+	}
+	if account.Endpoint == "" {
+		account.Endpoint = dnsPodEndpoint
+	}
+	return &DNSPodUtils{
+		account: account,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func hmacSHA256(key []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(msg []byte) string {
+	sum := sha256.Sum256(msg)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign returns the TC3-HMAC-SHA256 Authorization header of the request
+func (dns *DNSPodUtils) sign(host string, payload []byte, timestamp int64) string {
+	contentType := "application/json; charset=utf-8"
+	canonicalRequest := "POST\n/\n\ncontent-type:" + contentType + "\nhost:" + host + "\n\ncontent-type;host\n" + sha256Hex(payload)
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	credentialScope := date + "/" + dnsPodService + "/tc3_request"
+	stringToSign := "TC3-HMAC-SHA256\n" + strconv.FormatInt(timestamp, 10) + "\n" + credentialScope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	secretDate := hmacSHA256([]byte("TC3"+dns.account.SecretKey), date)
+	secretService := hmacSHA256(secretDate, dnsPodService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+	return "TC3-HMAC-SHA256 Credential=" + dns.account.SecretID + "/" + credentialScope + ", SignedHeaders=content-type;host, Signature=" + signature
+}
+
+// call invokes the action with the request, and decodes the `Response` field of the response body into resp
+func (dns *DNSPodUtils) call(ctx context.Context, action string, request interface{}, resp interface{}) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dns.account.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Version", dnsPodVersion)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("Authorization", dns.sign(req.URL.Host, payload, timestamp))
+
+	httpResp, err := dns.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	var body struct {
+		Response json.RawMessage
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("%s: %s: %w", action, httpResp.Status, err)
+	}
+	var respErr struct {
+		Error     *DNSPodError
+		RequestId string
+	}
+	if err := json.Unmarshal(body.Response, &respErr); err != nil {
+		return err
+	}
+	if respErr.Error != nil {
+		respErr.Error.RequestId = respErr.RequestId
+		return respErr.Error
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(body.Response, resp)
+}
+
+func (dns *DNSPodUtils) ListRecords(ctx context.Context) ([]*DNSPodRecord, error) {
+	var records []*DNSPodRecord
+	for offset := 0; ; offset += dnsPodMaxPageSize {
+		var resp struct {
+			RecordCountInfo struct {
+				TotalCount int
+			}
+			RecordList []*DNSPodRecord
+		}
+		err := dns.call(ctx, "DescribeRecordList", map[string]interface{}{
+			"Domain": dns.account.DomainName,
+			"Offset": offset,
+			"Limit":  dnsPodMaxPageSize,
+		}, &resp)
+		if dnsPodErr, ok := err.(*DNSPodError); ok && dnsPodErr.Code == dnsPodErrNoRecord {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, resp.RecordList...)
+		if len(resp.RecordList) == 0 || len(records) >= resp.RecordCountInfo.TotalCount {
+			break
+		}
+	}
+	return records, nil
+}
+
+func (dns *DNSPodUtils) recordRequest(RR string, Value string, Type string, Line string, TTL int) map[string]interface{} {
+	value, mx := recordValue(Type, Value)
+	request := map[string]interface{}{
+		"Domain":     dns.account.DomainName,
+		"SubDomain":  RR,
+		"RecordType": Type,
+		"RecordLine": Line,
+		"Value":      value,
+	}
+	if mx != 0 {
+		request["MX"] = mx
+	}
+	if TTL > 0 {
+		request["TTL"] = TTL
+	}
+	return request
+}
+
+func (dns *DNSPodUtils) CreateRecord(ctx context.Context, RR string, Value string, Type string, Line string, TTL int) (string, error) {
+	var resp struct {
+		RecordId uint64
+	}
+	if err := dns.call(ctx, "CreateRecord", dns.recordRequest(RR, Value, Type, Line, TTL), &resp); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(resp.RecordId, 10), nil
+}
+
+func (dns *DNSPodUtils) UpdateRecord(ctx context.Context, RecordId string, RR string, Value string, Type string, Line string, TTL int) error {
+	id, err := strconv.ParseUint(RecordId, 10, 64)
+	if err != nil {
+		return err
+	}
+	request := dns.recordRequest(RR, Value, Type, Line, TTL)
+	request["RecordId"] = id
+	return dns.call(ctx, "ModifyRecord", request, nil)
+}
+
+func (dns *DNSPodUtils) UpdateRecordRemark(ctx context.Context, RecordId string, Remark string) error {
+	id, err := strconv.ParseUint(RecordId, 10, 64)
+	if err != nil {
+		return err
+	}
+	return dns.call(ctx, "ModifyRecordRemark", map[string]interface{}{
+		"Domain":   dns.account.DomainName,
+		"RecordId": id,
+		"Remark":   Remark,
+	}, nil)
+}
+
+func (dns *DNSPodUtils) DeleteRecord(ctx context.Context, RecordId string) error {
+	id, err := strconv.ParseUint(RecordId, 10, 64)
+	if err != nil {
+		return err
+	}
+	return dns.call(ctx, "DeleteRecord", map[string]interface{}{
+		"Domain":   dns.account.DomainName,
+		"RecordId": id,
+	}, nil)
+}
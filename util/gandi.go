@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type GandiAccount struct {
+	// The personal access token with the permission to manage the domain technical configurations
+	Token  string `json:"token"`
+	Domain string `json:"domain"`
+	// The endpoint of the Gandi LiveDNS API, e.g. https://api.gandi.net/v5/livedns
+	Endpoint string `json:"endpoint"`
+}
+
+type GandiRRSet struct {
+	// The name relative to the domain, `@` for the apex
+	Name   string   `json:"rrset_name"`
+	Type   string   `json:"rrset_type"`
+	TTL    int      `json:"rrset_ttl,omitempty"`
+	Values []string `json:"rrset_values"`
+}
+
+type GandiUtils struct {
+	account    GandiAccount
+	rest       *RESTClient
+	recordsURL string
+}
+
+func gandiErrorMessage(body []byte) string {
+	var respErr struct {
+		Message string `json:"message"`
+		Cause   string `json:"cause"`
+		Errors  []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &respErr) != nil {
+		return ""
+	}
+	if len(respErr.Errors) != 0 {
+		messages := make([]string, 0, len(respErr.Errors))
+		for _, e := range respErr.Errors {
+			messages = append(messages, e.Name+": "+e.Description)
+		}
+		return strings.Join(messages, "; ")
+	}
+	if respErr.Cause != "" && respErr.Message != "" {
+		return respErr.Cause + ": " + respErr.Message
+	}
+	return respErr.Message
+}
+
+func NewGandiUtils(account GandiAccount) (*GandiUtils, error) {
+	if account.Token == "" {
+		return nil, fmt.Errorf("gandi token is required")
+	}
+	if account.Endpoint == "" {
+		account.Endpoint = "https://api.gandi.net/v5/livedns"
+	}
+	account.Domain = strings.TrimSuffix(account.Domain, ".")
+	rest := NewRESTClient("gandi livedns", account.Endpoint, http.Header{"Authorization": {"Bearer " + account.Token}})
+	rest.ErrorMessage = gandiErrorMessage
+	return &GandiUtils{
+		account:    account,
+		rest:       rest,
+		recordsURL: "/domains/" + url.PathEscape(account.Domain) + "/records",
+	}, nil
+}
+
+func (dns *GandiUtils) rrsetURL(name string, rrtype string) string {
+	return dns.recordsURL + "/" + url.PathEscape(name) + "/" + url.PathEscape(rrtype)
+}
+
+// ListRRSets returns all RRsets of the domain
+func (dns *GandiUtils) ListRRSets(ctx context.Context) ([]*GandiRRSet, error) {
+	var rrsets []*GandiRRSet
+	if err := dns.rest.Do(ctx, http.MethodGet, dns.recordsURL, nil, nil, &rrsets); err != nil {
+		return nil, err
+	}
+	return rrsets, nil
+}
+
+// PutRRSet creates or replaces the RRset of the name and type
+func (dns *GandiUtils) PutRRSet(ctx context.Context, rrset *GandiRRSet) error {
+	body := map[string]interface{}{"rrset_values": rrset.Values}
+	if rrset.TTL != 0 {
+		body["rrset_ttl"] = rrset.TTL
+	}
+	return dns.rest.Do(ctx, http.MethodPut, dns.rrsetURL(rrset.Name, rrset.Type), nil, body, nil)
+}
+
+func (dns *GandiUtils) DeleteRRSet(ctx context.Context, name string, rrtype string) error {
+	return dns.rest.Do(ctx, http.MethodDelete, dns.rrsetURL(name, rrtype), nil, nil, nil)
+}
@@ -0,0 +1,185 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const googleDNSScope = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
+
+type GoogleDNSAccount struct {
+	Project     string `json:"project"`
+	ManagedZone string `json:"managed-zone"`
+	// The service account JSON key, the application default credentials are used if empty
+	CredentialsJSON string `json:"credentials-json"`
+	// The endpoint of the Cloud DNS API, e.g. https://dns.googleapis.com
+	Endpoint string `json:"endpoint"`
+}
+
+type GoogleDNSRRSet struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl,omitempty"`
+	RRDatas []string `json:"rrdatas"`
+}
+
+type GoogleDNSUtils struct {
+	account GoogleDNSAccount
+	client  *http.Client
+	baseURL string
+}
+
+// NewGoogleTokenSource creates the token source of the service account JSON key,
+// or of the application default credentials if empty
+func NewGoogleTokenSource(credentialsJSON string) (oauth2.TokenSource, error) {
+	var creds *google.Credentials
+	var err error
+	// the token source outlives the reconcile, so it must not use the reconcile context
+	if credentialsJSON == "" {
+		creds, err = google.FindDefaultCredentials(context.Background(), googleDNSScope)
+	} else {
+		creds, err = google.CredentialsFromJSON(context.Background(), []byte(credentialsJSON), googleDNSScope)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return creds.TokenSource, nil
+}
+
+// NewGoogleDNSUtils creates the utils of the account authorized by ts, see NewGoogleTokenSource
+func NewGoogleDNSUtils(account GoogleDNSAccount, ts oauth2.TokenSource) (*GoogleDNSUtils, error) {
+	if account.Project == "" {
+		return nil, fmt.Errorf("google project is required")
+	}
+	if account.Endpoint == "" {
+		account.Endpoint = "https://dns.googleapis.com"
+	}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &oauth2.Transport{Source: ts},
+	}
+	return &GoogleDNSUtils{
+		account: account,
+		client:  client,
+		baseURL: strings.TrimSuffix(account.Endpoint, "/") + "/dns/v1/projects/" + url.PathEscape(account.Project),
+	}, nil
+}
+
+func (dns *GoogleDNSUtils) do(ctx context.Context, method string, path string, body interface{}, resp interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, dns.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := dns.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= 300 {
+		var respErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(httpResp.Body).Decode(&respErr); err != nil || respErr.Error.Message == "" {
+			return NewRESTError("google cloud dns", method, path, httpResp, "")
+		}
+		return NewRESTError("google cloud dns", method, path, httpResp, respErr.Error.Message)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func (dns *GoogleDNSUtils) zonePath() string {
+	return "/managedZones/" + url.PathEscape(dns.account.ManagedZone)
+}
+
+// FindManagedZone returns the name of the public or private managed zone with the dns name
+func (dns *GoogleDNSUtils) FindManagedZone(ctx context.Context, dnsName string) (string, error) {
+	if !strings.HasSuffix(dnsName, ".") {
+		dnsName += "."
+	}
+	var resp struct {
+		ManagedZones []struct {
+			Name    string `json:"name"`
+			DNSName string `json:"dnsName"`
+		} `json:"managedZones"`
+	}
+	if err := dns.do(ctx, http.MethodGet, "/managedZones?dnsName="+url.QueryEscape(dnsName), nil, &resp); err != nil {
+		return "", err
+	}
+	switch len(resp.ManagedZones) {
+	case 0:
+		return "", fmt.Errorf("managed zone %s not found in project %s", dnsName, dns.account.Project)
+	case 1:
+		return resp.ManagedZones[0].Name, nil
+	default:
+		return "", fmt.Errorf("found %d managed zones named %s, set managedZone to choose one", len(resp.ManagedZones), dnsName)
+	}
+}
+
+// SetManagedZone sets the managed zone discovered by FindManagedZone
+func (dns *GoogleDNSUtils) SetManagedZone(managedZone string) {
+	dns.account.ManagedZone = managedZone
+}
+
+// ListRRSets returns all RRsets of the managed zone
+func (dns *GoogleDNSUtils) ListRRSets(ctx context.Context) ([]*GoogleDNSRRSet, error) {
+	var rrsets []*GoogleDNSRRSet
+	pageToken := ""
+	for {
+		var resp struct {
+			RRSets        []*GoogleDNSRRSet `json:"rrsets"`
+			NextPageToken string            `json:"nextPageToken"`
+		}
+		path := dns.zonePath() + "/rrsets"
+		if pageToken != "" {
+			path += "?pageToken=" + url.QueryEscape(pageToken)
+		}
+		if err := dns.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+			return nil, err
+		}
+		rrsets = append(rrsets, resp.RRSets...)
+		if resp.NextPageToken == "" {
+			return rrsets, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// ChangeRRSets applies the additions and deletions in one change, which either succeed or fail together.
+// The deletions must match the current RRsets exactly.
+func (dns *GoogleDNSUtils) ChangeRRSets(ctx context.Context, additions []*GoogleDNSRRSet, deletions []*GoogleDNSRRSet) error {
+	change := map[string][]*GoogleDNSRRSet{}
+	if len(additions) != 0 {
+		change["additions"] = additions
+	}
+	if len(deletions) != 0 {
+		change["deletions"] = deletions
+	}
+	return dns.do(ctx, http.MethodPost, dns.zonePath()+"/changes", change, nil)
+}
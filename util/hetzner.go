@@ -0,0 +1,143 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type HetznerDNSAccount struct {
+	APIToken string `json:"api-token"`
+	ZoneName string `json:"zone-name"`
+	// The endpoint of the Hetzner DNS API, e.g. https://dns.hetzner.com/api/v1
+	Endpoint string `json:"endpoint"`
+}
+
+type HetznerDNSRecord struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	// The name relative to the zone, `@` for the apex
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	// The default ttl of the zone is used if 0
+	TTL int `json:"ttl,omitempty"`
+}
+
+type HetznerDNSUtils struct {
+	account HetznerDNSAccount
+	rest    *RESTClient
+	zoneID  string
+}
+
+func hetznerErrorMessage(body []byte) string {
+	var respErr struct {
+		Message string `json:"message"`
+		Error   struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &respErr) != nil {
+		return ""
+	}
+	if respErr.Error.Message != "" {
+		return respErr.Error.Message
+	}
+	return respErr.Message
+}
+
+func NewHetznerDNSUtils(account HetznerDNSAccount) (*HetznerDNSUtils, error) {
+	if account.APIToken == "" {
+		return nil, fmt.Errorf("hetzner api token is required")
+	}
+	if account.Endpoint == "" {
+		account.Endpoint = "https://dns.hetzner.com/api/v1"
+	}
+	account.ZoneName = strings.TrimSuffix(account.ZoneName, ".")
+	rest := NewRESTClient("hetzner dns", account.Endpoint, http.Header{"Auth-API-Token": {account.APIToken}})
+	rest.ErrorMessage = hetznerErrorMessage
+	return &HetznerDNSUtils{account: account, rest: rest}, nil
+}
+
+// ZoneID returns the id of the zone, which is looked up by name on the first call
+func (dns *HetznerDNSUtils) ZoneID(ctx context.Context) (string, error) {
+	if dns.zoneID != "" {
+		return dns.zoneID, nil
+	}
+	var resp struct {
+		Zones []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"zones"`
+	}
+	if err := dns.rest.Do(ctx, http.MethodGet, "/zones", url.Values{"name": {dns.account.ZoneName}}, nil, &resp); err != nil {
+		return "", err
+	}
+	for _, zone := range resp.Zones {
+		if strings.EqualFold(zone.Name, dns.account.ZoneName) {
+			dns.zoneID = zone.ID
+			return dns.zoneID, nil
+		}
+	}
+	return "", fmt.Errorf("hetzner dns zone %s not found", dns.account.ZoneName)
+}
+
+// ListRecords returns all records of the zone
+func (dns *HetznerDNSUtils) ListRecords(ctx context.Context) ([]*HetznerDNSRecord, error) {
+	zoneID, err := dns.ZoneID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var records []*HetznerDNSRecord
+	for page := 1; ; page++ {
+		var resp struct {
+			Records []*HetznerDNSRecord `json:"records"`
+			Meta    struct {
+				Pagination struct {
+					LastPage int `json:"last_page"`
+				} `json:"pagination"`
+			} `json:"meta"`
+		}
+		query := url.Values{"zone_id": {zoneID}, "page": {strconv.Itoa(page)}, "per_page": {"100"}}
+		if err := dns.rest.Do(ctx, http.MethodGet, "/records", query, nil, &resp); err != nil {
+			return nil, err
+		}
+		records = append(records, resp.Records...)
+		if page >= resp.Meta.Pagination.LastPage || len(resp.Records) == 0 {
+			return records, nil
+		}
+	}
+}
+
+// CreateRecord creates the record in the zone, returns the created record
+func (dns *HetznerDNSUtils) CreateRecord(ctx context.Context, record *HetznerDNSRecord) (*HetznerDNSRecord, error) {
+	zoneID, err := dns.ZoneID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record.ZoneID = zoneID
+	var resp struct {
+		Record *HetznerDNSRecord `json:"record"`
+	}
+	if err := dns.rest.Do(ctx, http.MethodPost, "/records", nil, record, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Record, nil
+}
+
+func (dns *HetznerDNSUtils) UpdateRecord(ctx context.Context, id string, record *HetznerDNSRecord) error {
+	zoneID, err := dns.ZoneID(ctx)
+	if err != nil {
+		return err
+	}
+	record.ZoneID = zoneID
+	return dns.rest.Do(ctx, http.MethodPut, "/records/"+url.PathEscape(id), nil, record, nil)
+}
+
+func (dns *HetznerDNSUtils) DeleteRecord(ctx context.Context, id string) error {
+	return dns.rest.Do(ctx, http.MethodDelete, "/records/"+url.PathEscape(id), nil, nil, nil)
+}
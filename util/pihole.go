@@ -0,0 +1,224 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type PiHoleAccount struct {
+	// The URL of the Pi-hole web interface, e.g. http://pi.hole
+	ServerURL string `json:"server-url"`
+	Password  string `json:"password"`
+}
+
+// PiHoleLocalRecord is an entry of the local DNS records (`dns.hosts`) or local CNAME records (`dns.cnameRecords`)
+type PiHoleLocalRecord struct {
+	Domain string
+	// A, AAAA or CNAME
+	Type string
+	// The IP address of A and AAAA records, or the target of CNAME records
+	Value string
+	// The TTL of CNAME records, 0 means the default TTL
+	TTL int
+	// the config item as listed, which may hold multiple domains
+	raw string
+}
+
+// item returns the config item of the record, in the form of `<ip> <domain>` or `<domain>,<target>[,<ttl>]`
+func (r *PiHoleLocalRecord) item() string {
+	if r.raw != "" {
+		return r.raw
+	}
+	if r.Type != "CNAME" {
+		return r.Value + " " + r.Domain
+	}
+	item := r.Domain + "," + r.Value
+	if r.TTL > 0 {
+		item += "," + strconv.Itoa(r.TTL)
+	}
+	return item
+}
+
+func (r *PiHoleLocalRecord) configPath() string {
+	if r.Type == "CNAME" {
+		return "/api/config/dns/cnameRecords/"
+	}
+	return "/api/config/dns/hosts/"
+}
+
+type PiHoleUtils struct {
+	account PiHoleAccount
+	client  *http.Client
+	baseURL string
+}
+
+var (
+	piholeSessionsMu sync.Mutex
+	// the sessions are shared by the providers of the same server, as Pi-hole limits the count of sessions
+	piholeSessions = map[string]string{}
+)
+
+func NewPiHoleUtils(account PiHoleAccount) (*PiHoleUtils, error) {
+	if account.ServerURL == "" {
+		return nil, fmt.Errorf("pihole serverURL is required")
+	}
+	return &PiHoleUtils{
+		account: account,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: strings.TrimSuffix(account.ServerURL, "/"),
+	}, nil
+}
+
+func (dns *PiHoleUtils) sessionKey() string {
+	return dns.baseURL + "\n" + dns.account.Password
+}
+
+// login creates a new session, an empty session id is returned if no password is set
+func (dns *PiHoleUtils) login(ctx context.Context) (string, error) {
+	var resp struct {
+		Session struct {
+			Valid   bool    `json:"valid"`
+			SID     *string `json:"sid"`
+			Message string  `json:"message"`
+		} `json:"session"`
+	}
+	if _, err := dns.request(ctx, http.MethodPost, "/api/auth", "", map[string]string{"password": dns.account.Password}, &resp); err != nil {
+		return "", err
+	}
+	if !resp.Session.Valid {
+		return "", fmt.Errorf("pihole login: %s", resp.Session.Message)
+	}
+	sid := ""
+	if resp.Session.SID != nil {
+		sid = *resp.Session.SID
+	}
+	piholeSessionsMu.Lock()
+	piholeSessions[dns.sessionKey()] = sid
+	piholeSessionsMu.Unlock()
+	return sid, nil
+}
+
+func (dns *PiHoleUtils) request(ctx context.Context, method string, path string, sid string, body interface{}, resp interface{}) (status int, err error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, dns.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if sid != "" {
+		req.Header.Set("X-FTL-SID", sid)
+	}
+
+	httpResp, err := dns.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= 300 {
+		var respErr struct {
+			Error struct {
+				Key     string `json:"key"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(httpResp.Body).Decode(&respErr); err != nil || respErr.Error.Message == "" {
+			return httpResp.StatusCode, NewRESTError("pihole", method, path, httpResp, "")
+		}
+		return httpResp.StatusCode, NewRESTError("pihole", method, path, httpResp, respErr.Error.Message)
+	}
+	if resp == nil {
+		return httpResp.StatusCode, nil
+	}
+	return httpResp.StatusCode, json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// do sends the request with the cached session, logging in again if the session has expired
+func (dns *PiHoleUtils) do(ctx context.Context, method string, path string, resp interface{}) error {
+	piholeSessionsMu.Lock()
+	sid, ok := piholeSessions[dns.sessionKey()]
+	piholeSessionsMu.Unlock()
+	if !ok {
+		var err error
+		if sid, err = dns.login(ctx); err != nil {
+			return err
+		}
+	}
+	status, err := dns.request(ctx, method, path, sid, nil, resp)
+	if status != http.StatusUnauthorized {
+		return err
+	}
+	if sid, err = dns.login(ctx); err != nil {
+		return err
+	}
+	_, err = dns.request(ctx, method, path, sid, nil, resp)
+	return err
+}
+
+// ListLocalRecords returns all local DNS records and local CNAME records
+func (dns *PiHoleUtils) ListLocalRecords(ctx context.Context) ([]PiHoleLocalRecord, error) {
+	var resp struct {
+		Config struct {
+			DNS struct {
+				Hosts        []string `json:"hosts"`
+				CNAMERecords []string `json:"cnameRecords"`
+			} `json:"dns"`
+		} `json:"config"`
+	}
+	if err := dns.do(ctx, http.MethodGet, "/api/config/dns", &resp); err != nil {
+		return nil, err
+	}
+	var records []PiHoleLocalRecord
+	for _, host := range resp.Config.DNS.Hosts {
+		fields := strings.Fields(host)
+		if len(fields) < 2 {
+			continue
+		}
+		recordType := "A"
+		if strings.Contains(fields[0], ":") {
+			recordType = "AAAA"
+		}
+		// a line of the hosts may have multiple host names
+		for _, domain := range fields[1:] {
+			records = append(records, PiHoleLocalRecord{Domain: domain, Type: recordType, Value: fields[0], raw: host})
+		}
+	}
+	for _, cname := range resp.Config.DNS.CNAMERecords {
+		fields := strings.Split(cname, ",")
+		if len(fields) < 2 {
+			continue
+		}
+		record := PiHoleLocalRecord{Domain: fields[0], Type: "CNAME", Value: fields[len(fields)-1], raw: cname}
+		if ttl, err := strconv.Atoi(fields[len(fields)-1]); err == nil && len(fields) > 2 {
+			record.Value, record.TTL = fields[len(fields)-2], ttl
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (dns *PiHoleUtils) AddLocalRecord(ctx context.Context, record PiHoleLocalRecord) error {
+	return dns.do(ctx, http.MethodPut, record.configPath()+url.PathEscape(record.item()), nil)
+}
+
+func (dns *PiHoleUtils) DeleteLocalRecord(ctx context.Context, record PiHoleLocalRecord) error {
+	return dns.do(ctx, http.MethodDelete, record.configPath()+url.PathEscape(record.item()), nil)
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type PowerDNSAccount struct {
+	// The URL of the PowerDNS server, e.g. http://pdns:8081
+	ServerURL string `json:"server-url"`
+	ServerID  string `json:"server-id"`
+	APIKey    string `json:"api-key"`
+	Zone      string `json:"zone"`
+}
+
+type PowerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type PowerDNSComment struct {
+	Content    string `json:"content"`
+	Account    string `json:"account"`
+	ModifiedAt int64  `json:"modified_at,omitempty"`
+}
+
+type PowerDNSRRSet struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	TTL        int               `json:"ttl,omitempty"`
+	ChangeType string            `json:"changetype,omitempty"`
+	Records    []PowerDNSRecord  `json:"records"`
+	Comments   []PowerDNSComment `json:"comments"`
+}
+
+type PowerDNSUtils struct {
+	account PowerDNSAccount
+	client  *http.Client
+	zoneURL string
+}
+
+func NewPowerDNSUtils(account PowerDNSAccount) (*PowerDNSUtils, error) {
+	if account.ServerURL == "" {
+		return nil, fmt.Errorf("powerdns serverURL is required")
+	}
+	if account.ServerID == "" {
+		account.ServerID = "localhost"
+	}
+	if !strings.HasSuffix(account.Zone, ".") {
+		account.Zone += "."
+	}
+	return &PowerDNSUtils{
+		account: account,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		zoneURL: strings.TrimSuffix(account.ServerURL, "/") + "/api/v1/servers/" + url.PathEscape(account.ServerID) + "/zones/" + url.PathEscape(account.Zone),
+	}, nil
+}
+
+func (dns *PowerDNSUtils) do(ctx context.Context, method string, body interface{}, resp interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, dns.zoneURL, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", dns.account.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := dns.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= 300 {
+		var respErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(httpResp.Body).Decode(&respErr); err != nil || respErr.Error == "" {
+			return NewRESTError("powerdns", method, dns.account.Zone, httpResp, "")
+		}
+		return NewRESTError("powerdns", method, dns.account.Zone, httpResp, respErr.Error)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// ListRRSets returns all RRsets of the zone
+func (dns *PowerDNSUtils) ListRRSets(ctx context.Context) ([]*PowerDNSRRSet, error) {
+	var zone struct {
+		RRSets []*PowerDNSRRSet `json:"rrsets"`
+	}
+	if err := dns.do(ctx, http.MethodGet, nil, &zone); err != nil {
+		return nil, err
+	}
+	return zone.RRSets, nil
+}
+
+// PatchRRSets applies the changes of the RRsets in one request, which either succeed or fail together
+func (dns *PowerDNSUtils) PatchRRSets(ctx context.Context, rrsets ...*PowerDNSRRSet) error {
+	return dns.do(ctx, http.MethodPatch, map[string]interface{}{"rrsets": rrsets}, nil)
+}
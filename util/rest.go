@@ -0,0 +1,208 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RESTClient is the HTTP plumbing shared by the JSON REST APIs of the DNS vendors,
+// so a vendor client only declares its endpoint, authentication and error format.
+// Requests rejected by rate limiting (429) are retried after the Retry-After delay,
+// idempotent requests failed by the network or with 5xx are retried with exponential backoff.
+type RESTClient struct {
+	// The name of the API in the errors, e.g. `hetzner dns`
+	Name string
+	// The URL the request paths are relative to, e.g. https://dns.hetzner.com/api/v1
+	BaseURL string
+	// The headers set on every request, e.g. the authorization header
+	Header http.Header
+	// ErrorMessage extracts the message from the body of a failed request, only the status is reported if nil or empty
+	ErrorMessage func(body []byte) string
+	// The max count of retries of a request
+	MaxRetries int
+	// The max delay before a retry, the error is returned if the server requests a longer delay
+	MaxRetryDelay time.Duration
+
+	client *http.Client
+}
+
+// RESTError is the error of a request answered with a non-2xx status
+type RESTError struct {
+	API        string
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Message    string
+	// The delay requested by the Retry-After header, 0 if absent
+	RetryAfter time.Duration
+}
+
+func (e *RESTError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s %s: %s", e.API, e.Method, e.Path, e.Status)
+	}
+	return fmt.Sprintf("%s %s %s: %s: %s", e.API, e.Method, e.Path, e.Status, e.Message)
+}
+
+// NewRESTError returns the error of the failed response, the message is omitted if empty
+func NewRESTError(api string, method string, path string, resp *http.Response, message string) *RESTError {
+	return &RESTError{
+		API:        api,
+		Method:     method,
+		Path:       path,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// IsRESTStatus reports whether the err is a RESTError with the status code
+func IsRESTStatus(err error, statusCode int) bool {
+	var restErr *RESTError
+	return errors.As(err, &restErr) && restErr.StatusCode == statusCode
+}
+
+func NewRESTClient(name string, baseURL string, header http.Header) *RESTClient {
+	return &RESTClient{
+		Name:          name,
+		BaseURL:       strings.TrimSuffix(baseURL, "/"),
+		Header:        header,
+		MaxRetries:    3,
+		MaxRetryDelay: 10 * time.Second,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Do sends the body encoded as JSON to the path and decodes the response into resp, the body and resp can be nil.
+// The request and its retries are cancelled with the ctx.
+func (c *RESTClient) Do(ctx context.Context, method string, path string, query url.Values, body interface{}, resp interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	rawURL := c.BaseURL + path
+	if len(query) != 0 {
+		rawURL += "?" + query.Encode()
+	}
+	for attempt := 0; ; attempt++ {
+		err := c.do(ctx, method, path, rawURL, payload, resp)
+		if err == nil || attempt >= c.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+		delay, ok := c.retryDelay(method, err, attempt)
+		if !ok {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+func (c *RESTClient) do(ctx context.Context, method string, path string, rawURL string, payload []byte, resp interface{}) error {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= 300 {
+		restErr := NewRESTError(c.Name, method, path, httpResp, "")
+		if body, err := io.ReadAll(httpResp.Body); err == nil && c.ErrorMessage != nil {
+			restErr.Message = c.ErrorMessage(body)
+		}
+		return restErr
+	}
+	if resp == nil || httpResp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// retryDelay returns the delay before retrying the request failed with the err, or false if it should not be retried
+func (c *RESTClient) retryDelay(method string, err error, attempt int) (time.Duration, bool) {
+	// 500ms, 1s, 2s... with up to 50% jitter, so the retries of concurrent requests are spread
+	backoff := (500 * time.Millisecond) << attempt
+	backoff += time.Duration(rand.Int63n(int64(backoff / 2)))
+
+	var restErr *RESTError
+	if errors.As(err, &restErr) {
+		switch {
+		case restErr.StatusCode == http.StatusTooManyRequests:
+			if restErr.RetryAfter > 0 {
+				backoff = restErr.RetryAfter
+			}
+		case restErr.StatusCode >= 500 && idempotent(method):
+		default:
+			return 0, false
+		}
+	} else {
+		var urlErr *url.Error
+		// the request may have been applied if the connection is broken after it's sent
+		if !errors.As(err, &urlErr) || !idempotent(method) {
+			return 0, false
+		}
+	}
+	if backoff > c.MaxRetryDelay {
+		return 0, false
+	}
+	return backoff, true
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses the Retry-After header in seconds or HTTP date, returns 0 if it's invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}